@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/Zaphoood/tresor/src/cli"
 	"github.com/Zaphoood/tresor/src/keepass/database"
 	"github.com/Zaphoood/tresor/src/tui"
 	"github.com/Zaphoood/tresor/src/util"
@@ -11,6 +12,12 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		if cmd, ok := cli.Lookup(os.Args[1]); ok {
+			os.Exit(cmd.Run(os.Args[2:]))
+		}
+	}
+
 	f, err := tea.LogToFile("debug.log", "debug")
 	if err != nil {
 		fmt.Println("fatal:", err)
@@ -11,6 +11,7 @@ require (
 	golang.design/x/clipboard v0.6.3
 	golang.org/x/crypto v0.6.0
 	golang.org/x/exp v0.0.0-20230425010034-47ecfdc1ba53
+	golang.org/x/term v0.5.0
 )
 
 require (
@@ -32,7 +33,6 @@ require (
 	golang.org/x/image v0.7.0 // indirect
 	golang.org/x/mobile v0.0.0-20210716004757-34ab1303b554 // indirect
 	golang.org/x/sys v0.5.0 // indirect
-	golang.org/x/term v0.5.0 // indirect
 	golang.org/x/text v0.9.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,82 @@
+// Package kdbx reads and writes KeePass databases in the KDBX 3.1 format. It is the stable entry point
+// for using tresor as a library.
+//
+//	f, err := os.Open("passwords.kdbx")
+//	...
+//	db, err := kdbx.Open(f, kdbx.PasswordKey("secret"))
+//	...
+//	l, err := db.Document().Resolve("Root/Internet/GitHub")
+package kdbx
+
+import (
+	"io"
+
+	"github.com/Zaphoood/tresor/src/keepass/crypto"
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// CompositeKey is the hash of all credentials a database is encrypted with
+type CompositeKey = crypto.CompositeKey
+
+// Document is the decrypted content of a database, which may be read and modified
+type Document = parser.Document
+
+type (
+	Group    = parser.Group
+	Entry    = parser.Entry
+	Item     = parser.Item
+	Location = parser.Location
+)
+
+// Errors returned by Open wrap one of these, so that they may be told apart with errors.Is
+var (
+	ErrWrongKey           = database.ErrWrongKey
+	ErrCorrupted          = database.ErrCorrupted
+	ErrTruncated          = database.ErrTruncated
+	ErrNotKeePass         = database.ErrNotKeePass
+	ErrUnsupportedVersion = database.ErrUnsupportedVersion
+	ErrUnsupportedCipher  = database.ErrUnsupportedCipher
+)
+
+// PasswordKey returns the composite key of a database which is protected by just a password
+func PasswordKey(password string) CompositeKey {
+	return crypto.PasswordKey(password)
+}
+
+// DB is a decrypted database
+type DB struct {
+	db *database.Database
+}
+
+// Open reads an encrypted database from r until its end, decrypting it with key and parsing it along the way.
+// Neither the encrypted nor the decrypted content is held in memory, only the resulting document.
+func Open(r io.Reader, key CompositeKey) (*DB, error) {
+	d := database.New("")
+	d.SetKey(key)
+	err := d.DecryptFrom(r)
+	if err != nil {
+		return nil, err
+	}
+	err = d.CheckHeaderHash()
+	if err != nil {
+		return nil, err
+	}
+	return &DB{d}, nil
+}
+
+// Document returns the database's content. Changes to it are included when writing the database.
+func (db *DB) Document() *Document {
+	return db.db.Parsed()
+}
+
+// SetKey changes the key the database is encrypted with when it is written
+func (db *DB) SetKey(key CompositeKey) {
+	db.db.SetKey(key)
+}
+
+// WriteTo encrypts the database and writes it to w. New random seeds are used every time.
+// It returns the number of bytes written.
+func (db *DB) WriteTo(w io.Writer) (int64, error) {
+	return db.db.WriteTo(w)
+}
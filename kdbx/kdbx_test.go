@@ -0,0 +1,78 @@
+package kdbx
+
+import (
+	"bytes"
+	"os"
+	"testing"
+	"testing/iotest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func openExample(t *testing.T, path string) *DB {
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	// Reading one byte at a time makes sure that short reads are handled
+	db, err := Open(iotest.OneByteReader(f), PasswordKey("foo"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestOpenWrite(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, path := range []string{"../src/keepass/test/example.kdbx", "../src/keepass/test/example_compressed.kdbx"} {
+		db := openExample(t, path)
+		l, err := db.Document().Resolve("test/Sample Entry #2")
+		if !assert.Nil(err) {
+			continue
+		}
+		entry := l.Item.(Entry)
+		assert.Equal("Michael321", entry.TryGet("UserName", ""))
+
+		var buf bytes.Buffer
+		n, err := db.WriteTo(&buf)
+		assert.Nil(err)
+		assert.Equal(int64(buf.Len()), n)
+
+		reopened, err := Open(&buf, PasswordKey("foo"))
+		if assert.Nil(err) {
+			_, err = reopened.Document().Resolve("test/Sample Entry #2")
+			assert.Nil(err)
+		}
+	}
+}
+
+func TestSetKey(t *testing.T) {
+	assert := assert.New(t)
+
+	db := openExample(t, "../src/keepass/test/example.kdbx")
+	db.SetKey(PasswordKey("bar"))
+	var buf bytes.Buffer
+	_, err := db.WriteTo(&buf)
+	assert.Nil(err)
+	written := buf.Bytes()
+
+	_, err = Open(bytes.NewReader(written), PasswordKey("foo"))
+	assert.ErrorIs(err, ErrWrongKey)
+	_, err = Open(bytes.NewReader(written), PasswordKey("bar"))
+	assert.Nil(err)
+}
+
+func TestOpenTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	content, err := os.ReadFile("../src/keepass/test/example.kdbx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, length := range []int{0, 3, 12, 100} {
+		_, err = Open(bytes.NewReader(content[:length]), PasswordKey("foo"))
+		assert.ErrorIs(err, ErrTruncated, "length %d", length)
+	}
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/autotype"
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+func runAutoType(args []string) error {
+	fs := newFlagSet("autotype")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	window := fs.String("window", "", "Title of the target window, used for matching associations (default: active window)")
+	tool := fs.String("tool", string(autotype.XDOTOOL), "Tool for sending keystrokes: xdotool or ydotool")
+	delay := fs.Duration("delay", 0, "Time to wait before typing, e. g. to focus the target window")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return usageError{"Expected at most one entry"}
+	}
+
+	sink, err := autotype.NewExecSink(autotype.Tool(*tool))
+	if err != nil {
+		return err
+	}
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+
+	time.Sleep(*delay)
+	if len(*window) == 0 && autotype.Tool(*tool) == autotype.XDOTOOL {
+		*window = activeWindowTitle()
+	}
+
+	var entry parser.Entry
+	if len(positional) == 1 {
+		entry, err = findEntry(d.Parsed(), positional[0])
+		if err != nil {
+			return err
+		}
+	} else {
+		entry, err = entryForWindow(d.Parsed(), *window)
+		if err != nil {
+			return err
+		}
+	}
+
+	sequence, err := autotype.SequenceFor(d.Parsed(), &entry, *window)
+	if err != nil {
+		return err
+	}
+	return autotype.Perform(d.Parsed(), &entry, sequence, sink)
+}
+
+// entryForWindow returns the only entry matching the given window title
+func entryForWindow(d *parser.Document, window string) (parser.Entry, error) {
+	if len(window) == 0 {
+		return parser.Entry{}, usageError{"Either an entry or the target window must be specified"}
+	}
+	matches := autotype.MatchingEntries(d, window)
+	switch len(matches) {
+	case 0:
+		return parser.Entry{}, fmt.Errorf("No entry matches window '%s'", window)
+	case 1:
+		return matches[0], nil
+	default:
+		titles := make([]string, 0, len(matches))
+		for _, match := range matches {
+			titles = append(titles, match.TryGet("Title", match.UUID))
+		}
+		return parser.Entry{}, fmt.Errorf("Multiple entries match window '%s': %s", window, strings.Join(titles, ", "))
+	}
+}
+
+// activeWindowTitle returns the title of the currently focused window, or an empty string if it can't be determined
+func activeWindowTitle() string {
+	out, err := exec.Command(string(autotype.XDOTOOL), "getactivewindow", "getwindowname").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
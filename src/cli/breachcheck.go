@@ -0,0 +1,55 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/Zaphoood/tresor/src/keepass/hibp"
+)
+
+func runBreachCheck(args []string) error {
+	fs := newFlagSet("breachcheck")
+	passwordFD := passwordFlag(fs)
+	dir := fs.String("hibp-dir", os.Getenv(hibp.ENV_DIR), "Directory containing the Have I Been Pwned range files (default $"+hibp.ENV_DIR+")")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	path, err := fileArg(positional)
+	if err != nil {
+		return err
+	}
+	if len(*dir) == 0 {
+		return usageError{fmt.Sprintf("No range files specified, use --hibp-dir or set $%s", hibp.ENV_DIR)}
+	}
+	store, err := hibp.Open(*dir)
+	if err != nil {
+		return err
+	}
+
+	d, err := openDatabase(path, *passwordFD)
+	if err != nil {
+		return err
+	}
+	breaches, err := hibp.CheckDocument(d.Parsed(), store)
+	if err != nil {
+		return err
+	}
+
+	if len(breaches) == 0 {
+		fmt.Println("No compromised passwords found.")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COUNT\tENTRY")
+	for _, breach := range breaches {
+		fmt.Fprintf(w, "%d\t%s\n", breach.Count, breach.Entry)
+	}
+	err = w.Flush()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\n%d compromised passwords found.\n", len(breaches))
+	return nil
+}
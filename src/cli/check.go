@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/integrity"
+)
+
+func runCheck(args []string) error {
+	fs := newFlagSet("check")
+	passwordFD := passwordFlag(fs)
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	fix := fs.Bool("fix", false, "Repair the problems which can be repaired safely and save the database")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	path, err := fileArg(positional)
+	if err != nil {
+		return err
+	}
+
+	// The header hash is checked as part of the report
+	d, err := decryptDatabase(path, *passwordFD)
+	if err != nil {
+		return err
+	}
+	report := integrity.Run(d)
+	fixed := []integrity.Problem{}
+	if *fix && len(report.Problems) > 0 {
+		// Saving writes a new header hash
+		for _, p := range report.Problems {
+			if p.Check == integrity.HEADER_HASH {
+				fixed = append(fixed, p)
+			}
+		}
+		fixed = append(fixed, integrity.Fix(d.Parsed())...)
+		err = d.Save()
+		if err != nil {
+			return err
+		}
+		// Check what was actually written
+		err = reload(d)
+		if err != nil {
+			return err
+		}
+		report = integrity.Run(d)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(struct {
+			integrity.Report
+			Fixed []integrity.Problem `json:"fixed"`
+		}{report, fixed})
+	} else {
+		err = writeCheckReport(os.Stdout, path, report, fixed)
+	}
+	if err != nil {
+		return err
+	}
+	if len(report.Problems) > 0 {
+		return exitCodeError{EXIT_CORRUPTED}
+	}
+	return nil
+}
+
+// reload reads, decrypts and parses a database again, using the same key
+func reload(d *database.Database) error {
+	err := d.Load()
+	if err == nil {
+		err = d.Decrypt()
+	}
+	return err
+}
+
+// writeCheckReport prints the problems found by an integrity check, and the ones which were fixed, as a
+// human-readable table
+func writeCheckReport(out io.Writer, path string, r integrity.Report, fixed []integrity.Problem) error {
+	fmt.Fprintf(out, "Database: %s\n\n", path)
+	if len(fixed) > 0 {
+		fmt.Fprintf(out, "Fixed %d problems:\n", len(fixed))
+		err := writeProblems(out, fixed)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out)
+	}
+	if len(r.Problems) == 0 {
+		fmt.Fprintln(out, "No problems found.")
+		return nil
+	}
+	err := writeProblems(out, r.Problems)
+	if err != nil {
+		return err
+	}
+
+	checks := make([]string, 0, len(r.Summary))
+	for check := range r.Summary {
+		checks = append(checks, string(check))
+	}
+	sort.Strings(checks)
+	fmt.Fprintf(out, "\n%d problems:", len(r.Problems))
+	for _, check := range checks {
+		fmt.Fprintf(out, " %s=%d", check, r.Summary[integrity.Check(check)])
+	}
+	fmt.Fprintln(out)
+	return nil
+}
+
+func writeProblems(out io.Writer, problems []integrity.Problem) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CHECK\tITEM\tDETAILS")
+	for _, p := range problems {
+		item := p.Item
+		if len(item) == 0 {
+			item = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", p.Check, item, p.Message)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,266 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"golang.org/x/term"
+)
+
+// Environment variable which may be used instead of the -db flag
+const ENV_DATABASE = "TRESOR_DB"
+
+// Environment variable containing the database password. If set, the password isn't prompted for.
+const ENV_PASSWORD = "TRESOR_PASSWORD"
+
+const (
+	EXIT_OK    = 0
+	EXIT_ERROR = 1
+	EXIT_USAGE = 2
+	// An entry, group or field doesn't exist
+	EXIT_NOT_FOUND = 3
+	// The password is incorrect
+	EXIT_WRONG_KEY = 4
+	// The database is corrupted or truncated
+	EXIT_CORRUPTED = 5
+	// The file isn't a database in a supported format
+	EXIT_UNSUPPORTED = 6
+)
+
+// Command is a non-interactive subcommand, e.g. `tresor totp`
+type Command struct {
+	Name  string
+	Usage string
+	run   func(args []string) error
+}
+
+var commands = []Command{
+	{"ls", "ls [-db FILE] [-password-fd N] [-uuid] [GROUP]", runLs},
+	{"show", "show [-db FILE] [-password-fd N] [-reveal] [-raw] ENTRY", runShow},
+	{"get", "get [-db FILE] [-password-fd N] [-f FIELD] [-raw] ENTRY", runGet},
+	{"add", "add [-db FILE] [-password-fd N] [-set KEY=VALUE]... GROUP/TITLE", runAdd},
+	{"edit", "edit [-db FILE] [-password-fd N] [-set KEY=VALUE]... [-unset KEY]... ENTRY", runEdit},
+	{"rm", "rm [-db FILE] [-password-fd N] [-permanent] ENTRY", runRm},
+	{"shell", "shell [-password-fd N] [FILE]", runShell},
+	{"run", "run [-db FILE] [-password-fd N] -env NAME=PATH... [-no-mask] [--] COMMAND [ARGS...]", runRun},
+	{"inject", "inject [-db FILE] [-password-fd N] [-i TEMPLATE] [-o OUTPUT]", runInject},
+	{"clip", "clip [-db FILE] [-password-fd N] [-f FIELD] [-raw] [-clear SECONDS] ENTRY", runClip},
+	{CLIP_HELPER_COMMAND, CLIP_HELPER_COMMAND + " SECONDS (used internally by clip)", runClipHelper},
+	{"totp", "totp [-db FILE] [-password-fd N] ENTRY", runTOTP},
+	{"autotype", "autotype [-db FILE] [-password-fd N] [-window TITLE] [-tool xdotool|ydotool] [-delay DURATION] [ENTRY]", runAutoType},
+	{"audit", "audit [-password-fd N] [-json] [-max-age DAYS] [FILE]", runAudit},
+	{"breachcheck", "breachcheck [-password-fd N] --hibp-dir DIR [FILE]", runBreachCheck},
+	{"check", "check [-password-fd N] [-json] [-fix] [FILE]", runCheck},
+	{"recover", "recover [-password-fd N] [-o OUTPUT] [FILE]", runRecover},
+}
+
+// Lookup returns the command with the given name, and false if there is no such command
+func Lookup(name string) (Command, bool) {
+	for _, cmd := range commands {
+		if cmd.Name == name {
+			return cmd, true
+		}
+	}
+	return Command{}, false
+}
+
+// Run executes the command with the given arguments and returns the exit code
+func (c Command) Run(args []string) int {
+	err := c.run(args)
+	if err == nil {
+		return EXIT_OK
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "Usage: tresor %s\n", c.Usage)
+		return EXIT_OK
+	}
+	if _, ok := err.(usageError); ok {
+		fmt.Fprintf(os.Stderr, "%s\nUsage: tresor %s\n", err, c.Usage)
+		return EXIT_USAGE
+	}
+	if e, ok := err.(exitCodeError); ok {
+		// The child process has already reported its error
+		return e.code
+	}
+	// Errors from templates wrap the error of the failed lookup
+	if errors.As(err, &notFoundError{}) {
+		fmt.Fprintf(os.Stderr, "tresor %s: %s\n", c.Name, err)
+		return EXIT_NOT_FOUND
+	}
+	fmt.Fprintf(os.Stderr, "tresor %s: %s\n", c.Name, err)
+	return databaseExitCode(err)
+}
+
+// databaseExitCode returns the exit code for an error that occurred while opening a database
+func databaseExitCode(err error) int {
+	switch {
+	case errors.Is(err, database.ErrWrongKey):
+		return EXIT_WRONG_KEY
+	case errors.Is(err, database.ErrCorrupted), errors.Is(err, database.ErrTruncated):
+		return EXIT_CORRUPTED
+	case errors.Is(err, database.ErrNotKeePass), errors.Is(err, database.ErrUnsupportedVersion),
+		errors.Is(err, database.ErrUnsupportedCipher):
+		return EXIT_UNSUPPORTED
+	}
+	return EXIT_ERROR
+}
+
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// notFoundError is returned if an entry, group or field doesn't exist
+type notFoundError struct {
+	msg string
+}
+
+func (e notFoundError) Error() string {
+	return e.msg
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	// Errors are reported by Command.Run
+	fs.SetOutput(io.Discard)
+	return fs
+}
+
+// parseArgs parses flags and returns the positional arguments. Unlike flag.FlagSet.Parse,
+// flags may also appear after positional arguments. Everything after "--" is positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	positional := []string{}
+	for {
+		err := fs.Parse(args)
+		if err != nil {
+			if errors.Is(err, flag.ErrHelp) {
+				return nil, err
+			}
+			return nil, usageError{err.Error()}
+		}
+		consumed := len(args) - fs.NArg()
+		if consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, fs.Args()...), nil
+		}
+		if fs.NArg() == 0 {
+			return positional, nil
+		}
+		positional = append(positional, fs.Arg(0))
+		args = fs.Args()[1:]
+	}
+}
+
+// databaseFlag registers the -db flag on the given flag set
+func databaseFlag(fs *flag.FlagSet) *string {
+	return fs.String("db", os.Getenv(ENV_DATABASE), "Path to the database (default $"+ENV_DATABASE+")")
+}
+
+// passwordFlag registers the -password-fd flag on the given flag set
+func passwordFlag(fs *flag.FlagSet) *int {
+	return fs.Int("password-fd", -1, "Read the password from this file descriptor (default: $"+ENV_PASSWORD+" or prompt)")
+}
+
+// fileArg returns the database file given as the only positional argument, falling back to $TRESOR_DB
+func fileArg(positional []string) (string, error) {
+	switch len(positional) {
+	case 0:
+		return os.Getenv(ENV_DATABASE), nil
+	case 1:
+		return positional[0], nil
+	default:
+		return "", usageError{"Expected at most one file"}
+	}
+}
+
+// openDatabase loads, decrypts and parses the database at path and verifies its header hash. The
+// password is read from passwordFD if it isn't negative, see readPassword.
+func openDatabase(path string, passwordFD int) (*database.Database, error) {
+	d, err := decryptDatabase(path, passwordFD)
+	if err != nil {
+		return nil, err
+	}
+	err = d.CheckHeaderHash()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// decryptDatabase is like openDatabase, but doesn't verify the header hash
+func decryptDatabase(path string, passwordFD int) (*database.Database, error) {
+	if len(path) == 0 {
+		return nil, usageError{fmt.Sprintf("No database specified, use -db or set $%s", ENV_DATABASE)}
+	}
+	d := database.New(path)
+	err := d.Load()
+	if err != nil {
+		return nil, err
+	}
+	password, err := readPassword(path, passwordFD)
+	if err != nil {
+		return nil, err
+	}
+	d.SetPassword(password)
+	err = d.Decrypt()
+	if err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// readPassword returns the database password. It is read from the first line of passwordFD if it isn't
+// negative, or taken from $TRESOR_PASSWORD if that is set. Otherwise, it is prompted for on the terminal,
+// or read from the first line of stdin if that isn't a terminal.
+func readPassword(path string, passwordFD int) (string, error) {
+	if passwordFD >= 0 {
+		f := os.NewFile(uintptr(passwordFD), "password")
+		if f == nil {
+			return "", fmt.Errorf("Invalid file descriptor: %d", passwordFD)
+		}
+		defer f.Close()
+		return readLine(f)
+	}
+	if password, ok := os.LookupEnv(ENV_PASSWORD); ok {
+		return password, nil
+	}
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "Password for %s: ", path)
+		password, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		return string(password), err
+	}
+	return readLine(os.Stdin)
+}
+
+// readLine returns the first line read from r, without the line break. Since r is read byte by byte,
+// nothing after the line is consumed, e. g. commands for the shell following the password on stdin.
+func readLine(r io.Reader) (string, error) {
+	line := []byte{}
+	b := make([]byte, 1)
+	for {
+		n, err := r.Read(b)
+		if n == 1 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimRight(string(line), "\r"), nil
+}
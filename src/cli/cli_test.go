@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		args       []string
+		positional []string
+		db         string
+		raw        bool
+	}{
+		{[]string{}, []string{}, "", false},
+		{[]string{"a", "b"}, []string{"a", "b"}, "", false},
+		{[]string{"-db", "x.kdbx", "-raw", "a"}, []string{"a"}, "x.kdbx", true},
+		// Flags may follow positional arguments
+		{[]string{"a", "-raw", "b", "-db", "x.kdbx"}, []string{"a", "b"}, "x.kdbx", true},
+		// Everything after "--" is positional
+		{[]string{"a", "--", "-raw", "b"}, []string{"a", "-raw", "b"}, "", false},
+		{[]string{"-raw", "--", "--"}, []string{"--"}, "", true},
+		{[]string{"--"}, []string{}, "", false},
+	}
+	for _, c := range cases {
+		fs := newFlagSet("test")
+		db := fs.String("db", "", "")
+		raw := fs.Bool("raw", false, "")
+		positional, err := parseArgs(fs, c.args)
+		if assert.Nil(err, "%q", c.args) {
+			assert.Equal(c.positional, positional, "%q", c.args)
+			assert.Equal(c.db, *db, "%q", c.args)
+			assert.Equal(c.raw, *raw, "%q", c.args)
+		}
+	}
+
+	for _, args := range [][]string{{"-nope"}, {"a", "-db"}, {"a", "-raw=maybe"}} {
+		_, err := parseArgs(newFlagSet("test"), args)
+		assert.IsType(usageError{}, err, "%q", args)
+	}
+	_, err := parseArgs(newFlagSet("test"), []string{"a", "-h"})
+	assert.ErrorIs(err, flag.ErrHelp)
+}
+
+func TestCommandExitCode(t *testing.T) {
+	assert := assert.New(t)
+
+	// Errors are reported on stderr, which isn't of interest here
+	stderr := os.Stderr
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = devNull
+	defer func() {
+		os.Stderr = stderr
+		devNull.Close()
+	}()
+
+	cases := []struct {
+		err  error
+		code int
+	}{
+		{nil, EXIT_OK},
+		{flag.ErrHelp, EXIT_OK},
+		{errors.New("failed"), EXIT_ERROR},
+		{usageError{"Expected exactly one entry"}, EXIT_USAGE},
+		{notFoundError{"No such entry: a"}, EXIT_NOT_FOUND},
+		{fmt.Errorf("template: %w", notFoundError{"No such field: b"}), EXIT_NOT_FOUND},
+		{exitCodeError{42}, 42},
+		{fmt.Errorf("%w: HMAC mismatch", database.ErrWrongKey), EXIT_WRONG_KEY},
+		{database.ErrCorrupted, EXIT_CORRUPTED},
+		{fmt.Errorf("%w: block 3", database.ErrTruncated), EXIT_CORRUPTED},
+		{database.ErrNotKeePass, EXIT_UNSUPPORTED},
+		{database.ErrUnsupportedVersion, EXIT_UNSUPPORTED},
+		{database.ErrUnsupportedCipher, EXIT_UNSUPPORTED},
+	}
+	for _, c := range cases {
+		cmd := Command{Name: "test", Usage: "test", run: func(_ []string) error { return c.err }}
+		assert.Equal(c.code, cmd.Run(nil), "%v", c.err)
+	}
+}
+
+func TestUsage(t *testing.T) {
+	// The password may be read from a file descriptor by every command which opens a database
+	for _, cmd := range commands {
+		if cmd.Name != CLIP_HELPER_COMMAND {
+			assert.Contains(t, cmd.Usage, "[-password-fd N]", cmd.Name)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/util"
+	"golang.design/x/clipboard"
+)
+
+// Name of the internal command which holds the clipboard until it is cleared
+const CLIP_HELPER_COMMAND = "clip-helper"
+
+// Written by the helper once the value has been copied
+const CLIP_HELPER_OK = "OK"
+
+func runClip(args []string) error {
+	fs := newFlagSet("clip")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	field := fs.String("f", "Password", "Name of the field to copy")
+	raw := fs.Bool("raw", false, "Don't resolve field references and placeholders")
+	delay := fs.Int("clear", util.CLEAR_CLIPBOARD_DELAY, "Clear the clipboard after this many seconds, 0 to never clear it")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+	if *delay < 0 {
+		return usageError{"The delay must not be negative"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	entry, err := findEntry(d.Parsed(), positional[0])
+	if err != nil {
+		return err
+	}
+	value, err := fieldValue(d.Parsed(), &entry, *field, *raw)
+	if err != nil {
+		return err
+	}
+	err = startClipHelper(value.Inner, *delay)
+	if err != nil {
+		return err
+	}
+	if *delay > 0 {
+		fmt.Fprintf(os.Stderr, "Copied %s to clipboard, clearing in %d seconds\n", *field, *delay)
+	} else {
+		fmt.Fprintf(os.Stderr, "Copied %s to clipboard\n", *field)
+	}
+	return nil
+}
+
+// startClipHelper starts a detached process which copies value to the clipboard and clears it after
+// delay seconds, unless its contents have changed in the meantime. The value is passed through a pipe,
+// so that it doesn't show up in the process list. Returns once the value has been copied.
+//
+// A separate process is needed since this one exits right away, and on X11 the clipboard's contents
+// are lost when the process which owns them exits.
+func startClipHelper(value string, delay int) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(executable, CLIP_HELPER_COMMAND, fmt.Sprint(delay))
+	detach(cmd)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	err = cmd.Start()
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(stdin, value)
+	stdin.Close()
+	if err != nil {
+		return err
+	}
+
+	// The helper closes stdout after reporting its status
+	output, err := io.ReadAll(stdout)
+	status := strings.TrimSpace(string(output))
+	if status != CLIP_HELPER_OK {
+		cmd.Wait()
+		if len(status) == 0 {
+			return fmt.Errorf("Failed to start clipboard helper: %v", err)
+		}
+		return errors.New(status)
+	}
+	// The helper keeps running after this process exits
+	return cmd.Process.Release()
+}
+
+// runClipHelper is run by startClipHelper. It copies stdin to the clipboard, reports success on stdout
+// and waits until the clipboard can be cleared.
+func runClipHelper(args []string) error {
+	var delay int
+	if len(args) != 1 {
+		return usageError{"Expected the delay in seconds"}
+	}
+	_, err := fmt.Sscan(args[0], &delay)
+	if err != nil {
+		return usageError{err.Error()}
+	}
+	value, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return err
+	}
+	err = clipboard.Init()
+	if err != nil {
+		fmt.Println(err)
+		return err
+	}
+	changed := clipboard.Write(clipboard.FmtText, value)
+	fmt.Println(CLIP_HELPER_OK)
+	os.Stdout.Close()
+
+	if delay == 0 {
+		<-changed
+		return nil
+	}
+	select {
+	case <-changed:
+	case <-time.After(time.Duration(delay) * time.Second):
+		clipboard.Write(clipboard.FmtText, []byte(""))
+	}
+	return nil
+}
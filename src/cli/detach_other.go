@@ -0,0 +1,8 @@
+//go:build !(aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris)
+
+package cli
+
+import "os/exec"
+
+// detach is a no-op on systems without sessions
+func detach(cmd *exec.Cmd) {}
@@ -0,0 +1,13 @@
+//go:build aix || darwin || dragonfly || freebsd || linux || netbsd || openbsd || solaris
+
+package cli
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach makes cmd run in a new session, so that it isn't terminated along with the terminal
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,407 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/Zaphoood/tresor/src/keepass/undo"
+)
+
+// Shown instead of protected values. Its length doesn't depend on the value, so that it isn't revealed.
+const MASKED_VALUE = "********"
+
+// Format of times shown by the show command
+const TIME_FORMAT = "2006-01-02 15:04:05"
+
+// Fields every entry has, in the order they are shown
+var standardFields = []string{"Title", "UserName", "Password", "URL", "Notes"}
+
+// assignments collects repeated KEY=VALUE flags, e. g. -set
+type assignments []parser.String
+
+func (a *assignments) String() string {
+	return ""
+}
+
+func (a *assignments) Set(s string) error {
+	key, value, ok := strings.Cut(s, "=")
+	if !ok || len(key) == 0 {
+		return fmt.Errorf("Expected KEY=VALUE, got '%s'", s)
+	}
+	*a = append(*a, parser.String{Key: key, Value: wrappers.Value{Inner: value}})
+	return nil
+}
+
+// keys collects repeated flags which specify field names
+type keys []string
+
+func (k *keys) String() string {
+	return ""
+}
+
+func (k *keys) Set(s string) error {
+	*k = append(*k, s)
+	return nil
+}
+
+func runLs(args []string) error {
+	fs := newFlagSet("ls")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	showUUID := fs.Bool("uuid", false, "Print each item's UUID before its name")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 1 {
+		return usageError{"Expected at most one group"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	groups := d.Parsed().Root.Groups
+	entries := []parser.Entry{}
+	if len(positional) == 1 {
+		l, err := findGroup(d.Parsed(), positional[0])
+		if err != nil {
+			return err
+		}
+		group := l.Item.(parser.Group)
+		groups, entries = group.Groups, group.Entries
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, group := range groups {
+		if *showUUID {
+			fmt.Fprintf(w, "%s\t", group.UUID)
+		}
+		fmt.Fprintf(w, "%s%c\n", parser.EscapeName(group.Name), parser.PATH_SEPARATOR)
+	}
+	for _, entry := range entries {
+		if *showUUID {
+			fmt.Fprintf(w, "%s\t", entry.UUID)
+		}
+		fmt.Fprintln(w, parser.EscapeName(entry.TryGet("Title", "")))
+	}
+	return w.Flush()
+}
+
+func runShow(args []string) error {
+	fs := newFlagSet("show")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	reveal := fs.Bool("reveal", false, "Show protected values, such as the password, instead of masking them")
+	raw := fs.Bool("raw", false, "Don't resolve field references and placeholders")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	l, err := findEntryLocation(d.Parsed(), positional[0])
+	if err != nil {
+		return err
+	}
+	return writeEntry(os.Stdout, d.Parsed(), l, *reveal, *raw)
+}
+
+// writeEntry prints all fields of the entry at l. Protected values are masked unless reveal is true.
+func writeEntry(out io.Writer, d *parser.Document, l parser.Location, reveal, raw bool) error {
+	entry := l.Item.(parser.Entry)
+	w := tabwriter.NewWriter(out, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(w, "UUID:\t%s\n", entry.UUID)
+	fmt.Fprintf(w, "Path:\t%s\n", parser.JoinPath(l.Names))
+	for _, key := range fieldKeys(entry) {
+		value, err := fieldValue(d, &entry, key, raw)
+		if err != nil {
+			return err
+		}
+		if value.Protected && !reveal && len(value.Inner) > 0 {
+			value.Inner = MASKED_VALUE
+		}
+		fmt.Fprintf(w, "%s:\t%s\n", key, value.Inner)
+	}
+	if tags := entry.TagList(); len(tags) > 0 {
+		fmt.Fprintf(w, "Tags:\t%s\n", strings.Join(tags, ", "))
+	}
+	if entry.Times.Expires.Value() {
+		fmt.Fprintf(w, "Expires:\t%s\n", entry.Times.ExpiryTime.Local().Format(TIME_FORMAT))
+	}
+	fmt.Fprintf(w, "Modified:\t%s\n", entry.Times.LastModificationTime.Local().Format(TIME_FORMAT))
+	return w.Flush()
+}
+
+func runGet(args []string) error {
+	fs := newFlagSet("get")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	field := fs.String("f", "Password", "Name of the field to print")
+	raw := fs.Bool("raw", false, "Don't resolve field references and placeholders")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	entry, err := findEntry(d.Parsed(), positional[0])
+	if err != nil {
+		return err
+	}
+	value, err := fieldValue(d.Parsed(), &entry, *field, *raw)
+	if err != nil {
+		return err
+	}
+	fmt.Println(value.Inner)
+	return nil
+}
+
+func runAdd(args []string) error {
+	fs := newFlagSet("add")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	var set assignments
+	fs.Var(&set, "set", "Set field KEY to VALUE (may be repeated)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one path"}
+	}
+	names := parser.SplitPath(positional[0])
+	if len(names) < 2 {
+		return usageError{"Expected the path of the new entry, consisting of its group and its title"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	document := d.Parsed()
+	l, err := findGroup(document, parser.JoinPath(names[:len(names)-1]))
+	if err != nil {
+		return err
+	}
+	entry, err := newEntry(document, names[len(names)-1])
+	if err != nil {
+		return err
+	}
+	for _, s := range set {
+		entry.SetField(s.Key, s.Value.Inner)
+	}
+	err = document.AddEntry(l.Item.GetUUID(), entry)
+	if err != nil {
+		return err
+	}
+	err = d.Save()
+	if err != nil {
+		return err
+	}
+	fmt.Println(entry.UUID)
+	return nil
+}
+
+func runEdit(args []string) error {
+	fs := newFlagSet("edit")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	var set assignments
+	var unset keys
+	fs.Var(&set, "set", "Set field KEY to VALUE, adding the field if it doesn't exist (may be repeated)")
+	fs.Var(&unset, "unset", "Delete field KEY; standard fields are set to an empty string instead (may be repeated)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+	if len(set) == 0 && len(unset) == 0 {
+		return usageError{"Nothing to do, use -set or -unset"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	entry, err := findEntry(d.Parsed(), positional[0])
+	if err != nil {
+		return err
+	}
+	changed, err := applyEdits(&entry, set, unset)
+	if err != nil || !changed {
+		return err
+	}
+	d.Parsed().UpdateEntry(entry)
+	return d.Save()
+}
+
+// applyEdits deletes the fields in unset from entry and then sets the fields in set.
+// Standard fields are set to an empty string instead of being deleted.
+// Returns true if a change was made.
+func applyEdits(entry *parser.Entry, set assignments, unset keys) (bool, error) {
+	changed := false
+	for _, key := range unset {
+		if isStandardField(key) {
+			changed = entry.UpdateField(key, "") || changed
+		} else if entry.DeleteField(key) {
+			changed = true
+		} else {
+			return false, notFoundError{fmt.Sprintf("No such field: %s", key)}
+		}
+	}
+	for _, s := range set {
+		changed = entry.SetField(s.Key, s.Value.Inner) || changed
+	}
+	if changed {
+		entry.Times.LastModificationTime = now()
+	}
+	return changed, nil
+}
+
+func runRm(args []string) error {
+	fs := newFlagSet("rm")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	permanent := fs.Bool("permanent", false, "Delete the entry instead of moving it to the recycle bin")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	l, err := findEntryLocation(d.Parsed(), positional[0])
+	if err != nil {
+		return err
+	}
+	if recycle(d.Parsed(), l, *permanent) {
+		err = d.Parsed().MoveItem(l.Item.GetUUID(), d.Parsed().Meta.RecycleBinUUID)
+	} else {
+		_, err = d.Parsed().RemoveItem(l.Item.GetUUID())
+	}
+	if err != nil {
+		return err
+	}
+	return d.Save()
+}
+
+// removeAction returns an action which moves the entry at l to the recycle bin, or deletes it, see recycle
+func removeAction(d *parser.Document, l parser.Location, permanent bool) undo.Action[parser.Document] {
+	entry := l.Item.(parser.Entry)
+	groupUUID := l.Path[len(l.Path)-2]
+	title := entry.TryGet("Title", "")
+	if recycle(d, l, permanent) {
+		return undo.NewMoveItemAction(groupUUID, d.Meta.RecycleBinUUID, entry, nil, fmt.Sprintf("Move '%s' to recycle bin", title))
+	}
+	return undo.NewRemoveItemAction(groupUUID, entry, nil, fmt.Sprintf("Delete '%s'", title))
+}
+
+// recycle checks whether the item at l should be moved to the recycle bin when it is removed. If the recycle
+// bin is disabled, permanent is true or the item is in the recycle bin already, it is deleted instead.
+func recycle(d *parser.Document, l parser.Location, permanent bool) bool {
+	recycleBin := d.Meta.RecycleBinUUID
+	inRecycleBin := false
+	for _, uuid := range l.Path {
+		inRecycleBin = inRecycleBin || uuid == recycleBin
+	}
+	_, recycleBinExists := d.FindPath(recycleBin)
+	return !permanent && d.Meta.RecycleBinEnabled.Value() && recycleBinExists && !inRecycleBin
+}
+
+// fieldKeys returns the keys of an entry's fields, standard fields first
+func fieldKeys(entry parser.Entry) []string {
+	keys := []string{}
+	for _, key := range standardFields {
+		if _, err := entry.Get(key); err == nil {
+			keys = append(keys, key)
+		}
+	}
+	for _, field := range entry.Strings {
+		if !isStandardField(field.Key) {
+			keys = append(keys, field.Key)
+		}
+	}
+	return keys
+}
+
+func isStandardField(key string) bool {
+	for _, field := range standardFields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// fieldValue returns the value of an entry's field, with references and placeholders resolved unless raw is
+// true. A resolved value is protected if any of the fields it was resolved from is.
+func fieldValue(d *parser.Document, entry *parser.Entry, key string, raw bool) (wrappers.Value, error) {
+	value, err := entry.Get(key)
+	if err != nil {
+		return wrappers.Value{}, notFoundError{fmt.Sprintf("No such field: %s", key)}
+	}
+	if raw {
+		return value, nil
+	}
+	value.Inner, value.Protected, err = d.ResolveField(entry, key)
+	return value, err
+}
+
+// newEntry returns an empty entry with the given title and a random UUID. Standard fields are
+// protected according to the document's memory protection settings. Its times are set once it is added.
+func newEntry(d *parser.Document, title string) (parser.Entry, error) {
+	uuid, err := parser.NewUUID()
+	if err != nil {
+		return parser.Entry{}, err
+	}
+	protection := d.Meta.MemoryProtection
+	protected := map[string]bool{
+		"Title":    protection.ProtectTitle.Value(),
+		"UserName": protection.ProtectUserName.Value(),
+		"Password": protection.ProtectPassword.Value(),
+		"URL":      protection.ProtectURL.Value(),
+		"Notes":    protection.ProtectNotes.Value(),
+	}
+	entry := parser.Entry{UUID: uuid}
+	for _, key := range standardFields {
+		entry.Strings = append(entry.Strings, parser.String{Key: key, Value: wrappers.Value{Protected: protected[key]}})
+	}
+	entry.UpdateField("Title", title)
+	entry.Times = parser.Times{
+		ExpiryTime: now(),
+		Expires:    wrappers.NewBool(false),
+	}
+	entry.AutoType.Enabled = wrappers.NewBool(true)
+	return entry, nil
+}
+
+// now returns the current time in the precision stored in KeePass files
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
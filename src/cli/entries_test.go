@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyEdits(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		set     []string
+		unset   []string
+		changed bool
+		// Fields of the entry afterwards, with missing fields left out
+		expected map[string]string
+	}{
+		{nil, nil, false, map[string]string{"Title": "db", "UserName": "admin", "Password": "hunter2", "Extra": "x"}},
+		{[]string{"Password=new"}, nil, true, map[string]string{"Title": "db", "UserName": "admin", "Password": "new", "Extra": "x"}},
+		{[]string{"Password=hunter2"}, nil, false, map[string]string{"Title": "db", "UserName": "admin", "Password": "hunter2", "Extra": "x"}},
+		{[]string{"New=a=b"}, nil, true, map[string]string{"Title": "db", "UserName": "admin", "Password": "hunter2", "Extra": "x", "New": "a=b"}},
+		// Standard fields are emptied rather than deleted
+		{nil, []string{"UserName", "Extra"}, true, map[string]string{"Title": "db", "UserName": "", "Password": "hunter2"}},
+		// Fields are unset before being set
+		{[]string{"Extra=y"}, []string{"Extra"}, true, map[string]string{"Title": "db", "UserName": "admin", "Password": "hunter2", "Extra": "y"}},
+	}
+	for _, c := range cases {
+		entry := newTestEntry("AQEBAQEBAQEBAQEBAQEBAQ==", map[string]string{"Title": "db", "UserName": "admin", "Password": "hunter2", "Extra": "x"})
+		var set assignments
+		for _, s := range c.set {
+			assert.Nil(set.Set(s))
+		}
+		changed, err := applyEdits(&entry, set, keys(c.unset))
+		if !assert.Nil(err, "set %q, unset %q", c.set, c.unset) {
+			continue
+		}
+		assert.Equal(c.changed, changed, "set %q, unset %q", c.set, c.unset)
+		assert.Equal(c.changed, !entry.Times.LastModificationTime.IsZero(), "set %q, unset %q", c.set, c.unset)
+		fields := map[string]string{}
+		for _, s := range entry.Strings {
+			fields[s.Key] = s.Value.Inner
+		}
+		assert.Equal(c.expected, fields, "set %q, unset %q", c.set, c.unset)
+	}
+
+	entry := newTestEntry("AQEBAQEBAQEBAQEBAQEBAQ==", map[string]string{"Title": "db"})
+	_, err := applyEdits(&entry, nil, keys{"Missing"})
+	assert.IsType(notFoundError{}, err)
+
+	var set assignments
+	assert.NotNil(set.Set("no value"))
+	assert.NotNil(set.Set("=value"))
+}
+
+func TestWriteEntry(t *testing.T) {
+	assert := assert.New(t)
+	d := testDocument()
+	l, err := find(d, "db", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := func(reveal, raw bool) []string {
+		var out bytes.Buffer
+		assert.Nil(writeEntry(&out, d, l, reveal, raw))
+		return strings.Split(out.String(), "\n")
+	}
+	written := lines(false, false)
+	assert.Contains(written, "UUID:     BAQEBAQEBAQEBAQEBAQEBA==")
+	assert.Contains(written, "Path:     Root/Servers/db")
+	assert.Contains(written, "UserName: admin")
+	assert.Contains(written, "Password: "+MASKED_VALUE)
+	// The URL contains the password through a placeholder
+	assert.Contains(written, "URL:      "+MASKED_VALUE)
+
+	written = lines(true, false)
+	assert.Contains(written, "Password: hunter2")
+	assert.Contains(written, "URL:      https://db?p=hunter2")
+
+	written = lines(false, true)
+	assert.Contains(written, "Password: "+MASKED_VALUE)
+	assert.Contains(written, "URL:      https://db?p={PASSWORD}")
+}
+
+func TestFieldValue(t *testing.T) {
+	assert := assert.New(t)
+	d := testDocument()
+	entry, err := findEntry(d, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := fieldValue(d, &entry, "URL", false)
+	if assert.Nil(err) {
+		assert.Equal(wrappers.Value{Inner: "https://db?p=hunter2", Protected: true}, value)
+	}
+	value, err = fieldValue(d, &entry, "URL", true)
+	if assert.Nil(err) {
+		assert.Equal(wrappers.Value{Inner: "https://db?p={PASSWORD}"}, value)
+	}
+	_, err = fieldValue(d, &entry, "Missing", false)
+	assert.IsType(notFoundError{}, err)
+}
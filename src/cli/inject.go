@@ -0,0 +1,206 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Name of the template function which returns a field's value
+const TEMPLATE_FUNC = "tresor"
+
+// Scheme of URIs which are replaced by a field's value, e. g. tresor://Servers/db/Password
+const URI_SCHEME = "tresor://"
+
+// Permissions of files written by inject, since they contain secrets
+const INJECT_FILE_MODE = 0600
+
+// URIs end at whitespace, quotes and punctuation which usually follows them, e. g. in "(see tresor://a/b),".
+// Names containing these characters must be percent-encoded.
+var uriPattern = regexp.MustCompile(regexp.QuoteMeta(URI_SCHEME) + "[^\\s\"'`<>,;)\\]}]+")
+
+func runInject(args []string) error {
+	fs := newFlagSet("inject")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	inPath := fs.String("i", "", "Template to render (default: stdin)")
+	outPath := fs.String("o", "", "File to write the result to (default: stdout)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) > 0 {
+		return usageError{"Expected no positional arguments, use -i and -o"}
+	}
+
+	var in []byte
+	name := "stdin"
+	if len(*inPath) == 0 {
+		in, err = io.ReadAll(os.Stdin)
+	} else {
+		in, err = os.ReadFile(*inPath)
+		name = filepath.Base(*inPath)
+	}
+	if err != nil {
+		return err
+	}
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	tmpl, err := parseTemplate(name, string(in), d.Parsed())
+	if err != nil {
+		return err
+	}
+
+	if len(*outPath) == 0 {
+		return tmpl.Execute(os.Stdout, nil)
+	}
+	return writeTemplate(tmpl, *outPath)
+}
+
+// parseTemplate parses text as a template. Besides the usual template syntax, it may contain references to
+// fields in the form {{ tresor "Servers/db" "Password" }} or tresor://Servers/db/Password, which are
+// replaced by the field's value, with field references resolved. URIs are only replaced outside of actions.
+func parseTemplate(name, text string, d *parser.Document) (*template.Template, error) {
+	var uriErr error
+	text = replaceURIs(text, func(uri string) string {
+		path, field, err := parseURI(uri)
+		if err != nil && uriErr == nil {
+			uriErr = err
+		}
+		return fmt.Sprintf("{{ %s %s %s }}", TEMPLATE_FUNC, strconv.Quote(path), strconv.Quote(field))
+	})
+	if uriErr != nil {
+		return nil, uriErr
+	}
+
+	funcs := template.FuncMap{
+		TEMPLATE_FUNC: func(path string, field ...string) (string, error) {
+			key := "Password"
+			switch len(field) {
+			case 0:
+			case 1:
+				key = field[0]
+			default:
+				return "", fmt.Errorf("Expected an entry and at most one field, got %d arguments", len(field)+1)
+			}
+			entry, err := findEntry(d, path)
+			if err != nil {
+				return "", err
+			}
+			value, err := fieldValue(d, &entry, key, false)
+			return value.Inner, err
+		},
+	}
+	return template.New(name).Funcs(funcs).Option("missingkey=error").Parse(text)
+}
+
+// replaceURIs replaces the tresor:// URIs in text with the actions which replace returns for them, leaving
+// existing actions, i. e. everything between "{{" and "}}", alone
+func replaceURIs(text string, replace func(uri string) string) string {
+	var b strings.Builder
+	for {
+		start := strings.Index(text, "{{")
+		if start < 0 {
+			replaceURIsInText(&b, text, replace)
+			return b.String()
+		}
+		replaceURIsInText(&b, text[:start], replace)
+		end := start + actionLength(text[start:])
+		b.WriteString(text[start:end])
+		text = text[end:]
+	}
+}
+
+// replaceURIsInText replaces the URIs in text, which contains no actions, and writes the result to b
+func replaceURIsInText(b *strings.Builder, text string, replace func(uri string) string) {
+	written := 0
+	for _, match := range uriPattern.FindAllStringIndex(text, -1) {
+		before := text[written:match[0]]
+		// A brace right before the action would be taken as part of its delimiter, as in "${tresor://a/b}"
+		if strings.HasSuffix(before, "{") {
+			before = before[:len(before)-1] + `{{ "{" }}`
+		}
+		b.WriteString(before)
+		b.WriteString(replace(text[match[0]:match[1]]))
+		written = match[1]
+	}
+	b.WriteString(text[written:])
+}
+
+// actionLength returns the length of the action at the beginning of text, including its delimiters. Strings
+// and comments are skipped, since they may contain "}}". If the action isn't closed, it extends to the end.
+func actionLength(text string) int {
+	for i := len("{{"); i < len(text); i++ {
+		switch text[i] {
+		case '"', '\'', '`':
+			quote := text[i]
+			for i++; i < len(text) && text[i] != quote; i++ {
+				if text[i] == '\\' && quote != '`' {
+					i++
+				}
+			}
+		case '/':
+			if strings.HasPrefix(text[i:], "/*") {
+				end := strings.Index(text[i:], "*/")
+				if end < 0 {
+					return len(text)
+				}
+				i += end + 1
+			}
+		case '}':
+			if strings.HasPrefix(text[i:], "}}") {
+				return i + len("}}")
+			}
+		}
+	}
+	return len(text)
+}
+
+// parseURI splits a tresor:// URI into the path of an entry and the name of a field. Path segments may be
+// percent-encoded, e. g. tresor://Servers/My%20Server/Password or tresor://Servers/a%2Fb/Password.
+func parseURI(uri string) (string, string, error) {
+	segments := strings.Split(strings.TrimPrefix(uri, URI_SCHEME), string(parser.PATH_SEPARATOR))
+	if len(segments) < 2 {
+		return "", "", fmt.Errorf("Invalid reference '%s': expected the path of an entry followed by a field name", uri)
+	}
+	names := make([]string, len(segments))
+	for i, segment := range segments {
+		name, err := url.PathUnescape(segment)
+		if err != nil {
+			return "", "", fmt.Errorf("Invalid reference '%s': %s", uri, err)
+		}
+		names[i] = name
+	}
+	return parser.JoinPath(names[:len(names)-1]), names[len(names)-1], nil
+}
+
+// writeTemplate renders tmpl to a file at path which is only accessible by its owner. The file is only
+// replaced once rendering succeeded, so that it is never left half written.
+func writeTemplate(tmpl *template.Template, path string) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	err = f.Chmod(INJECT_FILE_MODE)
+	if err == nil {
+		err = tmpl.Execute(f, nil)
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
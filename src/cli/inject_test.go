@@ -0,0 +1,116 @@
+package cli
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseURI(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		uri   string
+		path  string
+		field string
+	}{
+		{"tresor://Root/Servers/db/Password", "Root/Servers/db", "Password"},
+		{"tresor://db/UserName", "db", "UserName"},
+		{"tresor://Root/Servers/My%20Server/Password", "Root/Servers/My Server", "Password"},
+		{"tresor://Root/a%2Fb/Password", `Root/a\/b`, "Password"},
+	}
+	for _, c := range cases {
+		path, field, err := parseURI(c.uri)
+		if assert.Nil(err, c.uri) {
+			assert.Equal(c.path, path, c.uri)
+			assert.Equal(c.field, field, c.uri)
+		}
+	}
+
+	for _, uri := range []string{"tresor://db", "tresor://db/%zz"} {
+		_, _, err := parseURI(uri)
+		assert.NotNil(err, uri)
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	assert := assert.New(t)
+	d := testDocument()
+
+	cases := []struct {
+		template string
+		expected string
+	}{
+		{`pass={{ tresor "Root/Servers/db" }}`, "pass=hunter2"},
+		{`user={{ tresor "db" "UserName" }}`, "user=admin"},
+		{"url={{ tresor \"db\" \"URL\" }}", "url=https://db?p=hunter2"},
+		{"pass=tresor://db/Password\n", "pass=hunter2\n"},
+		{"tresor://Root/Servers/My%20Server/Password", "s3cret"},
+		// Punctuation following a URI isn't part of it
+		{"tresor://db/UserName, tresor://db/Password;", "admin, hunter2;"},
+		{"(tresor://db/UserName) [tresor://db/Password] {tresor://db/Password}", "(admin) [hunter2] {hunter2}"},
+		{`"tresor://db/Password"`, `"hunter2"`},
+		{"PASS=${tresor://db/Password}", "PASS=${hunter2}"},
+		// URIs within actions are left alone
+		{`{{ "tresor://db/Password" }}`, "tresor://db/Password"},
+		{`{{ printf "%s}}" "tresor://db/Password" }} tresor://db/UserName`, "tresor://db/Password}} admin"},
+		{`{{/* tresor://db/Password isn't replaced */}}tresor://db/UserName`, "admin"},
+		{"{{ if true }}tresor://db/UserName{{ end }}", "admin"},
+	}
+	for _, c := range cases {
+		tmpl, err := parseTemplate("test", c.template, d)
+		if !assert.Nil(err, c.template) {
+			continue
+		}
+		var out bytes.Buffer
+		err = tmpl.Execute(&out, nil)
+		if assert.Nil(err, c.template) {
+			assert.Equal(c.expected, out.String(), c.template)
+		}
+	}
+
+	invalid := []string{"tresor://db", "tresor://db/%zz", "{{ tresor }"}
+	for _, template := range invalid {
+		_, err := parseTemplate("test", template, d)
+		assert.NotNil(err, template)
+	}
+	unresolved := []string{"tresor://db/DoesNotExist", "tresor://Nothing/Password", "tresor://Twin/Password", `{{ tresor "db" "Password" "URL" }}`}
+	for _, template := range unresolved {
+		tmpl, err := parseTemplate("test", template, d)
+		if assert.Nil(err, template) {
+			assert.NotNil(tmpl.Execute(&bytes.Buffer{}, nil), template)
+		}
+	}
+}
+
+func TestWriteTemplate(t *testing.T) {
+	assert := assert.New(t)
+	d := testDocument()
+	path := filepath.Join(t.TempDir(), "out.conf")
+
+	tmpl, err := parseTemplate("test", "pass=tresor://db/Password", d)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Nil(writeTemplate(tmpl, path))
+	content, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal("pass=hunter2", string(content))
+	info, err := os.Stat(path)
+	if assert.Nil(err) {
+		assert.Equal(os.FileMode(INJECT_FILE_MODE), info.Mode().Perm())
+	}
+
+	// A file is left as it was if rendering fails
+	tmpl, err = parseTemplate("test", "pass=tresor://db/DoesNotExist", d)
+	if assert.Nil(err) {
+		assert.NotNil(writeTemplate(tmpl, path))
+	}
+	content, _ = os.ReadFile(path)
+	assert.Equal("pass=hunter2", string(content))
+	entries, _ := os.ReadDir(filepath.Dir(path))
+	assert.Len(entries, 1, "The temporary file must be removed")
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// locate returns all items of the document, in document order, for which match returns true
+func locate(d *parser.Document, match func(l parser.Location) bool) []parser.Location {
+	matches := []parser.Location{}
+	d.Walk(func(l parser.Location) error {
+		if match(l) {
+			matches = append(matches, l)
+		}
+		return nil
+	})
+	return matches
+}
+
+// find returns the group (if group is true) or entry specified by ref, which is either a UUID or a name path.
+// Entries may also be specified by just their title, as long as it is unique.
+func find(d *parser.Document, ref string, group bool) (parser.Location, error) {
+	names := parser.SplitPath(ref)
+	if !group && len(names) == 1 {
+		return selectMatch(d, ref, group, locate(d, func(l parser.Location) bool {
+			return parser.ItemName(l.Item) == names[0]
+		}))
+	}
+	return selectMatch(d, ref, group, d.ResolveAll(ref))
+}
+
+// selectMatch returns the group (if group is true) or entry whose UUID equals ref, if there is one.
+// Otherwise, it returns the only group or entry among matches. It is an error if there is no such item
+// or more than one.
+func selectMatch(d *parser.Document, ref string, group bool, matches []parser.Location) (parser.Location, error) {
+	kind := "entry"
+	if group {
+		kind = "group"
+	}
+	isKind := func(l parser.Location) bool {
+		_, isGroup := l.Item.(parser.Group)
+		return isGroup == group
+	}
+	if byUUID := locate(d, func(l parser.Location) bool { return isKind(l) && l.Item.GetUUID() == ref }); len(byUUID) > 0 {
+		return byUUID[0], nil
+	}
+	filtered := []parser.Location{}
+	for _, l := range matches {
+		if isKind(l) {
+			filtered = append(filtered, l)
+		}
+	}
+
+	switch len(filtered) {
+	case 0:
+		return parser.Location{}, notFoundError{fmt.Sprintf("No such %s: %s", kind, ref)}
+	case 1:
+		return filtered[0], nil
+	default:
+		return parser.Location{}, fmt.Errorf("Ambiguous %s '%s': %d items match, use the UUID instead", kind, ref, len(filtered))
+	}
+}
+
+// findEntry returns the entry specified by its UUID, its name path or its title
+func findEntry(d *parser.Document, ref string) (parser.Entry, error) {
+	l, err := findEntryLocation(d, ref)
+	if err != nil {
+		return parser.Entry{}, err
+	}
+	return l.Item.(parser.Entry), nil
+}
+
+// findEntryLocation is like findEntry, but also returns the path to the entry
+func findEntryLocation(d *parser.Document, ref string) (parser.Location, error) {
+	return find(d, ref, false)
+}
+
+// findGroup returns the group specified by its UUID or name path, along with the path to it
+func findGroup(d *parser.Document, ref string) (parser.Location, error) {
+	return find(d, ref, true)
+}
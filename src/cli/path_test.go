@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEntry(uuid string, fields map[string]string) parser.Entry {
+	e := parser.Entry{UUID: uuid}
+	for key, value := range fields {
+		e.Strings = append(e.Strings, parser.String{Key: key, Value: wrappers.Value{Inner: value, Protected: key == "Password"}})
+	}
+	return e
+}
+
+func testDocument() *parser.Document {
+	d := parser.NewDocument()
+	d.Root.Groups = []parser.Group{{
+		UUID: "AAAAAAAAAAAAAAAAAAAAAA==",
+		Name: "Root",
+		Entries: []parser.Entry{
+			newTestEntry("AQEBAQEBAQEBAQEBAQEBAQ==", map[string]string{"Title": "Twin", "Password": "one"}),
+			newTestEntry("AgICAgICAgICAgICAgICAg==", map[string]string{"Title": "Twin", "Password": "two"}),
+		},
+		Groups: []parser.Group{{
+			UUID: "AwMDAwMDAwMDAwMDAwMDAw==",
+			Name: "Servers",
+			Entries: []parser.Entry{
+				newTestEntry("BAQEBAQEBAQEBAQEBAQEBA==", map[string]string{
+					"Title":    "db",
+					"UserName": "admin",
+					"Password": "hunter2",
+					"URL":      "https://db?p={PASSWORD}",
+				}),
+				newTestEntry("BQUFBQUFBQUFBQUFBQUFBQ==", map[string]string{"Title": "My Server", "Password": "s3cret"}),
+			},
+		}},
+	}}
+	return d
+}
+
+func TestFind(t *testing.T) {
+	assert := assert.New(t)
+	d := testDocument()
+
+	cases := []struct {
+		ref   string
+		group bool
+		// UUID of the expected item, or empty if there is none
+		uuid string
+		// If there is no item, whether because nothing matched rather than because several did
+		notFound bool
+	}{
+		// Entries by UUID, path or title
+		{"BAQEBAQEBAQEBAQEBAQEBA==", false, "BAQEBAQEBAQEBAQEBAQEBA==", false},
+		{"Root/Servers/db", false, "BAQEBAQEBAQEBAQEBAQEBA==", false},
+		{"db", false, "BAQEBAQEBAQEBAQEBAQEBA==", false},
+		{"Root/Servers/My Server", false, "BQUFBQUFBQUFBQUFBQUFBQ==", false},
+		// Entries with the same title can only be told apart by their UUID
+		{"Twin", false, "", false},
+		{"Root/Twin", false, "", false},
+		{"AgICAgICAgICAgICAgICAg==", false, "AgICAgICAgICAgICAgICAg==", false},
+		{"Nothing", false, "", true},
+		{"Root/Servers/Nothing", false, "", true},
+		// Groups aren't entries, and the other way round
+		{"Root/Servers", false, "", true},
+		{"AwMDAwMDAwMDAwMDAwMDAw==", false, "", true},
+		{"Root/Servers", true, "AwMDAwMDAwMDAwMDAwMDAw==", false},
+		{"AwMDAwMDAwMDAwMDAwMDAw==", true, "AwMDAwMDAwMDAwMDAwMDAw==", false},
+		{"Root/Servers/db", true, "", true},
+		{"BAQEBAQEBAQEBAQEBAQEBA==", true, "", true},
+		// Groups can't be specified by just their name
+		{"Servers", true, "", true},
+	}
+	for _, c := range cases {
+		l, err := find(d, c.ref, c.group)
+		if len(c.uuid) > 0 {
+			if assert.Nil(err, c.ref) {
+				assert.Equal(c.uuid, l.Item.GetUUID(), c.ref)
+			}
+			continue
+		}
+		if assert.NotNil(err, c.ref) {
+			_, notFound := err.(notFoundError)
+			assert.Equal(c.notFound, notFound, "%s: %s", c.ref, err)
+		}
+	}
+}
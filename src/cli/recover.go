@@ -0,0 +1,94 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Inserted before the extension of a damaged database to get the path of the recovered one
+const RECOVERED_SUFFIX = ".recovered"
+
+func runRecover(args []string) error {
+	fs := newFlagSet("recover")
+	passwordFD := passwordFlag(fs)
+	outPath := fs.String("o", "", "Path of the recovered database (default: FILE"+RECOVERED_SUFFIX+".kdbx)")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	path, err := fileArg(positional)
+	if err != nil {
+		return err
+	}
+	if len(path) == 0 {
+		return usageError{fmt.Sprintf("No database specified, pass FILE or set $%s", ENV_DATABASE)}
+	}
+	if len(*outPath) == 0 {
+		ext := filepath.Ext(path)
+		*outPath = strings.TrimSuffix(path, ext) + RECOVERED_SUFFIX + ext
+	}
+	// Never overwrite anything, least of all the damaged database
+	if _, err := os.Stat(*outPath); err == nil {
+		return fmt.Errorf("'%s' already exists, choose another path with -o", *outPath)
+	}
+
+	d := database.New(path)
+	err = d.Load()
+	// A truncated file can't be decrypted entirely, but Recover takes care of that
+	if err != nil && !errors.As(err, &database.BlockSizeError{}) {
+		return err
+	}
+	password, err := readPassword(path, *passwordFD)
+	if err != nil {
+		return err
+	}
+	d.SetPassword(password)
+	report, err := d.Recover()
+	if err != nil {
+		return err
+	}
+	err = d.SaveToPath(*outPath)
+	if err != nil {
+		return err
+	}
+	writeRecoveryReport(os.Stdout, *outPath, d.Parsed(), report)
+	return nil
+}
+
+// writeRecoveryReport prints what was recovered to path and what was lost
+func writeRecoveryReport(out io.Writer, path string, d *parser.Document, r database.RecoveryReport) {
+	groups, entries := 0, 0
+	d.Walk(func(l parser.Location) error {
+		if _, isGroup := l.Item.(parser.Group); isGroup {
+			groups++
+		} else {
+			entries++
+		}
+		return nil
+	})
+	fmt.Fprintf(out, "Recovered %d groups and %d entries to %s\n", groups, entries, path)
+	if !r.Damaged() {
+		fmt.Fprintln(out, "No damage found.")
+		return
+	}
+
+	for _, id := range r.DamagedBlocks {
+		fmt.Fprintf(out, "Block %d is damaged, values in it may be garbled\n", id)
+	}
+	if r.ContentErr != nil {
+		fmt.Fprintf(out, "Content after the damage is lost: %s\n", r.ContentErr)
+	}
+	for _, err := range r.Lost {
+		fmt.Fprintf(out, "Lost: %s\n", err)
+	}
+	if len(r.Lost) > 0 {
+		fmt.Fprintln(out, "Protected values, such as passwords, after the first loss may be garbled.")
+	}
+}
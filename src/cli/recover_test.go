@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteRecoveryReport(t *testing.T) {
+	assert := assert.New(t)
+	d := testDocument()
+
+	cases := []struct {
+		report   database.RecoveryReport
+		expected string
+	}{
+		{database.RecoveryReport{}, "Recovered 2 groups and 4 entries to out.kdbx\nNo damage found.\n"},
+		{
+			database.RecoveryReport{DamagedBlocks: []uint32{0, 3}},
+			"Recovered 2 groups and 4 entries to out.kdbx\n" +
+				"Block 0 is damaged, values in it may be garbled\n" +
+				"Block 3 is damaged, values in it may be garbled\n",
+		},
+		{
+			database.RecoveryReport{
+				ContentErr: errors.New("unexpected EOF"),
+				Lost:       []error{errors.New("Entry 'a': bad XML"), errors.New("Group 'b': bad XML")},
+			},
+			"Recovered 2 groups and 4 entries to out.kdbx\n" +
+				"Content after the damage is lost: unexpected EOF\n" +
+				"Lost: Entry 'a': bad XML\n" +
+				"Lost: Group 'b': bad XML\n" +
+				"Protected values, such as passwords, after the first loss may be garbled.\n",
+		},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		writeRecoveryReport(&out, "out.kdbx", d, c.report)
+		assert.Equal(c.expected, out.String())
+	}
+}
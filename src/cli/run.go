@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Replaces secret values in the output of the child process
+const SECRET_MASK = "*****"
+
+// Secrets shorter than this aren't masked, since that would garble the output while hardly hiding anything
+const MIN_MASKED_LENGTH = 4
+
+// exitCodeError is returned when the exit code is decided and the error was reported already, e. g. when a
+// child process exits with a non-zero code, which is passed on
+type exitCodeError struct {
+	code int
+}
+
+func (e exitCodeError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+func runRun(args []string) error {
+	fs := newFlagSet("run")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	var env assignments
+	fs.Var(&env, "env", "Set environment variable NAME to the field at PATH, e. g. DB_PASS=Servers/db/Password (may be repeated)")
+	noMask := fs.Bool("no-mask", false, "Don't mask secret values in the command's output")
+	// Flags after the command belong to it, so parsing stops at the first positional argument
+	err := fs.Parse(args)
+	if err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err.Error()}
+	}
+	if fs.NArg() == 0 {
+		return usageError{"Expected a command"}
+	}
+	if len(env) == 0 {
+		return usageError{"No variables given, use -env NAME=PATH"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	vars := make([]string, 0, len(env))
+	secrets := []string{}
+	for _, e := range env {
+		value, err := resolveFieldPath(d.Parsed(), e.Value.Inner)
+		if err != nil {
+			return err
+		}
+		vars = append(vars, e.Key+"="+value)
+		if len(value) >= MIN_MASKED_LENGTH {
+			secrets = append(secrets, value)
+		}
+	}
+
+	cmd := exec.Command(fs.Arg(0), fs.Args()[1:]...)
+	cmd.Env = append(os.Environ(), vars...)
+	cmd.Stdin = os.Stdin
+	var stdout, stderr *maskingWriter
+	if *noMask {
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+	} else {
+		stdout = newMaskingWriter(os.Stdout, secrets)
+		stderr = newMaskingWriter(os.Stderr, secrets)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+	}
+	return runChild(cmd, stdout, stderr)
+}
+
+// runChild runs cmd, forwarding interrupts to it, and flushes the masking writers once it has exited
+func runChild(cmd *exec.Cmd, writers ...*maskingWriter) error {
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	go func() {
+		for sig := range signals {
+			cmd.Process.Signal(sig)
+		}
+	}()
+
+	err = cmd.Wait()
+	for _, w := range writers {
+		if w != nil {
+			w.Flush()
+		}
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if code < 0 {
+			// Terminated by a signal
+			code = EXIT_ERROR
+		}
+		return exitCodeError{code}
+	}
+	return err
+}
+
+// resolveFieldPath returns the value of the field specified by path, which consists of the path of an
+// entry followed by the field's name, e. g. "Servers/db/Password". References are resolved.
+func resolveFieldPath(d *parser.Document, path string) (string, error) {
+	names := parser.SplitPath(path)
+	if len(names) < 2 {
+		return "", usageError{fmt.Sprintf("Expected the path of an entry followed by a field name, got '%s'", path)}
+	}
+	entry, err := findEntry(d, parser.JoinPath(names[:len(names)-1]))
+	if err != nil {
+		return "", err
+	}
+	value, err := fieldValue(d, &entry, names[len(names)-1], false)
+	if err != nil {
+		return "", err
+	}
+	return value.Inner, nil
+}
+
+// maskingWriter replaces secrets in everything written to it with SECRET_MASK. Where secrets overlap, the
+// whole stretch they cover is replaced by a single mask. Output which might be the beginning of a secret is
+// held back until it is known whether it is, or until Flush is called.
+type maskingWriter struct {
+	out     io.Writer
+	secrets [][]byte
+	mask    []byte
+	buf     []byte
+	mu      sync.Mutex
+}
+
+func newMaskingWriter(out io.Writer, secrets []string) *maskingWriter {
+	w := &maskingWriter{out: out, mask: []byte(SECRET_MASK)}
+	for _, secret := range secrets {
+		if len(secret) > 0 {
+			w.secrets = append(w.secrets, []byte(secret))
+		}
+	}
+	return w
+}
+
+func (w *maskingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	matches := w.matches()
+	// Hold back what might be the beginning of a secret, as well as any secret overlapping it, since it may
+	// turn out to be part of a longer stretch of secrets
+	keep := len(w.buf) - w.partialSecretLength()
+	for _, m := range matches {
+		if m[0] < keep && keep < m[1] {
+			keep = m[0]
+		}
+	}
+	err := w.writeMasked(w.buf[:keep], matches)
+	w.buf = append(w.buf[:0], w.buf[keep:]...)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// matches returns the ranges of the buffer which are covered by secrets, sorted and with overlapping
+// ranges merged
+func (w *maskingWriter) matches() [][2]int {
+	ranges := [][2]int{}
+	for _, secret := range w.secrets {
+		for i := 0; ; {
+			j := bytes.Index(w.buf[i:], secret)
+			if j < 0 {
+				break
+			}
+			ranges = append(ranges, [2]int{i + j, i + j + len(secret)})
+			i += j + 1
+		}
+	}
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+
+	merged := [][2]int{}
+	for _, r := range ranges {
+		if last := len(merged) - 1; last >= 0 && r[0] < merged[last][1] {
+			if r[1] > merged[last][1] {
+				merged[last][1] = r[1]
+			}
+			continue
+		}
+		merged = append(merged, r)
+	}
+	return merged
+}
+
+// writeMasked writes p with the given ranges replaced by the mask. Ranges which don't lie within p are
+// ignored.
+func (w *maskingWriter) writeMasked(p []byte, ranges [][2]int) error {
+	out := make([]byte, 0, len(p))
+	written := 0
+	for _, r := range ranges {
+		if r[1] > len(p) {
+			break
+		}
+		out = append(out, p[written:r[0]]...)
+		out = append(out, w.mask...)
+		written = r[1]
+	}
+	out = append(out, p[written:]...)
+	_, err := w.out.Write(out)
+	return err
+}
+
+// partialSecretLength returns the length of the longest suffix of the buffer which is the beginning of a secret
+func (w *maskingWriter) partialSecretLength() int {
+	longest := 0
+	for _, secret := range w.secrets {
+		for n := len(secret) - 1; n > longest; n-- {
+			if n <= len(w.buf) && bytes.HasSuffix(w.buf, secret[:n]) {
+				longest = n
+				break
+			}
+		}
+	}
+	return longest
+}
+
+// Flush writes any output which was held back, with the secrets in it masked
+func (w *maskingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.writeMasked(w.buf, w.matches())
+	w.buf = w.buf[:0]
+	return err
+}
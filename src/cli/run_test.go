@@ -0,0 +1,65 @@
+package cli
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaskingWriter(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		secrets  []string
+		writes   []string
+		expected string
+	}{
+		{[]string{"hunter2"}, []string{"pw: hunter2\n"}, "pw: *****\n"},
+		{[]string{"hunter2"}, []string{"hunter2hunter2"}, "**********"},
+		// Secrets which are split across writes
+		{[]string{"hunter2"}, []string{"pw: hun", "ter2\n"}, "pw: *****\n"},
+		{[]string{"hunter2"}, []string{"h", "u", "n", "t", "e", "r", "2", "!"}, "*****!"},
+		{[]string{"hunter2"}, []string{"pw: hun", "ted\n"}, "pw: hunted\n"},
+		// Overlapping secrets are masked as a whole, whichever is found first
+		{[]string{"abcd", "bcdefg"}, []string{"xabcdefgx"}, "x*****x"},
+		{[]string{"bcdefg", "abcd"}, []string{"xabcdefgx"}, "x*****x"},
+		{[]string{"abcd", "bcdefg"}, []string{"xabcd", "efgx"}, "x*****x"},
+		{[]string{"abcd", "bcdefg"}, []string{"xab", "cde", "fgx"}, "x*****x"},
+		{[]string{"abcd", "bcdefg"}, []string{"xabcdex"}, "x*****ex"},
+		{[]string{"abcd", "cd12"}, []string{"abcd12abcd"}, "**********"},
+	}
+	for _, c := range cases {
+		var out bytes.Buffer
+		w := newMaskingWriter(&out, c.secrets)
+		for _, p := range c.writes {
+			n, err := w.Write([]byte(p))
+			assert.Nil(err)
+			assert.Equal(len(p), n)
+		}
+		assert.Nil(w.Flush())
+		assert.Equal(c.expected, out.String(), "%q written to %q", c.writes, c.secrets)
+	}
+}
+
+func TestMaskingWriterFlush(t *testing.T) {
+	assert := assert.New(t)
+
+	var out bytes.Buffer
+	w := newMaskingWriter(&out, []string{"abcd", "bcdefg"})
+	w.Write([]byte("x abc"))
+	assert.Equal("x ", out.String(), "The beginning of a secret must be held back")
+	assert.Nil(w.Flush())
+	assert.Equal("x abc", out.String())
+
+	// A secret which might be the beginning of a longer one is held back, but masked when flushed
+	out.Reset()
+	w.Write([]byte("x abcd"))
+	assert.Equal("x ", out.String())
+	assert.Nil(w.Flush())
+	assert.Equal("x *****", out.String())
+
+	out.Reset()
+	assert.Nil(w.Flush())
+	assert.Equal("", out.String())
+}
@@ -0,0 +1,460 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/undo"
+	"golang.org/x/term"
+)
+
+// Separates the arguments of a shell command
+const SHELL_ARG_SEPARATOR = ' '
+
+// lineReader reads the shell's input. It is implemented by term.Terminal and by plainReader.
+type lineReader interface {
+	ReadLine() (string, error)
+	ReadPassword(prompt string) (string, error)
+	SetPrompt(prompt string)
+}
+
+// plainReader reads lines from a non-interactive input, e. g. a pipe
+type plainReader struct {
+	r *bufio.Reader
+}
+
+func (p plainReader) ReadLine() (string, error) {
+	line, err := p.r.ReadString('\n')
+	if err == io.EOF && len(line) > 0 {
+		err = nil
+	}
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func (p plainReader) ReadPassword(_ string) (string, error) {
+	return p.ReadLine()
+}
+
+func (p plainReader) SetPrompt(_ string) {}
+
+// shell is an interactive line-mode interface to a database, for terminals where the TUI can't be used
+type shell struct {
+	database *database.Database
+	undo     undo.UndoManager[parser.Document]
+	// UUIDs of the current group and its parents. If empty, the top-level groups are listed.
+	cwd []string
+	in  lineReader
+	out io.Writer
+	// True if there are unsaved changes
+	modified bool
+	// True if the user was warned about unsaved changes on the last attempt to exit
+	warned bool
+}
+
+type shellCommand struct {
+	name  string
+	usage string
+	help  string
+	run   func(s *shell, args []string) error
+}
+
+var shellCommands []shellCommand
+
+func init() {
+	// Assigned in init, since the help command refers to shellCommands
+	shellCommands = []shellCommand{
+		{"ls", "ls [GROUP]", "List the groups and entries in a group", (*shell).ls},
+		{"cd", "cd [GROUP]", "Change the current group; without argument, go to the top", (*shell).cd},
+		{"pwd", "pwd", "Print the current group", (*shell).pwd},
+		{"show", "show [-reveal] [-raw] ENTRY", "Print all fields of an entry", (*shell).show},
+		{"cp", "cp ENTRY GROUP[/TITLE]", "Copy an entry", (*shell).cp},
+		{"mv", "mv ENTRY GROUP[/TITLE]", "Move or rename an entry", (*shell).mv},
+		{"rm", "rm [-permanent] ENTRY", "Move an entry to the recycle bin, or delete it", (*shell).rm},
+		{"edit", "edit [-set KEY=VALUE]... [-unset KEY]... ENTRY", "Edit an entry; without flags, prompt for each field", (*shell).edit},
+		{"find", "find QUERY", "Search the whole database", (*shell).find},
+		{"undo", "undo", "Undo the last change", (*shell).undoChange},
+		{"redo", "redo", "Redo the last undone change", (*shell).redoChange},
+		{"save", "save", "Save the database", (*shell).save},
+		{"passwd", "passwd", "Change the database password", (*shell).passwd},
+		{"help", "help", "Show this help", (*shell).help},
+		{"exit", "exit", "Leave the shell", nil},
+	}
+}
+
+func runShell(args []string) error {
+	fs := newFlagSet("shell")
+	passwordFD := passwordFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	path, err := fileArg(positional)
+	if err != nil {
+		return err
+	}
+	d, err := openDatabase(path, *passwordFD)
+	if err != nil {
+		return err
+	}
+
+	s := &shell{
+		database: d,
+		undo:     undo.NewUndoManager[parser.Document](),
+		cwd:      []string{},
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		s.in = plainReader{bufio.NewReader(os.Stdin)}
+		s.out = os.Stdout
+		return s.run()
+	}
+
+	oldState, err := term.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer term.Restore(fd, oldState)
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, os.Stdout}, "")
+	if width, height, err := term.GetSize(fd); err == nil {
+		t.SetSize(width, height)
+	}
+	t.AutoCompleteCallback = s.complete
+	s.in = t
+	s.out = t
+	return s.run()
+}
+
+// run reads and executes commands until the user exits
+func (s *shell) run() error {
+	fmt.Fprintf(s.out, "Opened %s. Type 'help' for a list of commands.\n", s.database.Path())
+	for {
+		s.in.SetPrompt(s.prompt())
+		line, err := s.in.ReadLine()
+		if err == io.EOF {
+			fmt.Fprintln(s.out)
+			if s.exit() {
+				return nil
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		args, err := splitArgs(line)
+		if err != nil {
+			fmt.Fprintln(s.out, err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" {
+			if s.exit() {
+				return nil
+			}
+			continue
+		}
+		s.warned = false
+		s.exec(args)
+	}
+}
+
+// exit returns true if the shell may be left. If there are unsaved changes, the user is warned first.
+func (s *shell) exit() bool {
+	if s.modified && !s.warned {
+		s.warned = true
+		fmt.Fprintln(s.out, "There are unsaved changes. Use 'save' to save them, or exit again to discard them.")
+		return false
+	}
+	return true
+}
+
+func (s *shell) exec(args []string) {
+	for _, cmd := range shellCommands {
+		if cmd.name != args[0] || cmd.run == nil {
+			continue
+		}
+		err := cmd.run(s, args[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			fmt.Fprintf(s.out, "Usage: %s\n", cmd.usage)
+		} else if _, ok := err.(usageError); ok {
+			fmt.Fprintf(s.out, "%s\nUsage: %s\n", err, cmd.usage)
+		} else if err != nil {
+			fmt.Fprintf(s.out, "%s: %s\n", cmd.name, err)
+		}
+		return
+	}
+	fmt.Fprintf(s.out, "Unknown command: %s. Type 'help' for a list of commands.\n", args[0])
+}
+
+func (s *shell) document() *parser.Document {
+	return s.database.Parsed()
+}
+
+func (s *shell) prompt() string {
+	modified := ""
+	if s.modified {
+		modified = "*"
+	}
+	return fmt.Sprintf("tresor:%s%s> ", s.cwdPath(), modified)
+}
+
+// cwdNames returns the names of the current group and its parents
+func (s *shell) cwdNames() []string {
+	if len(s.cwd) == 0 {
+		return []string{}
+	}
+	names, found := s.document().PathOf(s.cwd[len(s.cwd)-1])
+	if !found {
+		// The current group doesn't exist anymore
+		s.cwd = []string{}
+		return []string{}
+	}
+	return names
+}
+
+// cwdPath returns the absolute path of the current group
+func (s *shell) cwdPath() string {
+	return string(parser.PATH_SEPARATOR) + parser.JoinPath(s.cwdNames())
+}
+
+// absNames returns the names of the items along a path, which is relative to the current group unless it
+// starts with parser.PATH_SEPARATOR. The special names "." and ".." refer to the current and the parent group.
+func (s *shell) absNames(path string) []string {
+	names := []string{}
+	if len(path) == 0 || path[0] != parser.PATH_SEPARATOR {
+		names = s.cwdNames()
+	}
+	for _, name := range parser.SplitPath(path) {
+		switch name {
+		case ".":
+		case "..":
+			if len(names) > 0 {
+				names = names[:len(names)-1]
+			}
+		default:
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// resolve returns the group (if group is true) or entry at the given path, see absNames.
+// Items may also be specified by their UUID.
+func (s *shell) resolve(path string, group bool) (parser.Location, error) {
+	names := s.absNames(path)
+	if group && len(names) == 0 {
+		return parser.Location{Item: parser.Group{Groups: s.document().Root.Groups}, Path: []string{}, Names: []string{}}, nil
+	}
+	return selectMatch(s.document(), path, group, s.document().ResolveAll(parser.JoinPath(names)))
+}
+
+// resolveDestination returns the group and the title for copying or moving an entry to dest. If dest is a
+// group, title is empty. Otherwise, the last name of dest is the new title, and the rest must be a group.
+func (s *shell) resolveDestination(dest string) (parser.Location, string, error) {
+	if l, err := s.resolve(dest, true); err == nil {
+		if len(l.Path) == 0 {
+			return parser.Location{}, "", errors.New("Entries must be inside a group")
+		}
+		return l, "", nil
+	}
+	names := s.absNames(dest)
+	if len(names) < 2 {
+		return parser.Location{}, "", notFoundError{fmt.Sprintf("No such group: %s", dest)}
+	}
+	l, err := s.resolve(string(parser.PATH_SEPARATOR)+parser.JoinPath(names[:len(names)-1]), true)
+	if err != nil {
+		return parser.Location{}, "", err
+	}
+	return l, names[len(names)-1], nil
+}
+
+// do executes an undoable action on the document
+func (s *shell) do(action undo.Action[parser.Document]) error {
+	_, description, err := s.undo.Do(s.document(), action)
+	if err != nil {
+		return err
+	}
+	s.modified = true
+	fmt.Fprintln(s.out, description)
+	return nil
+}
+
+// splitArgs splits a command line into arguments. Arguments are separated by spaces, unless they are
+// quoted or escaped with a backslash. Other backslashes are kept, since they are part of a path's escaping.
+func splitArgs(line string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	var quote rune
+	escaped := false
+	for _, r := range line {
+		switch {
+		case escaped:
+			if r != SHELL_ARG_SEPARATOR && r != '"' && r != '\'' {
+				current.WriteRune(parser.PATH_ESCAPE)
+			}
+			current.WriteRune(r)
+			escaped = false
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				current.WriteRune(r)
+			}
+		case r == parser.PATH_ESCAPE:
+			escaped = true
+			inArg = true
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == SHELL_ARG_SEPARATOR || r == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(r)
+			inArg = true
+		}
+	}
+	if quote != 0 {
+		return nil, errors.New("Unterminated quote")
+	}
+	if escaped {
+		current.WriteRune(parser.PATH_ESCAPE)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// escapeArg escapes the characters which splitArgs treats specially
+func escapeArg(arg string) string {
+	var b strings.Builder
+	for _, r := range arg {
+		if r == SHELL_ARG_SEPARATOR || r == '"' || r == '\'' {
+			b.WriteRune(parser.PATH_ESCAPE)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// complete is called by the terminal on every key press, and completes command names and paths on tab
+func (s *shell) complete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+	head := line[:pos]
+	start := 0
+	escaped := false
+	for i, r := range head {
+		switch {
+		case escaped:
+			escaped = false
+		case r == parser.PATH_ESCAPE:
+			escaped = true
+		case r == SHELL_ARG_SEPARATOR:
+			start = i + 1
+		}
+	}
+	word := head[start:]
+
+	var dir, prefix string
+	candidates := []string{}
+	if len(strings.TrimSpace(head[:start])) == 0 {
+		prefix = word
+		for _, cmd := range shellCommands {
+			candidates = append(candidates, cmd.name+string(SHELL_ARG_SEPARATOR))
+		}
+	} else {
+		dir, prefix = splitLast(word)
+		candidates = s.pathCandidates(dir)
+	}
+
+	matches := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return "", 0, false
+	}
+	completion := commonPrefix(matches)
+	if len(matches) > 1 && completion == prefix {
+		sort.Strings(matches)
+		fmt.Fprintln(s.out, strings.Join(matches, "  "))
+		return "", 0, false
+	}
+	newHead := head[:start] + dir + completion
+	return newHead + line[pos:], len(newHead), true
+}
+
+// splitLast splits a path after its last unescaped parser.PATH_SEPARATOR
+func splitLast(path string) (string, string) {
+	split := 0
+	escaped := false
+	for i, r := range path {
+		switch {
+		case escaped:
+			escaped = false
+		case r == parser.PATH_ESCAPE:
+			escaped = true
+		case r == parser.PATH_SEPARATOR:
+			split = i + 1
+		}
+	}
+	return path[:split], path[split:]
+}
+
+// pathCandidates returns the escaped names of the items in the group at dir, as they would be typed
+func (s *shell) pathCandidates(dir string) []string {
+	args, err := splitArgs(dir)
+	if err != nil || len(args) > 1 {
+		return []string{}
+	}
+	path := ""
+	if len(args) == 1 {
+		path = args[0]
+	}
+	l, err := s.resolve(path, true)
+	if err != nil {
+		return []string{}
+	}
+	group := l.Item.(parser.Group)
+	candidates := []string{}
+	for _, subgroup := range group.Groups {
+		candidates = append(candidates, escapeArg(parser.EscapeName(subgroup.Name))+string(parser.PATH_SEPARATOR))
+	}
+	for _, entry := range group.Entries {
+		candidates = append(candidates, escapeArg(parser.EscapeName(entry.TryGet("Title", "")))+string(SHELL_ARG_SEPARATOR))
+	}
+	return candidates
+}
+
+func commonPrefix(strs []string) string {
+	prefix := strs[0]
+	for _, s := range strs[1:] {
+		for !strings.HasPrefix(s, prefix) {
+			_, size := utf8.DecodeLastRuneInString(prefix)
+			prefix = prefix[:len(prefix)-size]
+		}
+	}
+	return prefix
+}
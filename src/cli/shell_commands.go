@@ -0,0 +1,323 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/Zaphoood/tresor/src/keepass/search"
+	"github.com/Zaphoood/tresor/src/keepass/undo"
+)
+
+func (s *shell) ls(args []string) error {
+	if len(args) > 1 {
+		return usageError{"Expected at most one group"}
+	}
+	path := ""
+	if len(args) == 1 {
+		path = args[0]
+	}
+	l, err := s.resolve(path, true)
+	if err != nil {
+		return err
+	}
+	group := l.Item.(parser.Group)
+	for _, subgroup := range group.Groups {
+		fmt.Fprintf(s.out, "%s%c\n", parser.EscapeName(subgroup.Name), parser.PATH_SEPARATOR)
+	}
+	for _, entry := range group.Entries {
+		fmt.Fprintln(s.out, parser.EscapeName(entry.TryGet("Title", "")))
+	}
+	return nil
+}
+
+func (s *shell) cd(args []string) error {
+	if len(args) > 1 {
+		return usageError{"Expected at most one group"}
+	}
+	path := string(parser.PATH_SEPARATOR)
+	if len(args) == 1 {
+		path = args[0]
+	}
+	l, err := s.resolve(path, true)
+	if err != nil {
+		return err
+	}
+	s.cwd = l.Path
+	return nil
+}
+
+func (s *shell) pwd(args []string) error {
+	if len(args) > 0 {
+		return usageError{"Expected no arguments"}
+	}
+	fmt.Fprintln(s.out, s.cwdPath())
+	return nil
+}
+
+func (s *shell) show(args []string) error {
+	fs := newFlagSet("show")
+	reveal := fs.Bool("reveal", false, "Show protected values")
+	raw := fs.Bool("raw", false, "Don't resolve field references and placeholders")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+	l, err := s.resolve(positional[0], false)
+	if err != nil {
+		return err
+	}
+	return writeEntry(s.out, s.document(), l, *reveal, *raw)
+}
+
+func (s *shell) cp(args []string) error {
+	if len(args) != 2 {
+		return usageError{"Expected an entry and a destination"}
+	}
+	l, err := s.resolve(args[0], false)
+	if err != nil {
+		return err
+	}
+	dest, title, err := s.resolveDestination(args[1])
+	if err != nil {
+		return err
+	}
+	entry, err := copyEntry(l.Item.(parser.Entry))
+	if err != nil {
+		return err
+	}
+	if len(title) > 0 {
+		entry.SetField("Title", title)
+	}
+	return s.do(undo.NewAddEntryAction(dest.Item.GetUUID(), entry, nil,
+		fmt.Sprintf("Copy '%s' to '%s'", parser.JoinPath(l.Names), parser.JoinPath(append(dest.Names, entry.TryGet("Title", ""))))))
+}
+
+func (s *shell) mv(args []string) error {
+	if len(args) != 2 {
+		return usageError{"Expected an entry and a destination"}
+	}
+	l, err := s.resolve(args[0], false)
+	if err != nil {
+		return err
+	}
+	dest, title, err := s.resolveDestination(args[1])
+	if err != nil {
+		return err
+	}
+	oldEntry := l.Item.(parser.Entry)
+	newEntry := oldEntry
+	actions := []undo.Action[parser.Document]{}
+	if len(title) > 0 && newEntry.SetField("Title", title) {
+		newEntry.Times.LastModificationTime = now()
+		actions = append(actions, undo.NewUpdateEntryAction(newEntry, oldEntry, nil, "Rename"))
+	}
+	actions = append(actions, undo.NewMoveItemAction(l.Path[len(l.Path)-2], dest.Item.GetUUID(), newEntry, nil, "Move"))
+	return s.do(undo.NewSequenceAction(actions, nil,
+		fmt.Sprintf("Move '%s' to '%s'", parser.JoinPath(l.Names), parser.JoinPath(append(dest.Names, newEntry.TryGet("Title", ""))))))
+}
+
+func (s *shell) rm(args []string) error {
+	fs := newFlagSet("rm")
+	permanent := fs.Bool("permanent", false, "Delete the entry instead of moving it to the recycle bin")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+	l, err := s.resolve(positional[0], false)
+	if err != nil {
+		return err
+	}
+	return s.do(removeAction(s.document(), l, *permanent))
+}
+
+func (s *shell) edit(args []string) error {
+	fs := newFlagSet("edit")
+	var set assignments
+	var unset keys
+	fs.Var(&set, "set", "Set field KEY to VALUE")
+	fs.Var(&unset, "unset", "Delete field KEY")
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+	l, err := s.resolve(positional[0], false)
+	if err != nil {
+		return err
+	}
+	oldEntry := l.Item.(parser.Entry)
+	if len(set) == 0 && len(unset) == 0 {
+		set, err = s.promptFields(oldEntry)
+		if err != nil {
+			return err
+		}
+	}
+	newEntry := oldEntry
+	changed, err := applyEdits(&newEntry, set, unset)
+	if err != nil {
+		return err
+	}
+	if !changed {
+		fmt.Fprintln(s.out, "No changes")
+		return nil
+	}
+	return s.do(undo.NewUpdateEntryAction(newEntry, oldEntry, nil, fmt.Sprintf("Edit '%s'", parser.JoinPath(l.Names))))
+}
+
+// promptFields asks for a new value for each of the entry's fields. Entering nothing keeps the current value.
+func (s *shell) promptFields(entry parser.Entry) (assignments, error) {
+	fmt.Fprintln(s.out, "Enter new values, or nothing to keep the current value.")
+	set := assignments{}
+	defer s.in.SetPrompt(s.prompt())
+	for _, key := range fieldKeys(entry) {
+		value, _ := entry.Get(key)
+		var input string
+		var err error
+		if value.Protected {
+			input, err = s.in.ReadPassword(fmt.Sprintf("%s (hidden): ", key))
+			if err == nil && len(input) > 0 {
+				var repeated string
+				repeated, err = s.in.ReadPassword(fmt.Sprintf("Repeat %s: ", key))
+				if err == nil && repeated != input {
+					return nil, fmt.Errorf("Values for '%s' don't match", key)
+				}
+			}
+		} else {
+			s.in.SetPrompt(fmt.Sprintf("%s [%s]: ", key, value.Inner))
+			input, err = s.in.ReadLine()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(input) > 0 {
+			set = append(set, parser.String{Key: key, Value: wrappers.Value{Inner: input}})
+		}
+	}
+	return set, nil
+}
+
+func (s *shell) find(args []string) error {
+	if len(args) == 0 {
+		return usageError{"Expected a query"}
+	}
+	query, err := search.ParseQuery(strings.Join(args, string(SHELL_ARG_SEPARATOR)))
+	if err != nil {
+		return err
+	}
+	results := search.Search(s.document(), query.Match)
+	if len(results) == 0 {
+		fmt.Fprintln(s.out, "No matches")
+		return nil
+	}
+	for _, result := range results {
+		path := string(parser.PATH_SEPARATOR) + parser.JoinPath(append(result.Groups, result.Name()))
+		if _, ok := result.Item.(parser.Group); ok {
+			path += string(parser.PATH_SEPARATOR)
+		}
+		fmt.Fprintln(s.out, path)
+	}
+	return nil
+}
+
+func (s *shell) undoChange(args []string) error {
+	if len(args) > 0 {
+		return usageError{"Expected no arguments"}
+	}
+	_, description, err := s.undo.Undo(s.document())
+	if err != nil {
+		return err
+	}
+	s.modified = true
+	fmt.Fprintf(s.out, "Undo: %s\n", description)
+	return nil
+}
+
+func (s *shell) redoChange(args []string) error {
+	if len(args) > 0 {
+		return usageError{"Expected no arguments"}
+	}
+	_, description, err := s.undo.Redo(s.document())
+	if err != nil {
+		return err
+	}
+	s.modified = true
+	fmt.Fprintf(s.out, "Redo: %s\n", description)
+	return nil
+}
+
+func (s *shell) save(args []string) error {
+	if len(args) > 0 {
+		return usageError{"Expected no arguments"}
+	}
+	err := s.database.Save()
+	if err != nil {
+		return err
+	}
+	s.modified = false
+	fmt.Fprintf(s.out, "Saved %s\n", s.database.Path())
+	return nil
+}
+
+func (s *shell) passwd(args []string) error {
+	if len(args) > 0 {
+		return usageError{"Expected no arguments"}
+	}
+	password, err := s.in.ReadPassword("New password: ")
+	if err != nil {
+		return err
+	}
+	repeated, err := s.in.ReadPassword("Repeat new password: ")
+	if err != nil {
+		return err
+	}
+	if password != repeated {
+		return errors.New("Passwords don't match")
+	}
+	if len(password) == 0 {
+		return errors.New("Password must not be empty")
+	}
+	s.database.SetPassword(password)
+	s.document().Meta.MasterKeyChanged = now()
+	s.modified = true
+	fmt.Fprintln(s.out, "Password changed, use 'save' to apply it")
+	return nil
+}
+
+func (s *shell) help(args []string) error {
+	w := tabwriter.NewWriter(s.out, 0, 0, 2, ' ', 0)
+	for _, cmd := range shellCommands {
+		fmt.Fprintf(w, "%s\t%s\n", cmd.usage, cmd.help)
+	}
+	w.Flush()
+	fmt.Fprintln(s.out, "\nPaths are relative to the current group, unless they start with '/'. Press Tab to complete them.")
+	return nil
+}
+
+// copyEntry returns a copy of entry with a new UUID and without history. Its times, except for the
+// expiry time, are set once it is added.
+func copyEntry(entry parser.Entry) (parser.Entry, error) {
+	uuid, err := parser.NewUUID()
+	if err != nil {
+		return parser.Entry{}, err
+	}
+	entry.UUID = uuid
+	entry.History = nil
+	entry.Times.CreationTime = time.Time{}
+	entry.Times.LastModificationTime = time.Time{}
+	entry.Times.LastAccessTime = time.Time{}
+	entry.Times.LocationChanged = time.Time{}
+	return entry, nil
+}
@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/undo"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitArgs(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		line     string
+		expected []string
+	}{
+		{"", []string{}},
+		{"  ls  ", []string{"ls"}},
+		{"cp a b", []string{"cp", "a", "b"}},
+		{"ls\tgroup", []string{"ls", "group"}},
+		{`cd "My Group"/Sub`, []string{"cd", "My Group/Sub"}},
+		{`cd 'say "hi"'`, []string{"cd", `say "hi"`}},
+		{`show My\ Entry`, []string{"show", "My Entry"}},
+		{`show \"quoted\'`, []string{"show", `"quoted'`}},
+		{`show ""`, []string{"show", ""}},
+		// Other backslashes are kept, since they escape path separators
+		{`show a\/b c\\d`, []string{"show", `a\/b`, `c\\d`}},
+		{`show a\`, []string{"show", `a\`}},
+	}
+	for _, c := range cases {
+		args, err := splitArgs(c.line)
+		if assert.Nil(err, c.line) {
+			assert.Equal(c.expected, args, c.line)
+		}
+	}
+
+	for _, line := range []string{`cd "unterminated`, `cd 'a`} {
+		_, err := splitArgs(line)
+		assert.NotNil(err, line)
+	}
+
+	// Escaped arguments are split into the original ones
+	for _, arg := range []string{"My Entry", `say "hi"`, "it's", `a\/b`} {
+		args, err := splitArgs("show " + escapeArg(arg))
+		if assert.Nil(err, arg) {
+			assert.Equal([]string{"show", arg}, args)
+		}
+	}
+}
+
+func TestComplete(t *testing.T) {
+	assert := assert.New(t)
+	t.Setenv(ENV_PASSWORD, "foo")
+	d, err := openDatabase("../keepass/test/example.kdbx", -1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out bytes.Buffer
+	s := &shell{database: d, undo: undo.NewUndoManager[parser.Document](), cwd: []string{}, out: &out}
+
+	cases := []struct {
+		line     string
+		pos      int
+		expected string
+	}{
+		{"l", 1, "ls "},
+		{"he", 2, "help "},
+		{"cd t", 4, "cd test/"},
+		{"cd test/Ge", 10, "cd test/General/"},
+		{"show test/Em", 12, `show test/Email\ Account `},
+		{"show test/Sample", 16, `show test/Sample\ Entry\ #2 `},
+		{"cd /t", 5, "cd /test/"},
+		// The word before the cursor is completed
+		{"cd t other", 4, "cd test/ other"},
+	}
+	for _, c := range cases {
+		line, pos, ok := s.complete(c.line, c.pos, '\t')
+		if assert.True(ok, c.line) {
+			assert.Equal(c.expected, line, c.line)
+			assert.Equal(len(c.expected)-len(c.line)+c.pos, pos, c.line)
+		}
+	}
+
+	for _, line := range []string{"nope", "cd nope/", "cd test/Nope", `cd "unterminated/`} {
+		_, _, ok := s.complete(line, len(line), '\t')
+		assert.False(ok, line)
+	}
+	_, _, ok := s.complete("l", 1, 'l')
+	assert.False(ok, "Only tab completes")
+
+	// Without a common prefix to add, the candidates are listed
+	out.Reset()
+	_, _, ok = s.complete("s", 1, '\t')
+	assert.False(ok)
+	assert.Equal([]string{"save", "show"}, strings.Fields(out.String()))
+
+	// Paths are relative to the current group
+	cwd, err := s.resolve("test", true)
+	if assert.Nil(err) {
+		s.cwd = cwd.Path
+		line, _, ok := s.complete("cd Int", 6, '\t')
+		assert.True(ok)
+		assert.Equal("cd Internet/", line)
+	}
+}
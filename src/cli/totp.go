@@ -0,0 +1,39 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/otp"
+)
+
+func runTOTP(args []string) error {
+	fs := newFlagSet("totp")
+	dbPath := databaseFlag(fs)
+	passwordFD := passwordFlag(fs)
+	positional, err := parseArgs(fs, args)
+	if err != nil {
+		return err
+	}
+	if len(positional) != 1 {
+		return usageError{"Expected exactly one entry"}
+	}
+
+	d, err := openDatabase(*dbPath, *passwordFD)
+	if err != nil {
+		return err
+	}
+	entry, err := findEntry(d.Parsed(), positional[0])
+	if err != nil {
+		return err
+	}
+	key, err := otp.FromEntry(&entry)
+	if err != nil {
+		return err
+	}
+	if key.Type != otp.TOTP {
+		return fmt.Errorf("Entry uses %s, not TOTP", key.Type)
+	}
+	fmt.Println(key.TOTP(time.Now()))
+	return nil
+}
@@ -0,0 +1,231 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/strength"
+)
+
+// Number of AES-KDF rounds below which the key derivation is considered weak. This is the default of KeePass 2.
+const MIN_TRANSFORM_ROUNDS = 60000
+
+// Passwords whose entry hasn't been modified for longer than this many days are reported
+const DEFAULT_MAX_PASSWORD_AGE_DAYS = 365
+
+const DAY = 24 * time.Hour
+
+type Severity int
+
+const (
+	LOW Severity = iota
+	MEDIUM
+	HIGH
+)
+
+func (s Severity) String() string {
+	switch s {
+	case LOW:
+		return "low"
+	case MEDIUM:
+		return "medium"
+	case HIGH:
+		return "high"
+	default:
+		return fmt.Sprintf("Severity(%d)", int(s))
+	}
+}
+
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+type Check string
+
+const (
+	REUSED_PASSWORD Check = "reused-password"
+	WEAK_PASSWORD   Check = "weak-password"
+	OLD_PASSWORD    Check = "old-password"
+	EXPIRED         Check = "expired"
+	EMPTY_PASSWORD  Check = "empty-password"
+	WEAK_KDF        Check = "weak-kdf"
+	MASTER_KEY_AGE  Check = "master-key-age"
+)
+
+// Finding is a single problem found by the audit. Findings never contain secrets.
+type Finding struct {
+	Check    Check    `json:"check"`
+	Severity Severity `json:"severity"`
+	// UUID of the affected entry, empty for findings concerning the whole database
+	UUID string `json:"uuid,omitempty"`
+	// Path of the affected entry, i. e. the names of its parent groups and its title, see parser.JoinPath
+	Entry   string `json:"entry,omitempty"`
+	Message string `json:"message"`
+}
+
+type MasterKey struct {
+	Changed time.Time `json:"changed"`
+	AgeDays int       `json:"ageDays"`
+	// Number of days after which changing the master key is recommended or forced, -1 if disabled
+	ChangeRecommendedDays int `json:"changeRecommendedDays"`
+	ChangeForcedDays      int `json:"changeForcedDays"`
+}
+
+type Report struct {
+	Generated       time.Time     `json:"generated"`
+	Entries         int           `json:"entries"`
+	TransformRounds uint64        `json:"transformRounds"`
+	MasterKey       MasterKey     `json:"masterKey"`
+	Findings        []Finding     `json:"findings"`
+	Summary         map[Check]int `json:"summary"`
+}
+
+type Options struct {
+	Now                time.Time
+	MaxPasswordAgeDays int
+	MinTransformRounds uint64
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Now:                time.Now(),
+		MaxPasswordAgeDays: DEFAULT_MAX_PASSWORD_AGE_DAYS,
+		MinTransformRounds: MIN_TRANSFORM_ROUNDS,
+	}
+}
+
+type auditedEntry struct {
+	entry    parser.Entry
+	name     string
+	password string
+}
+
+// Run audits a document. Entries in the recycle bin and previous versions of entries are not audited.
+func Run(d *parser.Document, transformRounds uint64, opts Options) Report {
+	entries := collect(d)
+	r := Report{
+		Generated:       opts.Now,
+		Entries:         len(entries),
+		TransformRounds: transformRounds,
+		MasterKey:       masterKey(d, opts.Now),
+		Findings:        []Finding{},
+		Summary:         map[Check]int{},
+	}
+
+	if transformRounds < opts.MinTransformRounds {
+		r.add(Finding{
+			Check:    WEAK_KDF,
+			Severity: MEDIUM,
+			Message:  fmt.Sprintf("Key derivation uses %d AES rounds, at least %d are recommended", transformRounds, opts.MinTransformRounds),
+		})
+	}
+	r.checkMasterKey()
+
+	byPassword := map[string][]*auditedEntry{}
+	for i := range entries {
+		e := &entries[i]
+		if len(e.password) > 0 && !parser.IsReference(e.password) {
+			byPassword[e.password] = append(byPassword[e.password], e)
+		}
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		finding := func(check Check, severity Severity, message string) {
+			r.add(Finding{Check: check, Severity: severity, UUID: e.entry.UUID, Entry: e.name, Message: message})
+		}
+
+		switch {
+		case len(e.password) == 0:
+			finding(EMPTY_PASSWORD, MEDIUM, "Password is empty")
+		case parser.IsReference(e.password):
+			// The referenced entry is audited on its own
+		default:
+			if shared := byPassword[e.password]; len(shared) > 1 {
+				others := make([]string, 0, len(shared)-1)
+				for _, other := range shared {
+					if other != e {
+						others = append(others, other.name)
+					}
+				}
+				finding(REUSED_PASSWORD, HIGH, fmt.Sprintf("Password is also used by %s", strings.Join(others, ", ")))
+			}
+			if bits := strength.Estimate(e.password); bits < strength.WEAK_ENTROPY {
+				finding(WEAK_PASSWORD, HIGH, fmt.Sprintf("Password is weak (estimated strength %.0f bits)", bits))
+			}
+		}
+
+		modified := e.entry.Times.LastModificationTime
+		if !modified.IsZero() && len(e.password) > 0 {
+			if age := days(opts.Now.Sub(modified)); age > opts.MaxPasswordAgeDays {
+				finding(OLD_PASSWORD, LOW, fmt.Sprintf("Not modified for %d days", age))
+			}
+		}
+		if e.entry.Times.Expires.Value() && !e.entry.Times.ExpiryTime.After(opts.Now) {
+			finding(EXPIRED, MEDIUM, fmt.Sprintf("Expired on %s", e.entry.Times.ExpiryTime.Format("2006-01-02")))
+		}
+	}
+
+	// Most severe findings first, otherwise keep document order
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		return r.Findings[i].Severity > r.Findings[j].Severity
+	})
+	return r
+}
+
+func (r *Report) add(f Finding) {
+	r.Findings = append(r.Findings, f)
+	r.Summary[f.Check]++
+}
+
+func (r *Report) checkMasterKey() {
+	key := r.MasterKey
+	if key.Changed.IsZero() {
+		return
+	}
+	if key.ChangeForcedDays > 0 && key.AgeDays > key.ChangeForcedDays {
+		r.add(Finding{
+			Check:    MASTER_KEY_AGE,
+			Severity: HIGH,
+			Message:  fmt.Sprintf("Master key was changed %d days ago, changing it is required every %d days", key.AgeDays, key.ChangeForcedDays),
+		})
+	} else if key.ChangeRecommendedDays > 0 && key.AgeDays > key.ChangeRecommendedDays {
+		r.add(Finding{
+			Check:    MASTER_KEY_AGE,
+			Severity: LOW,
+			Message:  fmt.Sprintf("Master key was changed %d days ago, changing it is recommended every %d days", key.AgeDays, key.ChangeRecommendedDays),
+		})
+	}
+}
+
+func masterKey(d *parser.Document, now time.Time) MasterKey {
+	key := MasterKey{
+		Changed:               d.Meta.MasterKeyChanged,
+		ChangeRecommendedDays: d.Meta.MasterKeyChangeRec,
+		ChangeForcedDays:      d.Meta.MasterKeyChangeForce,
+	}
+	if !key.Changed.IsZero() {
+		key.AgeDays = days(now.Sub(key.Changed))
+	}
+	return key
+}
+
+func collect(d *parser.Document) []auditedEntry {
+	entries := []auditedEntry{}
+	d.WalkEntries(func(l parser.Location, e parser.Entry) {
+		entries = append(entries, auditedEntry{
+			entry:    e,
+			name:     parser.JoinPath(l.Names),
+			password: e.TryGet("Password", ""),
+		})
+	})
+	return entries
+}
+
+func days(d time.Duration) int {
+	return int(d / DAY)
+}
@@ -0,0 +1,120 @@
+package audit
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+const strong = "x7#Kp2!vQ9@mL4zR"
+
+func newEntry(title, password string, times parser.Times) parser.Entry {
+	return parser.Entry{
+		UUID:  title,
+		Times: times,
+		Strings: []parser.String{
+			{Key: "Title", Value: wrappers.Value{Inner: title}},
+			{Key: "Password", Value: wrappers.Value{Inner: password, Protected: true}},
+		},
+	}
+}
+
+func trueBool(t *testing.T) wrappers.Bool {
+	var b wrappers.Bool
+	if err := xml.Unmarshal([]byte("<Expires>True</Expires>"), &b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func testDocument(t *testing.T) *parser.Document {
+	recent := parser.Times{LastModificationTime: now.Add(-DAY)}
+	d := parser.NewDocument()
+	d.Meta.RecycleBinUUID = "bin"
+	d.Meta.MasterKeyChanged = now.Add(-400 * DAY)
+	d.Meta.MasterKeyChangeRec = 365
+	d.Meta.MasterKeyChangeForce = -1
+	d.Root.Groups = []parser.Group{{
+		UUID: "root",
+		Name: "Root",
+		Entries: []parser.Entry{
+			newEntry("ok", strong, recent),
+			newEntry("reused", strong+"1", recent),
+			newEntry("weak", "hunter2", recent),
+			newEntry("empty", "", recent),
+			newEntry("old", strong+"2", parser.Times{LastModificationTime: now.Add(-400 * DAY)}),
+			newEntry("expired", strong+"3", parser.Times{
+				LastModificationTime: now.Add(-DAY),
+				Expires:              trueBool(t),
+				ExpiryTime:           now.Add(-DAY),
+			}),
+			newEntry("reference", "{REF:P@I:0123}", recent),
+		},
+		Groups: []parser.Group{
+			{UUID: "sub", Name: "Su/b", Entries: []parser.Entry{newEntry("reused2", strong+"1", recent)}},
+			{UUID: "bin", Name: "Recycle Bin", Entries: []parser.Entry{newEntry("deleted", "", recent)}},
+		},
+	}}
+	return d
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+	opts := DefaultOptions()
+	opts.Now = now
+	report := Run(testDocument(t), 6000, opts)
+
+	assert.Equal(8, report.Entries)
+	assert.Equal(400, report.MasterKey.AgeDays)
+	assert.Equal(map[Check]int{
+		REUSED_PASSWORD: 2,
+		WEAK_PASSWORD:   1,
+		EMPTY_PASSWORD:  1,
+		OLD_PASSWORD:    1,
+		EXPIRED:         1,
+		WEAK_KDF:        1,
+		MASTER_KEY_AGE:  1,
+	}, report.Summary)
+
+	entries := map[Check][]string{}
+	for _, f := range report.Findings {
+		entries[f.Check] = append(entries[f.Check], f.Entry)
+	}
+	assert.Equal([]string{"Root/reused", `Root/Su\/b/reused2`}, entries[REUSED_PASSWORD])
+	assert.Equal([]string{"Root/weak"}, entries[WEAK_PASSWORD])
+	assert.Equal([]string{"Root/empty"}, entries[EMPTY_PASSWORD])
+	assert.Equal([]string{"Root/old"}, entries[OLD_PASSWORD])
+	assert.Equal([]string{"Root/expired"}, entries[EXPIRED])
+
+	for i := 1; i < len(report.Findings); i++ {
+		assert.GreaterOrEqual(report.Findings[i-1].Severity, report.Findings[i].Severity, "Findings must be sorted by severity")
+	}
+
+	// The master key change isn't due yet if the recommendation is disabled
+	d := testDocument(t)
+	d.Meta.MasterKeyChangeRec = -1
+	report = Run(d, MIN_TRANSFORM_ROUNDS, opts)
+	assert.Zero(report.Summary[MASTER_KEY_AGE])
+	assert.Zero(report.Summary[WEAK_KDF])
+}
+
+func TestNoSecrets(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Now = now
+	report := Run(testDocument(t), 6000, opts)
+	out, err := json.Marshal(report)
+	if !assert.Nil(t, err) {
+		return
+	}
+	for _, secret := range []string{strong, "hunter2"} {
+		assert.NotContains(t, string(out), secret)
+	}
+	assert.Contains(t, string(out), `"severity":"high"`)
+}
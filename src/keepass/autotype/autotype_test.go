@@ -0,0 +1,254 @@
+package autotype
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	PROTECTED_STREAM_KEY = "be3723cc9496ac62a51976df67314e68203140178c1aba143ce6c2441f1068f4"
+)
+
+func parseDecryptedExample(t *testing.T) *parser.Document {
+	key, err := hex.DecodeString(PROTECTED_STREAM_KEY)
+	if err != nil || len(key) != 32 {
+		t.Fatal("Failed to decode hex string")
+	}
+	xmlFile, err := os.Open("../test/example_decrypted.xml")
+	defer xmlFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := ioutil.ReadAll(xmlFile)
+
+	parsed, err := parser.Parse(content, *(*[32]byte)(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed
+}
+
+func testDocument() (*parser.Document, *parser.Entry) {
+	d := parser.NewDocument()
+	d.Root.Groups = []parser.Group{{
+		UUID: "AAAAAAAAAAAAAAAAAAAAAA==",
+		Entries: []parser.Entry{{
+			UUID: "AQEBAQEBAQEBAQEBAQEBAQ==",
+			Strings: []parser.String{
+				{Key: "Title", Value: wrappers.Value{Inner: "Login"}},
+				{Key: "UserName", Value: wrappers.Value{Inner: "alice"}},
+				{Key: "Password", Value: wrappers.Value{Inner: "p+^%~{TAB}(x)", Protected: true}},
+				{Key: "PIN", Value: wrappers.Value{Inner: "1234"}},
+			},
+		}},
+	}}
+	return d, &d.Root.Groups[0].Entries[0]
+}
+
+func TestCompile(t *testing.T) {
+	assert := assert.New(t)
+	d, e := testDocument()
+
+	cases := []struct {
+		sequence string
+		expected []Action
+	}{
+		{"{USERNAME}{TAB}{PASSWORD}{ENTER}", []Action{
+			{Kind: TYPE_TEXT, Text: "alice"},
+			{Kind: PRESS_KEY, Key: "Tab"},
+			{Kind: TYPE_TEXT, Text: "p+^%~{TAB}(x)"},
+			{Kind: PRESS_KEY, Key: "Return"},
+		}},
+		{"^a{DEL}abc~", []Action{
+			{Kind: PRESS_KEY, Key: "a", Modifiers: CTRL},
+			{Kind: PRESS_KEY, Key: "Delete"},
+			{Kind: TYPE_TEXT, Text: "abc"},
+			{Kind: PRESS_KEY, Key: "Return"},
+		}},
+		{"+^{TAB 2}%(ab)", []Action{
+			{Kind: PRESS_KEY, Key: "Tab", Modifiers: SHIFT | CTRL},
+			{Kind: PRESS_KEY, Key: "Tab", Modifiers: SHIFT | CTRL},
+			{Kind: PRESS_KEY, Key: "a", Modifiers: ALT},
+			{Kind: PRESS_KEY, Key: "b", Modifiers: ALT},
+		}},
+		{"{DELAY=50}x{DELAY 200}{S:PIN}", []Action{
+			{Kind: SET_DELAY, Delay: 50 * time.Millisecond},
+			{Kind: TYPE_TEXT, Text: "x"},
+			{Kind: DELAY, Delay: 200 * time.Millisecond},
+			{Kind: TYPE_TEXT, Text: "1234"},
+		}},
+		{"{+}{^}{%}{~}{(}{)}{{}{}}(", []Action{
+			{Kind: TYPE_TEXT, Text: "+^%~(){}("},
+		}},
+		{"{VKEY 13}^{VKEY 0x41}", []Action{
+			{Kind: PRESS_KEY, Key: "Return"},
+			{Kind: PRESS_KEY, Key: "a", Modifiers: CTRL},
+		}},
+		{"^A", []Action{
+			{Kind: PRESS_KEY, Key: "a", Modifiers: CTRL | SHIFT},
+		}},
+	}
+	for _, c := range cases {
+		actions, err := Compile(d, e, c.sequence)
+		if assert.Nil(err, c.sequence) {
+			assert.Equal(c.expected, actions, c.sequence)
+		}
+	}
+
+	actions, err := Compile(d, e, fmt.Sprintf("{TAB %d}{DELAY %d}", MAX_REPEAT, MAX_DELAY_MS))
+	if assert.Nil(err) {
+		assert.Len(actions, MAX_REPEAT+1)
+	}
+
+	invalid := []string{"{TAB", "+(ab", "{UNKNOWN}", "{DELAY x}", "{VKEY 9999}", "{TAB -1}", "abc^",
+		fmt.Sprintf("{TAB %d}", MAX_REPEAT+1), "{TAB 2000000000}",
+		fmt.Sprintf("{DELAY %d}", MAX_DELAY_MS+1), fmt.Sprintf("{DELAY=%d}", MAX_DELAY_MS+1), "{DELAY 9223372036854775807}"}
+	for _, sequence := range invalid {
+		_, err := Compile(d, e, sequence)
+		assert.NotNil(err, sequence)
+	}
+}
+
+func TestRun(t *testing.T) {
+	d, e := testDocument()
+	sink := RecordingSink{}
+	err := Perform(d, e, "{DELAY=10}{USERNAME}{TAB}{DELAY 100}x", &sink)
+	if !assert.Nil(t, err) {
+		return
+	}
+	assert.Equal(t, []Action{
+		{Kind: TYPE_TEXT, Text: "alice"},
+		{Kind: DELAY, Delay: 10 * time.Millisecond},
+		{Kind: PRESS_KEY, Key: "Tab"},
+		{Kind: DELAY, Delay: 10 * time.Millisecond},
+		{Kind: DELAY, Delay: 100 * time.Millisecond},
+		{Kind: TYPE_TEXT, Text: "x"},
+	}, sink.Actions)
+}
+
+func TestSequenceFor(t *testing.T) {
+	assert := assert.New(t)
+	d := parseDecryptedExample(t)
+	root := &d.Root.Groups[0]
+
+	// First entry has an association for 'Target Window'
+	entry := &root.Entries[0]
+	sequence, err := SequenceFor(d, entry, "Target Window")
+	if assert.Nil(err) {
+		assert.Equal("{USERNAME}{TAB}{PASSWORD}{TAB}{ENTER}", sequence)
+	}
+	sequence, err = SequenceFor(d, entry, "Another Window")
+	if assert.Nil(err) {
+		assert.Equal(DEFAULT_SEQUENCE, sequence)
+	}
+
+	// Sequences are inherited from the nearest group which specifies one
+	root.DefaultAutoTypeSequence = "{TITLE}"
+	root.Groups[0].DefaultAutoTypeSequence = "{URL}"
+	nested := &root.Groups[0].Entries[0]
+	sequence, err = SequenceFor(d, nested, "")
+	if assert.Nil(err) {
+		assert.Equal("{URL}", sequence)
+	}
+	sequence, err = SequenceFor(d, entry, "")
+	if assert.Nil(err) {
+		assert.Equal("{TITLE}", sequence)
+	}
+	entry.AutoType.DefaultSequence = "{NOTES}"
+	sequence, err = SequenceFor(d, entry, "")
+	if assert.Nil(err) {
+		assert.Equal("{NOTES}", sequence)
+	}
+
+	// The second entry's association has an empty sequence, so the default is used
+	second := &root.Entries[1]
+	sequence, err = SequenceFor(d, second, "Test Form - KeePass - Firefox")
+	if assert.Nil(err) {
+		assert.Equal("{TITLE}", sequence)
+	}
+
+	matches := MatchingEntries(d, "Test Form - KeePass - Firefox")
+	if assert.Equal(1, len(matches)) {
+		assert.Equal(second.UUID, matches[0].UUID)
+	}
+}
+
+func TestEnabled(t *testing.T) {
+	assert := assert.New(t)
+	d, e := testDocument()
+
+	enabled, err := Enabled(d, e)
+	if assert.Nil(err) {
+		assert.True(enabled)
+	}
+
+	var disabled wrappers.Bool
+	if !assert.Nil(xml.Unmarshal([]byte("<EnableAutoType>False</EnableAutoType>"), &disabled)) {
+		return
+	}
+	d.Root.Groups[0].EnableAutoType = disabled
+	enabled, err = Enabled(d, e)
+	if assert.Nil(err) {
+		assert.False(enabled)
+	}
+	_, err = SequenceFor(d, e, "")
+	assert.IsType(Disabled{}, err)
+}
+
+func TestMatchWindow(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		pattern string
+		title   string
+		matches bool
+	}{
+		{"Target Window", "target window", true},
+		{"Target Window", "Target Window 2", false},
+		{"*Firefox", "GitHub - Mozilla Firefox", true},
+		{"GitHub*", "GitHub - Mozilla Firefox", true},
+		{"*Hub - ?ozilla*", "GitHub - Mozilla Firefox", true},
+		{"*Chrome*", "GitHub - Mozilla Firefox", false},
+		{"//^git.*fire//", "GitHub - Mozilla Firefox", true},
+		{"//^fire//", "GitHub - Mozilla Firefox", false},
+	}
+	for _, c := range cases {
+		assert.Equal(c.matches, MatchWindow(c.pattern, c.title), c.pattern+" / "+c.title)
+	}
+}
+
+func TestExecSink(t *testing.T) {
+	assert := assert.New(t)
+	calls := []string{}
+	stdins := []string{}
+	run := func(name string, stdin string, args ...string) error {
+		calls = append(calls, name+" "+strings.Join(args, " "))
+		stdins = append(stdins, stdin)
+		return nil
+	}
+
+	xdotool := ExecSink{tool: XDOTOOL, run: run}
+	assert.Nil(xdotool.Type("secret"))
+	assert.Nil(xdotool.Key("Tab", CTRL|SHIFT))
+	ydotool := ExecSink{tool: YDOTOOL, run: run}
+	assert.Nil(ydotool.Key("a", CTRL))
+	assert.NotNil(ydotool.Key("ä", 0))
+
+	assert.Equal([]string{
+		"xdotool type --file -",
+		"xdotool key --clearmodifiers ctrl+shift+Tab",
+		"ydotool key 29:1 30:1 30:0 29:0",
+	}, calls)
+	assert.Equal("secret", stdins[0], "Text must be passed via stdin")
+}
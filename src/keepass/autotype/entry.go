@@ -0,0 +1,160 @@
+package autotype
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Sequence used by KeePass if neither the entry nor any of its parent groups specify one
+const DEFAULT_SEQUENCE = "{USERNAME}{TAB}{PASSWORD}{ENTER}"
+
+type Disabled struct {
+	uuid string
+}
+
+func (e Disabled) Error() string {
+	return fmt.Sprintf("Auto-type is disabled for entry '%s'", e.uuid)
+}
+
+// parentGroups returns the groups containing the entry with the given UUID, innermost group first
+func parentGroups(d *parser.Document, uuid string) ([]parser.Group, error) {
+	path, found := d.FindPath(uuid)
+	if !found {
+		return nil, fmt.Errorf("No such entry: %s", uuid)
+	}
+	groups := make([]parser.Group, 0, len(path)-1)
+	for i := len(path) - 1; i > 0; i-- {
+		item, err := d.GetItem(path[:i])
+		if err != nil {
+			return nil, err
+		}
+		group, ok := item.(parser.Group)
+		if !ok {
+			return nil, fmt.Errorf("Expected Group at path %v", path[:i])
+		}
+		groups = append(groups, group)
+	}
+	return groups, nil
+}
+
+// Enabled returns wether auto-type is enabled for an entry. If the entry's parent group
+// doesn't specify this setting, it is inherited from the next group up.
+func Enabled(d *parser.Document, e *parser.Entry) (bool, error) {
+	if e.AutoType.Enabled.IsSet() && !e.AutoType.Enabled.Value() {
+		return false, nil
+	}
+	groups, err := parentGroups(d, e.UUID)
+	if err != nil {
+		return false, err
+	}
+	for _, group := range groups {
+		if group.EnableAutoType.IsSet() {
+			return group.EnableAutoType.Value(), nil
+		}
+	}
+	return true, nil
+}
+
+// SequenceFor returns the sequence to be typed for an entry. If window is not empty and one of the entry's
+// associations matches it, the association's sequence is used. Otherwise the entry's default sequence is
+// used, which is inherited from the nearest parent group that specifies one, and ultimately DEFAULT_SEQUENCE.
+func SequenceFor(d *parser.Document, e *parser.Entry, window string) (string, error) {
+	enabled, err := Enabled(d, e)
+	if err != nil {
+		return "", err
+	}
+	if !enabled {
+		return "", Disabled{e.UUID}
+	}
+
+	if len(window) > 0 {
+		for _, association := range e.AutoType.Associations {
+			if len(association.KeystrokeSequence) > 0 && MatchWindow(association.Window, window) {
+				return association.KeystrokeSequence, nil
+			}
+		}
+	}
+	if len(e.AutoType.DefaultSequence) > 0 {
+		return e.AutoType.DefaultSequence, nil
+	}
+
+	groups, err := parentGroups(d, e.UUID)
+	if err != nil {
+		return "", err
+	}
+	for _, group := range groups {
+		if len(group.DefaultAutoTypeSequence) > 0 {
+			return group.DefaultAutoTypeSequence, nil
+		}
+	}
+	return DEFAULT_SEQUENCE, nil
+}
+
+// MatchWindow checks wether a window title matches the window pattern of an association.
+// As in KeePass, the pattern may contain '*' and '?' wildcards and matching ignores case.
+// Patterns enclosed in '//' are regular expressions.
+func MatchWindow(pattern, title string) bool {
+	if len(pattern) > 4 && strings.HasPrefix(pattern, "//") && strings.HasSuffix(pattern, "//") {
+		re, err := regexp.Compile("(?i)" + pattern[2:len(pattern)-2])
+		if err != nil {
+			return false
+		}
+		return re.MatchString(title)
+	}
+	return matchGlob([]rune(strings.ToLower(pattern)), []rune(strings.ToLower(title)))
+}
+
+func matchGlob(pattern, s []rune) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for i := 0; i <= len(s); i++ {
+				if matchGlob(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+		default:
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+		}
+		pattern = pattern[1:]
+		s = s[1:]
+	}
+	return len(s) == 0
+}
+
+// MatchingEntries returns all entries with auto-type enabled which match the given window title,
+// either because one of their associations matches or because the window title contains their title
+func MatchingEntries(d *parser.Document, window string) []parser.Entry {
+	matches := []parser.Entry{}
+	d.Walk(func(l parser.Location) error {
+		entry, ok := l.Item.(parser.Entry)
+		if !ok || !entryMatchesWindow(&entry, window) {
+			return nil
+		}
+		if enabled, err := Enabled(d, &entry); err == nil && enabled {
+			matches = append(matches, entry)
+		}
+		return nil
+	})
+	return matches
+}
+
+func entryMatchesWindow(e *parser.Entry, window string) bool {
+	for _, association := range e.AutoType.Associations {
+		if MatchWindow(association.Window, window) {
+			return true
+		}
+	}
+	title := strings.ToLower(e.TryGet("Title", ""))
+	return len(title) > 0 && strings.Contains(strings.ToLower(window), title)
+}
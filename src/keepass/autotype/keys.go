@@ -0,0 +1,198 @@
+package autotype
+
+import (
+	"strconv"
+	"strings"
+)
+
+type Modifier int
+
+const (
+	SHIFT Modifier = 1 << iota
+	CTRL
+	ALT
+	SUPER
+)
+
+var modifierNames = []struct {
+	modifier Modifier
+	name     string
+}{
+	{CTRL, "ctrl"},
+	{ALT, "alt"},
+	{SHIFT, "shift"},
+	{SUPER, "super"},
+}
+
+// String returns the modifiers joined by '+', e. g. "ctrl+shift"
+func (m Modifier) String() string {
+	names := []string{}
+	for _, mod := range modifierNames {
+		if m&mod.modifier != 0 {
+			names = append(names, mod.name)
+		}
+	}
+	return strings.Join(names, "+")
+}
+
+// Modifier characters as used in KeePass auto-type sequences
+var modifierChars = map[rune]Modifier{
+	'+': SHIFT,
+	'^': CTRL,
+	'%': ALT,
+	'@': SUPER,
+}
+
+// Special keys which can be used in sequences, e. g. '{TAB}', and their X keysym names
+var specialKeys = map[string]string{
+	"TAB":        "Tab",
+	"ENTER":      "Return",
+	"UP":         "Up",
+	"DOWN":       "Down",
+	"LEFT":       "Left",
+	"RIGHT":      "Right",
+	"HOME":       "Home",
+	"END":        "End",
+	"PGUP":       "Prior",
+	"PGDN":       "Next",
+	"INSERT":     "Insert",
+	"INS":        "Insert",
+	"DELETE":     "Delete",
+	"DEL":        "Delete",
+	"BACKSPACE":  "BackSpace",
+	"BS":         "BackSpace",
+	"BKSP":       "BackSpace",
+	"BREAK":      "Break",
+	"CAPSLOCK":   "Caps_Lock",
+	"ESC":        "Escape",
+	"WIN":        "Super_L",
+	"LWIN":       "Super_L",
+	"RWIN":       "Super_R",
+	"APPS":       "Menu",
+	"HELP":       "Help",
+	"NUMLOCK":    "Num_Lock",
+	"PRTSC":      "Print",
+	"SCROLLLOCK": "Scroll_Lock",
+	"SPACE":      "space",
+	"ADD":        "KP_Add",
+	"SUBTRACT":   "KP_Subtract",
+	"MULTIPLY":   "KP_Multiply",
+	"DIVIDE":     "KP_Divide",
+	"NUMPAD0":    "KP_0",
+	"NUMPAD1":    "KP_1",
+	"NUMPAD2":    "KP_2",
+	"NUMPAD3":    "KP_3",
+	"NUMPAD4":    "KP_4",
+	"NUMPAD5":    "KP_5",
+	"NUMPAD6":    "KP_6",
+	"NUMPAD7":    "KP_7",
+	"NUMPAD8":    "KP_8",
+	"NUMPAD9":    "KP_9",
+	"F1":         "F1",
+	"F2":         "F2",
+	"F3":         "F3",
+	"F4":         "F4",
+	"F5":         "F5",
+	"F6":         "F6",
+	"F7":         "F7",
+	"F8":         "F8",
+	"F9":         "F9",
+	"F10":        "F10",
+	"F11":        "F11",
+	"F12":        "F12",
+	"F13":        "F13",
+	"F14":        "F14",
+	"F15":        "F15",
+	"F16":        "F16",
+}
+
+// Windows virtual key codes, as used by '{VKEY n}', and their X keysym names
+var virtualKeys = map[int]string{
+	0x08: "BackSpace",
+	0x09: "Tab",
+	0x0D: "Return",
+	0x10: "Shift_L",
+	0x11: "Control_L",
+	0x12: "Alt_L",
+	0x13: "Pause",
+	0x14: "Caps_Lock",
+	0x1B: "Escape",
+	0x20: "space",
+	0x21: "Prior",
+	0x22: "Next",
+	0x23: "End",
+	0x24: "Home",
+	0x25: "Left",
+	0x26: "Up",
+	0x27: "Right",
+	0x28: "Down",
+	0x2C: "Print",
+	0x2D: "Insert",
+	0x2E: "Delete",
+	0x2F: "Help",
+	0x5B: "Super_L",
+	0x5C: "Super_R",
+	0x5D: "Menu",
+	0x6A: "KP_Multiply",
+	0x6B: "KP_Add",
+	0x6D: "KP_Subtract",
+	0x6F: "KP_Divide",
+	0x90: "Num_Lock",
+	0x91: "Scroll_Lock",
+}
+
+func init() {
+	// Digits, letters, numpad digits and function keys follow a regular pattern
+	for i := 0; i <= 9; i++ {
+		virtualKeys[0x30+i] = string(rune('0' + i))
+		virtualKeys[0x60+i] = "KP_" + string(rune('0'+i))
+	}
+	for i := 0; i < 26; i++ {
+		virtualKeys[0x41+i] = string(rune('a' + i))
+	}
+	for i := 1; i <= 16; i++ {
+		virtualKeys[0x6F+i] = specialKeys["F"+strconv.Itoa(i)]
+	}
+}
+
+// Linux input event codes of the keys which can be sent, needed by tools such as ydotool
+// which don't understand X keysym names
+var linuxKeyCodes = map[string]int{
+	"Escape": 1, "1": 2, "2": 3, "3": 4, "4": 5, "5": 6, "6": 7, "7": 8, "8": 9, "9": 10, "0": 11,
+	"minus": 12, "equal": 13, "BackSpace": 14, "Tab": 15,
+	"q": 16, "w": 17, "e": 18, "r": 19, "t": 20, "y": 21, "u": 22, "i": 23, "o": 24, "p": 25,
+	"bracketleft": 26, "bracketright": 27, "Return": 28, "Control_L": 29,
+	"a": 30, "s": 31, "d": 32, "f": 33, "g": 34, "h": 35, "j": 36, "k": 37, "l": 38,
+	"semicolon": 39, "apostrophe": 40, "grave": 41, "Shift_L": 42, "backslash": 43,
+	"z": 44, "x": 45, "c": 46, "v": 47, "b": 48, "n": 49, "m": 50,
+	"comma": 51, "period": 52, "slash": 53, "Shift_R": 54, "KP_Multiply": 55, "Alt_L": 56,
+	"space": 57, "Caps_Lock": 58,
+	"F1": 59, "F2": 60, "F3": 61, "F4": 62, "F5": 63, "F6": 64, "F7": 65, "F8": 66, "F9": 67, "F10": 68,
+	"Num_Lock": 69, "Scroll_Lock": 70,
+	"KP_7": 71, "KP_8": 72, "KP_9": 73, "KP_Subtract": 74, "KP_4": 75, "KP_5": 76, "KP_6": 77,
+	"KP_Add": 78, "KP_1": 79, "KP_2": 80, "KP_3": 81, "KP_0": 82,
+	"F11": 87, "F12": 88, "KP_Divide": 98, "Print": 99,
+	"Home": 102, "Up": 103, "Prior": 104, "Left": 105, "Right": 106, "End": 107, "Down": 108,
+	"Next": 109, "Insert": 110, "Delete": 111, "Pause": 119, "Break": 119,
+	"Super_L": 125, "Super_R": 126, "Menu": 127, "Help": 138,
+	"F13": 183, "F14": 184, "F15": 185, "F16": 186,
+}
+
+// Characters which may be combined with modifiers, e. g. '^,', and their X keysym names
+var punctuationKeys = map[rune]string{
+	'-': "minus", '=': "equal", '[': "bracketleft", ']': "bracketright", ';': "semicolon",
+	'\'': "apostrophe", '`': "grave", '\\': "backslash", ',': "comma", '.': "period", '/': "slash",
+	' ': "space",
+}
+
+// keyForChar returns the key name of a single character which is pressed along with modifiers.
+// Upper case letters are pressed as the lower case letter along with shift.
+func keyForChar(c rune, modifiers Modifier) (string, Modifier) {
+	if 'A' <= c && c <= 'Z' {
+		return string(c - 'A' + 'a'), modifiers | SHIFT
+	}
+	if name, ok := punctuationKeys[c]; ok {
+		return name, modifiers
+	}
+	return string(c), modifiers
+}
@@ -0,0 +1,263 @@
+package autotype
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+type ActionKind int
+
+const (
+	// Type a string of text
+	TYPE_TEXT ActionKind = iota
+	// Press a single key along with modifiers
+	PRESS_KEY
+	// Pause for a given duration
+	DELAY
+	// Set the default delay between keystrokes
+	SET_DELAY
+)
+
+// Action is a single step of a compiled auto-type sequence
+type Action struct {
+	Kind      ActionKind
+	Text      string
+	Key       string
+	Modifiers Modifier
+	Delay     time.Duration
+}
+
+func (a Action) String() string {
+	switch a.Kind {
+	case TYPE_TEXT:
+		return fmt.Sprintf("type %q", a.Text)
+	case PRESS_KEY:
+		if a.Modifiers == 0 {
+			return "key " + a.Key
+		}
+		return fmt.Sprintf("key %s+%s", a.Modifiers, a.Key)
+	case DELAY:
+		return fmt.Sprintf("delay %s", a.Delay)
+	case SET_DELAY:
+		return fmt.Sprintf("set delay %s", a.Delay)
+	default:
+		return fmt.Sprintf("ActionKind(%d)", int(a.Kind))
+	}
+}
+
+// Upper bounds for the repeat count of keys such as {TAB 3} and for delays such as {DELAY 500}, which
+// guard against sequences that would allocate huge amounts of actions or never finish
+const (
+	MAX_REPEAT   = 1000
+	MAX_DELAY_MS = 60 * 1000
+)
+
+// Characters which have a special meaning in sequences and have to be wrapped in braces to be typed
+const escapedChars = "+^%@~(){}[]"
+
+// Compile parses a KeePass auto-type sequence such as '{USERNAME}{TAB}{PASSWORD}{ENTER}' into actions.
+// Placeholders and field references are resolved in the context of entry e. Their values are always
+// typed literally, i. e. special characters in a password are not interpreted as key codes.
+func Compile(d *parser.Document, e *parser.Entry, sequence string) ([]Action, error) {
+	c := compiler{document: d, entry: e}
+	err := c.compile([]rune(sequence))
+	if err != nil {
+		return nil, err
+	}
+	c.flushText()
+	return c.actions, nil
+}
+
+type compiler struct {
+	document *parser.Document
+	entry    *parser.Entry
+	actions  []Action
+	// Consecutive characters are collected and typed as a single action
+	text strings.Builder
+}
+
+func (c *compiler) flushText() {
+	if c.text.Len() > 0 {
+		c.actions = append(c.actions, Action{Kind: TYPE_TEXT, Text: c.text.String()})
+		c.text.Reset()
+	}
+}
+
+func (c *compiler) add(action Action) {
+	c.flushText()
+	c.actions = append(c.actions, action)
+}
+
+// addChar types a character, or presses it as a key if modifiers are active
+func (c *compiler) addChar(r rune, modifiers Modifier) {
+	if modifiers == 0 {
+		c.text.WriteRune(r)
+		return
+	}
+	key, modifiers := keyForChar(r, modifiers)
+	c.add(Action{Kind: PRESS_KEY, Key: key, Modifiers: modifiers})
+}
+
+func (c *compiler) compile(seq []rune) error {
+	var modifiers Modifier
+	for i := 0; i < len(seq); i++ {
+		r := seq[i]
+		if mod, ok := modifierChars[r]; ok {
+			modifiers |= mod
+			continue
+		}
+		switch r {
+		case '~':
+			c.add(Action{Kind: PRESS_KEY, Key: "Return", Modifiers: modifiers})
+		case '(':
+			if modifiers == 0 {
+				c.addChar(r, 0)
+				break
+			}
+			// Modifiers apply to all characters in the group
+			end := indexRune(seq, ')', i+1)
+			if end == -1 {
+				return fmt.Errorf("Unclosed '(' at position %d", i)
+			}
+			for _, groupChar := range seq[i+1 : end] {
+				c.addChar(groupChar, modifiers)
+			}
+			i = end
+		case '{':
+			// '{}}' is the escaped closing brace, so start searching after the first character
+			end := indexRune(seq, '}', i+2)
+			if end == -1 {
+				return fmt.Errorf("Unclosed '{' at position %d", i)
+			}
+			err := c.compileBraced(string(seq[i+1:end]), modifiers)
+			if err != nil {
+				return err
+			}
+			i = end
+		default:
+			c.addChar(r, modifiers)
+		}
+		modifiers = 0
+	}
+	if modifiers != 0 {
+		return fmt.Errorf("Modifier at end of sequence")
+	}
+	return nil
+}
+
+// compileBraced compiles the content of a '{...}' token
+func (c *compiler) compileBraced(content string, modifiers Modifier) error {
+	if len([]rune(content)) == 1 && strings.Contains(escapedChars, content) {
+		c.addChar([]rune(content)[0], modifiers)
+		return nil
+	}
+
+	name, arg := content, ""
+	if i := strings.IndexAny(content, " ="); i != -1 {
+		name, arg = content[:i], content[i+1:]
+	}
+	upper := strings.ToUpper(name)
+
+	switch {
+	case upper == "DELAY":
+		ms, err := strconv.Atoi(strings.TrimSpace(arg))
+		if err != nil || ms < 0 || ms > MAX_DELAY_MS {
+			return fmt.Errorf("Invalid delay: {%s}", content)
+		}
+		kind := DELAY
+		if strings.HasPrefix(content[len(name):], "=") {
+			kind = SET_DELAY
+		}
+		c.add(Action{Kind: kind, Delay: time.Duration(ms) * time.Millisecond})
+		return nil
+	case upper == "VKEY":
+		code, err := strconv.ParseInt(strings.TrimSpace(arg), 0, 32)
+		if err != nil {
+			return fmt.Errorf("Invalid virtual key code: {%s}", content)
+		}
+		key, ok := virtualKeys[int(code)]
+		if !ok {
+			return fmt.Errorf("Unsupported virtual key code: {%s}", content)
+		}
+		c.add(Action{Kind: PRESS_KEY, Key: key, Modifiers: modifiers})
+		return nil
+	}
+
+	if key, ok := specialKeys[upper]; ok {
+		repeat := 1
+		if len(arg) > 0 {
+			var err error
+			repeat, err = strconv.Atoi(strings.TrimSpace(arg))
+			if err != nil || repeat < 0 || repeat > MAX_REPEAT {
+				return fmt.Errorf("Invalid repeat count: {%s}", content)
+			}
+		}
+		for j := 0; j < repeat; j++ {
+			c.add(Action{Kind: PRESS_KEY, Key: key, Modifiers: modifiers})
+		}
+		return nil
+	}
+
+	// Anything else must be a placeholder or field reference
+	placeholder := "{" + content + "}"
+	resolved, err := c.document.ResolveValue(c.entry, placeholder)
+	if err != nil {
+		return err
+	}
+	if resolved == placeholder {
+		return fmt.Errorf("Unknown placeholder: %s", placeholder)
+	}
+	c.text.WriteString(resolved)
+	return nil
+}
+
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Run sends the given actions to a sink
+func Run(actions []Action, sink Sink) error {
+	var delay time.Duration
+	for i, action := range actions {
+		var err error
+		switch action.Kind {
+		case TYPE_TEXT:
+			err = sink.Type(action.Text)
+		case PRESS_KEY:
+			err = sink.Key(action.Key, action.Modifiers)
+		case DELAY:
+			err = sink.Delay(action.Delay)
+		case SET_DELAY:
+			delay = action.Delay
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if delay > 0 && i < len(actions)-1 && action.Kind != DELAY {
+			err = sink.Delay(delay)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Perform compiles the given sequence and sends it to a sink
+func Perform(d *parser.Document, e *parser.Entry, sequence string, sink Sink) error {
+	actions, err := Compile(d, e, sequence)
+	if err != nil {
+		return err
+	}
+	return Run(actions, sink)
+}
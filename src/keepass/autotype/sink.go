@@ -0,0 +1,132 @@
+package autotype
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// Sink receives the keystrokes of an auto-type sequence
+type Sink interface {
+	// Type types the given text literally
+	Type(text string) error
+	// Key presses a single key, identified by its X keysym name, along with modifiers
+	Key(key string, modifiers Modifier) error
+	// Delay pauses for the given duration
+	Delay(d time.Duration) error
+}
+
+// RecordingSink records all keystrokes it receives instead of sending them anywhere
+type RecordingSink struct {
+	Actions []Action
+}
+
+func (s *RecordingSink) Type(text string) error {
+	s.Actions = append(s.Actions, Action{Kind: TYPE_TEXT, Text: text})
+	return nil
+}
+
+func (s *RecordingSink) Key(key string, modifiers Modifier) error {
+	s.Actions = append(s.Actions, Action{Kind: PRESS_KEY, Key: key, Modifiers: modifiers})
+	return nil
+}
+
+func (s *RecordingSink) Delay(d time.Duration) error {
+	s.Actions = append(s.Actions, Action{Kind: DELAY, Delay: d})
+	return nil
+}
+
+type Tool string
+
+const (
+	// xdotool works on X11
+	XDOTOOL Tool = "xdotool"
+	// ydotool works on Wayland as well, but requires the ydotoold daemon to be running
+	YDOTOOL Tool = "ydotool"
+)
+
+// ExecSink sends keystrokes by executing an external tool
+type ExecSink struct {
+	tool Tool
+	// Used for executing commands, can be replaced for testing
+	run func(name string, stdin string, args ...string) error
+}
+
+func NewExecSink(tool Tool) (*ExecSink, error) {
+	if tool != XDOTOOL && tool != YDOTOOL {
+		return nil, fmt.Errorf("Unsupported auto-type tool: %s", tool)
+	}
+	if _, err := exec.LookPath(string(tool)); err != nil {
+		return nil, fmt.Errorf("Auto-type tool '%s' not found: %s", tool, err)
+	}
+	return &ExecSink{tool: tool, run: runCommand}, nil
+}
+
+func runCommand(name string, stdin string, args ...string) error {
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s failed: %s (%s)", name, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// Type passes the text via stdin, so that it doesn't show up in the process list
+func (s *ExecSink) Type(text string) error {
+	return s.run(string(s.tool), text, "type", "--file", "-")
+}
+
+func (s *ExecSink) Key(key string, modifiers Modifier) error {
+	switch s.tool {
+	case XDOTOOL:
+		combination := key
+		if modifiers != 0 {
+			combination = modifiers.String() + "+" + key
+		}
+		return s.run(string(s.tool), "", "key", "--clearmodifiers", combination)
+	default:
+		args, err := ydotoolKeyArgs(key, modifiers)
+		if err != nil {
+			return err
+		}
+		return s.run(string(s.tool), "", append([]string{"key"}, args...)...)
+	}
+}
+
+func (s *ExecSink) Delay(d time.Duration) error {
+	time.Sleep(d)
+	return nil
+}
+
+// ydotoolKeyArgs returns the arguments for ydotool, which expects raw key codes along with
+// a state, e. g. '29:1 30:1 30:0 29:0' for pressing and releasing ctrl+a
+func ydotoolKeyArgs(key string, modifiers Modifier) ([]string, error) {
+	modifierKeys := map[Modifier]string{
+		CTRL:  "Control_L",
+		ALT:   "Alt_L",
+		SHIFT: "Shift_L",
+		SUPER: "Super_L",
+	}
+	codes := []int{}
+	for _, mod := range modifierNames {
+		if modifiers&mod.modifier != 0 {
+			codes = append(codes, linuxKeyCodes[modifierKeys[mod.modifier]])
+		}
+	}
+	code, ok := linuxKeyCodes[key]
+	if !ok {
+		return nil, fmt.Errorf("Key '%s' is not supported by %s", key, YDOTOOL)
+	}
+	codes = append(codes, code)
+
+	args := make([]string, 0, 2*len(codes))
+	for _, c := range codes {
+		args = append(args, fmt.Sprintf("%d:1", c))
+	}
+	for i := len(codes) - 1; i >= 0; i-- {
+		args = append(args, fmt.Sprintf("%d:0", codes[i]))
+	}
+	return args, nil
+}
@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/andreburgaud/crypt2go/padding"
+)
+
+// Size of the chunks in which cipher text is read and written, a multiple of the block size
+const CBC_CHUNK_SIZE = 64 * 1024
+
+// CBCReader decrypts cipher text in CBC mode while it is being read and removes the padding at its end.
+// Only one chunk is held in memory at a time.
+type CBCReader struct {
+	r    io.Reader
+	mode cipher.BlockMode
+	// Cipher text which was read from r
+	chunk []byte
+	// Decrypted chunk, preceded by the block which was held back from the previous one
+	buf []byte
+	// Decrypted data which hasn't been read yet
+	out []byte
+	// The last decrypted block is held back, since it contains the padding if it turns out to be the final one
+	held    [aes.BlockSize]byte
+	hasHeld bool
+	eof     bool
+	err     error
+}
+
+// NewCBCReader returns a reader which decrypts the cipher text read from r with the given key and IV
+func NewCBCReader(r io.Reader, key, iv []byte) (*CBCReader, error) {
+	cfr, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, DecryptError{fmt.Errorf("IV length must be %d", aes.BlockSize)}
+	}
+	return &CBCReader{
+		r:     r,
+		mode:  cipher.NewCBCDecrypter(cfr, iv),
+		chunk: make([]byte, CBC_CHUNK_SIZE),
+		buf:   make([]byte, aes.BlockSize+CBC_CHUNK_SIZE),
+	}, nil
+}
+
+// Read reads decrypted data. Malformed cipher text, e. g. with invalid padding, results in a DecryptError.
+func (c *CBCReader) Read(p []byte) (int, error) {
+	for len(c.out) == 0 {
+		if c.err != nil {
+			return 0, c.err
+		}
+		c.fill()
+	}
+	n := copy(p, c.out)
+	c.out = c.out[n:]
+	return n, nil
+}
+
+// fill decrypts the next chunk of cipher text, or removes the padding from the final block once the cipher
+// text has ended
+func (c *CBCReader) fill() {
+	if c.eof {
+		c.err = io.EOF
+		if !c.hasHeld {
+			c.err = DecryptError{errors.New("Cipher text is empty")}
+			return
+		}
+		c.hasHeld = false
+		padder := padding.NewPkcs7Padding(aes.BlockSize)
+		unpadded, err := padder.Unpad(c.held[:])
+		if err != nil {
+			c.err = DecryptError{err}
+			return
+		}
+		c.out = unpadded
+		return
+	}
+
+	n, err := io.ReadFull(c.r, c.chunk)
+	switch {
+	case errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		c.eof = true
+	case err != nil:
+		c.err = err
+		return
+	}
+	if n%aes.BlockSize != 0 {
+		c.err = DecryptError{fmt.Errorf("Cipher text length must be multiple of block size %d", aes.BlockSize)}
+		return
+	}
+	if n == 0 {
+		return
+	}
+
+	c.mode.CryptBlocks(c.buf[aes.BlockSize:aes.BlockSize+n], c.chunk[:n])
+	start := aes.BlockSize
+	if c.hasHeld {
+		copy(c.buf, c.held[:])
+		start = 0
+	}
+	c.out = c.buf[start:n]
+	copy(c.held[:], c.buf[n:aes.BlockSize+n])
+	c.hasHeld = true
+}
+
+// CBCWriter encrypts plain text in CBC mode while it is being written. Close must be called to pad and write
+// the final block.
+type CBCWriter struct {
+	w    io.Writer
+	mode cipher.BlockMode
+	// Plain text which doesn't fill a whole block yet
+	pending []byte
+	chunk   []byte
+}
+
+// NewCBCWriter returns a writer which encrypts everything written to it with the given key and IV and
+// writes the cipher text to w
+func NewCBCWriter(w io.Writer, key, iv []byte) (*CBCWriter, error) {
+	cfr, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, fmt.Errorf("IV length must be %d", aes.BlockSize)
+	}
+	return &CBCWriter{
+		w:       w,
+		mode:    cipher.NewCBCEncrypter(cfr, iv),
+		pending: make([]byte, 0, aes.BlockSize),
+		chunk:   make([]byte, CBC_CHUNK_SIZE),
+	}, nil
+}
+
+func (c *CBCWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	if len(c.pending) > 0 {
+		n := copy(c.pending[len(c.pending):aes.BlockSize], p)
+		c.pending = c.pending[:len(c.pending)+n]
+		p = p[n:]
+		if len(c.pending) < aes.BlockSize {
+			return written, nil
+		}
+		if err := c.encrypt(c.pending); err != nil {
+			return 0, err
+		}
+		c.pending = c.pending[:0]
+	}
+	for len(p) >= aes.BlockSize {
+		n := len(p) / aes.BlockSize * aes.BlockSize
+		if n > len(c.chunk) {
+			n = len(c.chunk)
+		}
+		if err := c.encrypt(p[:n]); err != nil {
+			return 0, err
+		}
+		p = p[n:]
+	}
+	c.pending = append(c.pending, p...)
+	return written, nil
+}
+
+// Close pads the remaining plain text and writes the final block. It doesn't close the underlying writer.
+func (c *CBCWriter) Close() error {
+	padder := padding.NewPkcs7Padding(aes.BlockSize)
+	padded, err := padder.Pad(c.pending)
+	if err != nil {
+		return err
+	}
+	c.pending = c.pending[:0]
+	return c.encrypt(padded)
+}
+
+// encrypt encrypts whole blocks of plain text, at most one chunk, and writes them
+func (c *CBCWriter) encrypt(p []byte) error {
+	c.mode.CryptBlocks(c.chunk[:len(p)], p)
+	n, err := c.w.Write(c.chunk[:len(p)])
+	if err == nil && n < len(p) {
+		err = io.ErrShortWrite
+	}
+	return err
+}
@@ -9,14 +9,33 @@ import (
 	"github.com/andreburgaud/crypt2go/padding"
 )
 
-type DecryptError error
+// DecryptError is returned if decrypted data is malformed, e. g. because its padding is invalid
+type DecryptError struct {
+	Err error
+}
+
+func (e DecryptError) Error() string {
+	return e.Err.Error()
+}
+
+func (e DecryptError) Unwrap() error {
+	return e.Err
+}
+
+// CompositeKey is the hash of all credentials a database is encrypted with
+type CompositeKey [sha256.Size]byte
+
+// PasswordKey returns the composite key of a database which is protected by just a password
+func PasswordKey(password string) CompositeKey {
+	passwordHash := sha256.Sum256([]byte(password))
+	return sha256.Sum256(passwordHash[:])
+}
 
 func GenerateMasterKey(password string, masterSeed, transformSeed []byte, transformRounds uint64) ([]byte, error) {
-	// Generate composite key
-	compositeKey := sha256.Sum256([]byte(password))
-	compositeKey = sha256.Sum256(compositeKey[:])
+	return GenerateMasterKeyFromComposite(PasswordKey(password), masterSeed, transformSeed, transformRounds)
+}
 
-	// Generate master key
+func GenerateMasterKeyFromComposite(compositeKey CompositeKey, masterSeed, transformSeed []byte, transformRounds uint64) ([]byte, error) {
 	transformOut, err := AESRounds(compositeKey[:], transformSeed, transformRounds)
 	if err != nil {
 		return nil, err
@@ -47,24 +66,40 @@ func AESRounds(in, seed []byte, rounds uint64) ([]byte, error) {
 	return out, nil
 }
 
+// DecryptAES decrypts ciphertext in CBC mode and removes the padding
 func DecryptAES(ciphertext, key, iv []byte) ([]byte, error) {
-	cfr, err := aes.NewCipher(key)
+	plaintext, err := DecryptCBC(ciphertext, key, iv)
 	if err != nil {
 		return nil, err
 	}
-	plaintext := make([]byte, len(ciphertext))
-	mode := cipher.NewCBCDecrypter(cfr, iv)
-	mode.CryptBlocks(plaintext, ciphertext)
-
 	padder := padding.NewPkcs7Padding(aes.BlockSize)
 	unpadded, err := padder.Unpad(plaintext)
 	if err != nil {
-		return nil, DecryptError(err)
+		return nil, DecryptError{err}
 	} else {
 		return unpadded, nil
 	}
 }
 
+// DecryptCBC decrypts ciphertext in CBC mode without removing the padding. This allows decrypting just
+// the first blocks of a longer cipher text.
+func DecryptCBC(ciphertext, key, iv []byte) ([]byte, error) {
+	cfr, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext)%aes.BlockSize != 0 {
+		return nil, DecryptError{fmt.Errorf("Cipher text length must be multiple of block size %d", aes.BlockSize)}
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, DecryptError{fmt.Errorf("IV length must be %d", aes.BlockSize)}
+	}
+	plaintext := make([]byte, len(ciphertext))
+	mode := cipher.NewCBCDecrypter(cfr, iv)
+	mode.CryptBlocks(plaintext, ciphertext)
+	return plaintext, nil
+}
+
 func EncryptAES(plaintext, key, iv []byte) ([]byte, error) {
 	cfr, err := aes.NewCipher(key)
 	if err != nil {
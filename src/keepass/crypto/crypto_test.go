@@ -1,7 +1,9 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/rand"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -39,6 +41,61 @@ func TestCrypt(t *testing.T) {
 	}
 }
 
+func TestCBCStream(t *testing.T) {
+	assert := assert.New(t)
+
+	key := make([]byte, 32)
+	iv := make([]byte, 16)
+	rand.Read(key)
+	rand.Read(iv)
+
+	for _, size := range []int{0, 1, 15, 16, 17, CBC_CHUNK_SIZE - 16, CBC_CHUNK_SIZE, 3*CBC_CHUNK_SIZE + 5} {
+		plaintext := make([]byte, size)
+		rand.Read(plaintext)
+		expected, err := EncryptAES(plaintext, key, iv)
+		if !assert.Nil(err) {
+			return
+		}
+
+		var encrypted bytes.Buffer
+		w, err := NewCBCWriter(&encrypted, key, iv)
+		if !assert.Nil(err) {
+			return
+		}
+		// Write in odd pieces, so that they don't line up with the blocks
+		for rest := plaintext; len(rest) > 0; {
+			n := 7
+			if n > len(rest) {
+				n = len(rest)
+			}
+			w.Write(rest[:n])
+			rest = rest[n:]
+		}
+		assert.Nil(w.Close())
+		assert.Equal(expected, encrypted.Bytes(), "size %d", size)
+
+		r, err := NewCBCReader(bytes.NewReader(expected), key, iv)
+		if !assert.Nil(err) {
+			return
+		}
+		decrypted, err := io.ReadAll(r)
+		assert.Nil(err, "size %d", size)
+		assert.True(bytes.Equal(plaintext, decrypted), "size %d", size)
+	}
+
+	// Invalid padding and a length which isn't a multiple of the block size are errors
+	ciphertext, _ := EncryptAES([]byte("foo"), key, iv)
+	// The padding is 13 bytes of 0x0d, decrypting with this IV changes the last one to 0x20
+	otherIV := append([]byte{}, iv...)
+	otherIV[15] ^= 0x0d ^ 0x20
+	r, _ := NewCBCReader(bytes.NewReader(ciphertext), key, otherIV)
+	_, err := io.ReadAll(r)
+	assert.ErrorAs(err, &DecryptError{})
+	r, _ = NewCBCReader(bytes.NewReader(ciphertext[:10]), key, iv)
+	_, err = io.ReadAll(r)
+	assert.ErrorAs(err, &DecryptError{})
+}
+
 func TestGenerateMasterKey(t *testing.T) {
 	assert := assert.New(t)
 
@@ -1,30 +1,82 @@
 package crypto
 
 import (
-	"golang.org/x/crypto/salsa20"
+	"encoding/binary"
+
+	"golang.org/x/crypto/salsa20/salsa"
 )
 
 var SALSA20_NONCE []byte = []byte{0xE8, 0x30, 0x09, 0x4B, 0x97, 0x20, 0x5D, 0x2A}
 
+// Size of the blocks the Salsa20 key stream is generated in
+const SALSA20_BLOCK_SIZE = 64
+
+// Salsa20Stream encrypts and decrypts values with a single Salsa20 key stream, like KeePass does for
+// protected values. It keeps track of its position in the key stream, so each call only generates the key
+// stream for its own input.
 type Salsa20Stream struct {
-	accum []byte
-	nonce []byte
-	key   [32]byte
+	key [32]byte
+	// Nonce, followed by the number of the next block of key stream as a little-endian integer
+	counter [16]byte
+	// The last block of key stream which was generated, of which the first used bytes were consumed already
+	block [SALSA20_BLOCK_SIZE]byte
+	used  int
 }
 
 func NewSalsa20Stream(key [32]byte) *Salsa20Stream {
-	return &Salsa20Stream{nonce: SALSA20_NONCE, key: key}
+	s := &Salsa20Stream{key: key, used: SALSA20_BLOCK_SIZE}
+	copy(s.counter[:], SALSA20_NONCE)
+	return s
 }
 
 func (s *Salsa20Stream) Decrypt(ciphertext []byte) ([]byte, error) {
-	s.accum = append(s.accum, ciphertext...)
-	out := make([]byte, len(s.accum))
-	salsa20.XORKeyStream(out, s.accum, s.nonce, &s.key)
+	out := make([]byte, len(ciphertext))
+	in, rest := ciphertext, out
+
+	// Use up what is left of the current block
+	n := s.xorBlock(rest, in)
+	in, rest = in[n:], rest[n:]
+
+	// Whole blocks are XORed directly
+	if whole := len(in) / SALSA20_BLOCK_SIZE * SALSA20_BLOCK_SIZE; whole > 0 {
+		salsa.XORKeyStream(rest[:whole], in[:whole], &s.counter, &s.key)
+		s.advance(whole / SALSA20_BLOCK_SIZE)
+		in, rest = in[whole:], rest[whole:]
+	}
+
+	// Generate another block for the rest, which is kept for the next call
+	if len(in) > 0 {
+		s.block = [SALSA20_BLOCK_SIZE]byte{}
+		salsa.XORKeyStream(s.block[:], s.block[:], &s.counter, &s.key)
+		s.advance(1)
+		s.used = 0
+		s.xorBlock(rest, in)
+	}
 
-	return out[len(out)-len(ciphertext):], nil
+	return out, nil
 }
 
 // Encrypt encrypts a given bytearray. The operation is the same as decrypting
 func (s *Salsa20Stream) Encrypt(plaintext []byte) ([]byte, error) {
 	return s.Decrypt(plaintext)
 }
+
+// xorBlock XORs in with what is left of the current block of key stream and writes the result to out. It
+// returns the number of bytes written, which is less than len(in) if the block is used up.
+func (s *Salsa20Stream) xorBlock(out, in []byte) int {
+	n := len(in)
+	if left := SALSA20_BLOCK_SIZE - s.used; n > left {
+		n = left
+	}
+	for i := 0; i < n; i++ {
+		out[i] = in[i] ^ s.block[s.used+i]
+	}
+	s.used += n
+	return n
+}
+
+// advance moves the key stream forward by the given number of blocks
+func (s *Salsa20Stream) advance(blocks int) {
+	n := binary.LittleEndian.Uint64(s.counter[8:])
+	binary.LittleEndian.PutUint64(s.counter[8:], n+uint64(blocks))
+}
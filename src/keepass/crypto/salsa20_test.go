@@ -0,0 +1,72 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/salsa20"
+)
+
+// xorAll encrypts all values as one stream in a single call, which is what the stream must be equivalent to
+func xorAll(key [32]byte, values [][]byte) [][]byte {
+	joined := bytes.Join(values, nil)
+	salsa20.XORKeyStream(joined, joined, SALSA20_NONCE, &key)
+	out := make([][]byte, len(values))
+	for i, value := range values {
+		out[i], joined = joined[:len(value)], joined[len(value):]
+	}
+	return out
+}
+
+func TestSalsa20Stream(t *testing.T) {
+	assert := assert.New(t)
+
+	var key [32]byte
+	rand.Read(key[:])
+	// Values which end in the middle of a block, at its end and span several blocks
+	sizes := []int{0, 1, 5, 63, 64, 1, 64, 65, 127, 200, 3, 0, 1000, 64 * 40, 7}
+	values := make([][]byte, len(sizes))
+	for i, size := range sizes {
+		values[i] = make([]byte, size)
+		rand.Read(values[i])
+	}
+	expected := xorAll(key, values)
+
+	s := NewSalsa20Stream(key)
+	for i, value := range values {
+		out, err := s.Encrypt(value)
+		assert.Nil(err)
+		assert.Equal(expected[i], out, "value %d of %d bytes", i, len(value))
+	}
+
+	// Decrypting is the same operation, and the input is left alone
+	s = NewSalsa20Stream(key)
+	for i, value := range expected {
+		original := append([]byte{}, value...)
+		out, err := s.Decrypt(value)
+		assert.Nil(err)
+		assert.Equal(values[i], out, "value %d of %d bytes", i, len(value))
+		assert.Equal(original, value)
+	}
+}
+
+func BenchmarkSalsa20Stream(b *testing.B) {
+	var key [32]byte
+	for _, count := range []int{100, 1000, 10000} {
+		b.Run(fmt.Sprintf("%d values", count), func(b *testing.B) {
+			// Roughly the size of a password
+			value := make([]byte, 20)
+			b.SetBytes(int64(count * len(value)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s := NewSalsa20Stream(key)
+				for j := 0; j < count; j++ {
+					s.Decrypt(value)
+				}
+			}
+		})
+	}
+}
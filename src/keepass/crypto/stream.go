@@ -1,5 +1,7 @@
 package crypto
 
+// Stream encrypts or decrypts the protected values of a database. All values share one key stream, so they
+// must be passed in document order; implementations keep their position in it between calls.
 type Stream interface {
 	Decrypt(in []byte) (out []byte, err error)
 	Encrypt(in []byte) (out []byte, err error)
@@ -5,12 +5,12 @@ import (
 	"crypto/aes"
 	"crypto/sha256"
 	"encoding/base64"
-	"encoding/binary"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"math"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
 
 	"github.com/Zaphoood/tresor/src/keepass/crypto"
 	"github.com/Zaphoood/tresor/src/keepass/parser"
@@ -23,30 +23,21 @@ const (
 	QWORD = 8
 )
 
-type block struct {
-	start  int
-	length int
-}
-
-type FileError error
+// Maximum size of the decompressed content, which guards against compression bombs. Real databases are
+// orders of magnitude smaller.
+const MAX_CONTENT_SIZE = 1 << 30
 
-type ParseError error
-
-type BlockSizeError struct {
-	expectedBlockSize int
-}
-
-func (e BlockSizeError) Error() string {
-	return fmt.Sprintf("Invalid cipher text: length must be multiple of block size %d", e.expectedBlockSize)
-}
+// Permissions of databases which are saved to a new file
+const NEW_FILE_MODE fs.FileMode = 0600
 
 type Database struct {
-	path     string
-	password string
-	header   header
+	path   string
+	key    crypto.CompositeKey
+	header header
 
+	// Encrypted content, if it was loaded by LoadFrom. Load leaves it empty, and the content is read from the
+	// file again when decrypting, so that it doesn't need to be held in memory.
 	ciphertext []byte
-	plaintext  []byte
 	parsed     *parser.Document
 }
 
@@ -61,11 +52,12 @@ func (d Database) Path() string {
 }
 
 func (d *Database) SetPassword(password string) {
-	d.password = password
+	d.key = crypto.PasswordKey(password)
 }
 
-func (d Database) Plaintext() []byte {
-	return d.plaintext
+// SetKey sets the composite key used for decrypting and saving the database
+func (d *Database) SetKey(key crypto.CompositeKey) {
+	d.key = key
 }
 
 func (d Database) Parsed() *parser.Document {
@@ -76,165 +68,183 @@ func (d Database) Version() version {
 	return d.header.version
 }
 
+// TransformRounds returns the number of AES-KDF rounds used for deriving the master key
+func (d Database) TransformRounds() uint64 {
+	return d.header.transformRounds
+}
+
+// Load reads the header of the encrypted database from its path. The content is only read when decrypting,
+// so the file mustn't be changed in the meantime.
 func (d *Database) Load() error {
 	f, err := os.Open(d.path)
+	if err != nil {
+		return err
+	}
 	defer f.Close()
+	err = d.readHeader(f)
 	if err != nil {
 		return err
 	}
+	d.ciphertext = nil
 
-	err = d.header.read(f)
+	// Check the content's length right away, like LoadFrom does
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return FileError{err}
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return FileError{err}
+	}
+	if (info.Size()-offset)%aes.BlockSize != 0 {
+		return FileError{BlockSizeError{aes.BlockSize}}
+	}
+	return nil
+}
+
+// LoadFrom reads the encrypted database from r, until its end. Unlike Load, it holds the encrypted content
+// in memory; DecryptFrom avoids that if the key is known already.
+func (d *Database) LoadFrom(r io.Reader) error {
+	err := d.readHeader(r)
 	if err != nil {
 		return err
 	}
 
-	d.ciphertext, err = ioutil.ReadAll(f)
+	d.ciphertext, err = io.ReadAll(r)
 	if err != nil {
-		return FileError(fmt.Errorf("Error while reading database content: %s", err))
+		return FileError{fmt.Errorf("Error while reading database content: %w", err)}
 	}
 
 	if len(d.ciphertext)%aes.BlockSize != 0 {
-		return FileError(BlockSizeError{aes.BlockSize})
+		return FileError{BlockSizeError{aes.BlockSize}}
 	}
 
 	return nil
 }
 
+func (d *Database) readHeader(r io.Reader) error {
+	err := d.header.read(r)
+	if err != nil && !errors.As(err, &FileError{}) {
+		err = FileError{err}
+	}
+	return err
+}
+
+// Decrypt decrypts and parses the database's content in a single pass, so that neither the decrypted
+// content nor the XML is held in memory. It returns ErrWrongKey if the key is incorrect.
 func (d *Database) Decrypt() error {
-	masterKey, err := crypto.GenerateMasterKey(d.password, d.header.masterSeed, d.header.transformSeed, d.header.transformRounds)
+	content, err := d.openContent()
 	if err != nil {
 		return err
 	}
+	defer content.Close()
+	return d.decrypt(content)
+}
 
-	plaintext, err := crypto.DecryptAES(d.ciphertext, masterKey, d.header.encryptionIV)
+// DecryptFrom reads the database from r, until its end, and decrypts it like Decrypt. Unlike LoadFrom, it
+// doesn't hold the encrypted content in memory. The key must be set before.
+func (d *Database) DecryptFrom(r io.Reader) error {
+	err := d.readHeader(r)
 	if err != nil {
 		return err
 	}
+	d.ciphertext = nil
+	return d.decrypt(r)
+}
 
-	type foo struct {
-		bar int
+// openContent returns a reader for the encrypted content. If it was loaded from a file, the file is opened
+// again and its header is skipped.
+func (d *Database) openContent() (io.ReadCloser, error) {
+	if d.ciphertext != nil {
+		return io.NopCloser(bytes.NewReader(d.ciphertext)), nil
 	}
-
-	if !d.checkStreamStartBytesAndTrim(&plaintext) {
-		return crypto.DecryptError(errors.New("Stream start bytes don't match"))
+	f, err := os.Open(d.path)
+	if err != nil {
+		return nil, err
+	}
+	var h header
+	err = h.read(f)
+	if err == nil && h.hashOfRead != d.header.hashOfRead {
+		err = errors.New("File was modified since it was loaded")
+	}
+	if err != nil {
+		f.Close()
+		return nil, FileError{err}
 	}
+	return f, nil
+}
 
-	plaintext, err = parseBlocks(plaintext)
+// decrypt decrypts, decompresses and parses the encrypted content while it is being read from r
+func (d *Database) decrypt(r io.Reader) error {
+	d.parsed = nil
+	masterKey, err := d.masterKey()
 	if err != nil {
 		return err
 	}
-	d.plaintext = plaintext
+	counter := &countingReader{r: r}
+	plain, err := crypto.NewCBCReader(counter, masterKey, d.header.encryptionIV)
+	if err != nil {
+		return corrupted("%s", err)
+	}
 
-	if d.header.compression {
-		d.plaintext, err = util.GUnzip(d.plaintext)
-		if err != nil {
-			return err
-		}
+	// The content starts with the stream start bytes, which tell a wrong key apart from corrupted content
+	start := make([]byte, len(d.header.streamStartBytes))
+	_, err = io.ReadFull(plain, start)
+	if err != nil && counter.n <= int64(len(start)) {
+		return FileError{fmt.Errorf("%w: content is shorter than the stream start bytes", ErrTruncated)}
+	}
+	if err == nil {
+		err = d.checkStartBytes(start)
+	}
+	if err != nil {
+		return contentError(err, counter.n)
 	}
 
+	content, err := contentReader(plain, d.header.compression, MAX_CONTENT_SIZE)
+	if err == nil {
+		d.parsed, err = parser.Decode(content, sha256.Sum256(d.header.innerRandomStreamKey))
+	}
+	// Read what follows the XML, so that the remaining blocks and the padding are verified as well
+	if err == nil {
+		_, err = io.Copy(io.Discard, content)
+	}
+	if err == nil {
+		_, err = io.Copy(io.Discard, plain)
+	}
+	if err != nil {
+		d.parsed = nil
+		return contentError(err, counter.n)
+	}
 	return nil
 }
 
-func (d *Database) checkStreamStartBytesAndTrim(plaintext *[]byte) bool {
-	ok := bytes.Equal(d.header.streamStartBytes, (*plaintext)[:len(d.header.streamStartBytes)])
-	*plaintext = (*plaintext)[len(d.header.streamStartBytes):]
-	return ok
+// masterKey derives the key the content is encrypted with
+func (d *Database) masterKey() ([]byte, error) {
+	return crypto.GenerateMasterKeyFromComposite(d.key, d.header.masterSeed, d.header.transformSeed, d.header.transformRounds)
 }
 
-func parseBlocks(plainBlocks []byte) ([]byte, error) {
-	in := bytes.NewReader(plainBlocks)
-	var out bytes.Buffer
-	blockCounter := uint32(0)
-	for {
-		buf := make([]byte, DWORD)
-		err := util.ReadAssert(in, buf)
-		if err != nil {
-			return nil, err
-		}
-		blockID := binary.LittleEndian.Uint32(buf)
-		if blockID != blockCounter {
-			return nil, ParseError(fmt.Errorf("Invalid block ID: %d", blockID))
-		}
-		blockCounter++
-
-		storedHash := make([]byte, sha256.Size)
-		util.ReadAssert(in, storedHash)
-
-		buf = make([]byte, DWORD)
-		err = util.ReadAssert(in, buf)
-		if err != nil {
-			return nil, err
-		}
-		blockSize := int(binary.LittleEndian.Uint32(buf))
-		if blockSize == 0 {
-			for _, b := range storedHash {
-				if b != 0 {
-					return nil, errors.New("Hash of final block must be zero")
-				}
-			}
-			break
-		}
-
-		content := make([]byte, blockSize)
-		util.ReadAssert(in, content)
-
-		hash := sha256.Sum256(content)
-		if !bytes.Equal(storedHash, hash[:]) {
-			return nil, ParseError(errors.New("Block hash does not match. File may be corrupted"))
-		}
-		out.Write(content)
+// checkStartBytes returns ErrWrongKey if the start of the decrypted content differs from the stream start
+// bytes
+func (d *Database) checkStartBytes(start []byte) error {
+	if !bytes.Equal(start, d.header.streamStartBytes) {
+		return ErrWrongKey
 	}
-
-	return out.Bytes(), nil
-}
-
-// formatBlocks formats the given byte array into blocks as per the kdbx file standard
-func formatBlocks(in []byte) ([]byte, error) {
-	var outBuf bytes.Buffer
-
-	index := 0
-	blockID := 0
-	for {
-		blockLength := len(in) - index
-		if blockLength > math.MaxInt32 {
-			blockLength = math.MaxInt32
-		}
-		block := in[index : index+blockLength]
-		var hash [32]byte
-		if index < len(in) {
-			hash = sha256.Sum256(block)
-		} else {
-			// Last block's hash is all zeros, no need to do anything
-		}
-		blockIDBuf := make([]byte, DWORD)
-		binary.LittleEndian.PutUint32(blockIDBuf, uint32(blockID))
-		lengthBuf := make([]byte, DWORD)
-		binary.LittleEndian.PutUint32(lengthBuf, uint32(blockLength))
-
-		outBuf.Write(blockIDBuf)
-		outBuf.Write(hash[:])
-		outBuf.Write(lengthBuf[:])
-		outBuf.Write(block)
-
-		if index >= len(in) {
-			break
-		}
-
-		index += blockLength
-		blockID++
-	}
-
-	return outBuf.Bytes(), nil
+	return nil
 }
 
-func (d *Database) Parse() error {
-	var err error
-	d.parsed, err = parser.Parse(d.plaintext, sha256.Sum256(d.header.innerRandomStreamKey))
-	if err != nil {
+// contentError makes sure that an error which occurred while reading the content wraps ErrCorrupted,
+// unless it is caused by a wrong key, truncation or the file itself. read is the number of bytes of
+// encrypted content which were read.
+func contentError(err error, read int64) error {
+	switch {
+	case errors.Is(err, ErrWrongKey), errors.Is(err, ErrCorrupted), errors.Is(err, ErrTruncated):
 		return err
+	case errors.As(err, &crypto.DecryptError{}) && read%aes.BlockSize != 0:
+		return FileError{BlockSizeError{aes.BlockSize}}
+	case errors.As(err, new(*fs.PathError)):
+		return FileError{err}
 	}
-
-	return nil
+	return corrupted("%s", err)
 }
 
 func (d *Database) VerifyHeaderHash() (bool, error) {
@@ -248,59 +258,97 @@ func (d *Database) VerifyHeaderHash() (bool, error) {
 	return bytes.Equal(d.header.hashOfRead[:], storedHash[:]), nil
 }
 
+// CheckHeaderHash is like VerifyHeaderHash, but returns an error wrapping ErrCorrupted if the hash doesn't match
+func (d *Database) CheckHeaderHash() error {
+	valid, err := d.VerifyHeaderHash()
+	if err != nil {
+		return err
+	}
+	if !valid {
+		return corrupted("header hash does not match")
+	}
+	return nil
+}
+
 func (d *Database) Save() error {
 	return d.SaveToPath(d.path)
 }
 
+// SaveToPath writes the database to a temporary file next to path and then renames it, so that a failed
+// save leaves an existing file intact. The existing file's permissions are kept, new files are only
+// readable by the owner.
 func (d *Database) SaveToPath(path string) error {
-	if d.parsed == nil {
-		return errors.New("parsed must not be nil")
+	mode := NEW_FILE_MODE
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+		// Replace the target of a symlink rather than the link itself
+		if path, err = filepath.EvalSymlinks(path); err != nil {
+			return err
+		}
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return err
 	}
-	header := d.header.Copy()
-	header.randomize()
 
-	f, err := os.Create(path)
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	hash, err := header.write(f)
+	defer os.Remove(f.Name())
+	err = f.Chmod(mode)
+	if err == nil {
+		_, err = d.WriteTo(f)
+	}
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
 	if err != nil {
 		return err
 	}
+	return os.Rename(f.Name(), path)
+}
 
-	d.parsed.Meta.HeaderHash = base64.StdEncoding.EncodeToString(hash[:])
+// WriteTo encrypts the database with new random seeds and writes it to w. It returns the number of bytes written.
+func (d *Database) WriteTo(w io.Writer) (int64, error) {
+	if d.parsed == nil {
+		return 0, errors.New("parsed must not be nil")
+	}
+	header := d.header.Copy()
+	header.randomize()
 
-	xml, err := parser.Unparse(d.parsed, sha256.Sum256(header.innerRandomStreamKey))
+	counter := &countingWriter{w: w}
+	hash, err := header.write(counter)
 	if err != nil {
-		return err
+		return counter.n, err
 	}
 
-	if header.compression {
-		xml, err = util.GZip(xml)
-		if err != nil {
-			return err
-		}
-	}
+	d.parsed.Meta.HeaderHash = base64.StdEncoding.EncodeToString(hash[:])
 
-	plainBlocks, err := formatBlocks(xml)
+	masterKey, err := crypto.GenerateMasterKeyFromComposite(d.key, header.masterSeed, header.transformSeed, d.header.transformRounds)
 	if err != nil {
-		return err
+		return counter.n, err
 	}
-
-	masterKey, err := crypto.GenerateMasterKey(d.password, header.masterSeed, header.transformSeed, d.header.transformRounds)
+	cipher, err := crypto.NewCBCWriter(counter, masterKey, header.encryptionIV)
 	if err != nil {
-		return err
+		return counter.n, err
 	}
-
-	plaintext := make([]byte, 0, len(header.streamStartBytes)+len(plainBlocks))
-	plaintext = append(plaintext, header.streamStartBytes...)
-	plaintext = append(plaintext, plainBlocks...)
-	ciphertext, err := crypto.EncryptAES(plaintext, masterKey, header.encryptionIV)
+	err = util.WriteAssert(cipher, header.streamStartBytes)
 	if err != nil {
-		return err
+		return counter.n, err
 	}
-	err = util.WriteAssert(f, ciphertext)
 
-	return err
+	// The XML is encrypted while it is being generated, so that it doesn't need to be held in memory
+	content := newContentWriter(cipher, header.compression)
+	err = parser.Encode(content, d.parsed, sha256.Sum256(header.innerRandomStreamKey))
+	if err != nil {
+		return counter.n, err
+	}
+	err = content.Close()
+	if err != nil {
+		return counter.n, err
+	}
+	err = cipher.Close()
+	return counter.n, err
 }
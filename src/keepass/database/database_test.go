@@ -1,19 +1,18 @@
 package database
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/Zaphoood/tresor/src/keepass/parser"
 	"github.com/stretchr/testify/assert"
 )
 
-var (
-	XML_HEADER      = []byte("<?xml version=\"1.0\" encoding=\"utf-8\" standalone=\"yes\"?>")
-	KEEPASS_END_TAG = []byte("</KeePassFile>")
-)
-
 func TestFileNotExist(t *testing.T) {
 	d := New("/this/path/does/not/exist.kdbx")
 	err := d.Load()
@@ -47,14 +46,8 @@ func TestLoadDb(t *testing.T) {
 			continue
 		}
 
-		plaintext := d.Plaintext()
-		assert.Equal(string(XML_HEADER), string(plaintext[:len(XML_HEADER)]))
-		assert.Equal(string(KEEPASS_END_TAG), string(plaintext[len(plaintext)-len(KEEPASS_END_TAG):]))
-
-		err = d.Parse()
-		if !assert.Nil(err) {
-			continue
-		}
+		_, err = d.Parsed().Resolve("test/Sample Entry #2")
+		assert.Nil(err)
 
 		valid, err := d.VerifyHeaderHash()
 		if !assert.Nil(err) {
@@ -87,9 +80,6 @@ func TestLoadSave(t *testing.T) {
 		if !assert.Nil(d.Decrypt()) {
 			return
 		}
-		if !assert.Nil(d.Parse()) {
-			return
-		}
 		assert.Nil(d.SaveToPath(path_out))
 
 		d2 := New(path_out)
@@ -101,28 +91,291 @@ func TestLoadSave(t *testing.T) {
 		if !assert.Nil(d2.Decrypt()) {
 			return
 		}
-		if !assert.Nil(d2.Parse()) {
-			return
-		}
 	}
 
 	// Clean up
 	os.Remove(path_out)
 }
 
+func TestSaveAtomically(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "saved.kdbx")
+	d := New("../test/example.kdbx")
+	if !assert.Nil(d.Load()) {
+		return
+	}
+	d.SetPassword("foo")
+	if !assert.Nil(d.Decrypt()) {
+		return
+	}
+
+	assert.Nil(d.SaveToPath(path))
+	info, err := os.Stat(path)
+	if assert.Nil(err) {
+		assert.Equal(NEW_FILE_MODE, info.Mode().Perm())
+	}
+
+	// Permissions of the existing file are kept
+	assert.Nil(os.Chmod(path, 0640))
+	assert.Nil(d.SaveToPath(path))
+	info, err = os.Stat(path)
+	if assert.Nil(err) {
+		assert.Equal(fs.FileMode(0640), info.Mode().Perm())
+	}
+
+	// A failed save leaves the existing file as it was, without any temporary files
+	saved, err := os.ReadFile(path)
+	assert.Nil(err)
+	d.parsed = nil
+	assert.NotNil(d.SaveToPath(path))
+	content, err := os.ReadFile(path)
+	assert.Nil(err)
+	assert.Equal(saved, content)
+	files, err := os.ReadDir(dir)
+	if assert.Nil(err) {
+		assert.Len(files, 1)
+	}
+}
+
 func TestErrors(t *testing.T) {
 	cases := []struct {
 		path    string
 		loadErr error
 	}{
-		{"../test/invalid_file_signature.kdbx", FileError(errors.New(""))},
-		{"../test/invalid_version_signature.kdbx", FileError(errors.New(""))},
-		{"../test/invalid_cipher_id.kdbx", FileError(errors.New(""))},
-		{"../test/invalid_length.kdbx", BlockSizeError{}},
+		{"../test/invalid_file_signature.kdbx", ErrNotKeePass},
+		{"../test/invalid_version_signature.kdbx", ErrUnsupportedVersion},
+		{"../test/invalid_cipher_id.kdbx", ErrUnsupportedCipher},
+		{"../test/invalid_length.kdbx", ErrCorrupted},
 	}
 	for _, c := range cases {
 		d := New(c.path)
 		err := d.Load()
-		assert.IsType(t, c.loadErr, err, fmt.Sprintf("Expected '%T' when loading '%s'", c.loadErr, c.path))
+		assert.ErrorIs(t, err, c.loadErr, fmt.Sprintf("Expected '%s' when loading '%s'", c.loadErr, c.path))
+		assert.ErrorAs(t, err, &FileError{})
+	}
+}
+
+func TestDecryptErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	content, err := os.ReadFile("../test/example.kdbx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	load := func(content []byte, password string) error {
+		d := New("")
+		err := d.LoadFrom(bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		d.SetPassword(password)
+		return d.Decrypt()
+	}
+
+	err = load(content, "wrong")
+	assert.ErrorIs(err, ErrWrongKey)
+
+	err = load(content[:100], "foo")
+	assert.ErrorIs(err, ErrTruncated)
+
+	// Damage the last block, which leaves the stream start bytes intact
+	damaged := append([]byte{}, content...)
+	damaged[len(damaged)-40] ^= 0xff
+	err = load(damaged, "foo")
+	assert.ErrorIs(err, ErrCorrupted)
+	assert.NotErrorIs(err, ErrWrongKey)
+	assert.ErrorAs(err, &ParseError{})
+}
+
+func TestDecryptFrom(t *testing.T) {
+	assert := assert.New(t)
+
+	content, err := os.ReadFile("../test/example_compressed.kdbx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypt := func(content []byte, password string) error {
+		d := New("")
+		d.SetPassword(password)
+		return d.DecryptFrom(bytes.NewReader(content))
+	}
+
+	assert.Nil(decrypt(content, "foo"))
+	assert.ErrorIs(decrypt(content, "wrong"), ErrWrongKey)
+	err = decrypt(content[:len(content)-1], "foo")
+	assert.ErrorIs(err, ErrCorrupted)
+	assert.ErrorAs(err, &BlockSizeError{})
+	err = decrypt(content[:len(content)-16], "foo")
+	assert.ErrorIs(err, ErrCorrupted)
+	assert.NotErrorIs(err, ErrWrongKey)
+}
+
+func TestDecryptModifiedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "modified.kdbx")
+	d := New("../test/example.kdbx")
+	if err := d.Load(); err != nil {
+		t.Fatal(err)
+	}
+	d.SetPassword("foo")
+	if err := d.Decrypt(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.SaveToPath(path); err != nil {
+		t.Fatal(err)
+	}
+
+	modified := New(path)
+	if err := modified.Load(); err != nil {
+		t.Fatal(err)
+	}
+	modified.SetPassword("foo")
+	// Saving uses new random seeds, so the header changes
+	if err := d.SaveToPath(path); err != nil {
+		t.Fatal(err)
+	}
+	assert.NotNil(t, modified.Decrypt())
+	assert.Nil(t, modified.Load())
+	assert.Nil(t, modified.Decrypt())
+}
+
+func TestHeaderFieldLengths(t *testing.T) {
+	h := newHeader(3, 1, false, 1, IRS_Salsa20, 16)
+	for short := range fieldLengths {
+		var buf bytes.Buffer
+		buf.Write(FILE_SIGNATURE[:])
+		buf.Write(VERSION_SIGNATURE[:])
+		h.version = version{3, 1}
+		h.version.write(&buf)
+		for _, code := range obligatoryFields {
+			data := make([]byte, 32)
+			switch code {
+			case CipherID:
+				data = AES_CIPHER_ID[:]
+			case CompressionFlag, InnerRandomStreamID:
+				data = make([]byte, DWORD)
+			case TransformRounds:
+				data = make([]byte, QWORD)
+			case EncryptionIV:
+				data = make([]byte, 16)
+			}
+			if code == short {
+				data = data[:1]
+			}
+			writeHeaderField(&buf, code, data)
+		}
+		writeHeaderField(&buf, EOH, EOH_DATA[:])
+
+		d := New("")
+		err := d.LoadFrom(&buf)
+		assert.ErrorIs(t, err, ErrCorrupted, "header with code %d", short)
+	}
+}
+
+// FuzzLoad reads the header and content of arbitrary files
+func FuzzLoad(f *testing.F) {
+	paths, err := filepath.Glob("../test/*.kdbx")
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(content)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d := New("")
+		d.LoadFrom(bytes.NewReader(data))
+	})
+}
+
+// FuzzReadContent joins, decompresses and parses arbitrary decrypted content. Decryption itself is skipped,
+// since it only succeeds for content which was encrypted with the right key.
+func FuzzReadContent(f *testing.F) {
+	for _, path := range []string{"../test/example.kdbx", "../test/example_compressed.kdbx"} {
+		d := New(path)
+		if err := d.Load(); err != nil {
+			f.Fatal(err)
+		}
+		d.SetPassword("foo")
+		if err := d.Decrypt(); err != nil {
+			f.Fatal(err)
+		}
+		var blocks bytes.Buffer
+		content := newContentWriter(&blocks, d.header.compression)
+		if err := parser.Encode(content, d.Parsed(), [32]byte{}); err != nil {
+			f.Fatal(err)
+		}
+		if err := content.Close(); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(blocks.Bytes(), d.header.compression)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, compression bool) {
+		content, err := contentReader(bytes.NewReader(data), compression, MAX_CONTENT_SIZE)
+		if err != nil {
+			return
+		}
+		parser.Decode(content, [32]byte{})
+	})
+}
+
+func TestRecover(t *testing.T) {
+	assert := assert.New(t)
+
+	content, err := os.ReadFile("../test/example.kdbx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recoverFrom := func(content []byte, password string) (*Database, RecoveryReport, error) {
+		d := New("")
+		err := d.LoadFrom(bytes.NewReader(content))
+		if err != nil && !errors.As(err, &BlockSizeError{}) {
+			return nil, RecoveryReport{}, err
+		}
+		d.SetPassword(password)
+		report, err := d.Recover()
+		return d, report, err
+	}
+
+	d, report, err := recoverFrom(content, "foo")
+	if assert.Nil(err) {
+		assert.False(report.Damaged())
+		_, err = d.Parsed().Resolve("test/Email Account")
+		assert.Nil(err)
+	}
+
+	_, _, err = recoverFrom(content, "wrong")
+	assert.ErrorIs(err, ErrWrongKey)
+
+	// Damage the middle of the content, which only garbles a few bytes of the XML
+	damaged := append([]byte{}, content...)
+	damaged[len(damaged)/2] ^= 0xff
+	d, report, err = recoverFrom(damaged, "foo")
+	if assert.Nil(err) {
+		assert.Equal([]uint32{0}, report.DamagedBlocks)
+		assert.Nil(report.ContentErr)
+		assert.Greater(len(d.Parsed().Root.Groups), 0)
+		_, err = d.Parsed().Resolve("test/Email Account")
+		assert.Nil(err)
+
+		// The recovered database can be written and opened again
+		var buf bytes.Buffer
+		_, err = d.WriteTo(&buf)
+		assert.Nil(err)
+		reopened := New("")
+		assert.Nil(reopened.LoadFrom(&buf))
+		reopened.SetPassword("foo")
+		assert.Nil(reopened.Decrypt())
+	}
+
+	d, report, err = recoverFrom(content[:len(content)*3/4], "foo")
+	if assert.Nil(err) {
+		assert.ErrorIs(report.ContentErr, ErrTruncated)
+		_, err = d.Parsed().Resolve("test/Email Account")
+		assert.Nil(err)
 	}
 }
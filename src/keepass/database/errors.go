@@ -0,0 +1,68 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Zaphoood/tresor/src/keepass/util"
+)
+
+// Sentinels which the errors returned by this package wrap, for use with errors.Is
+var (
+	// The key, i. e. the password, is incorrect
+	ErrWrongKey = errors.New("Incorrect password")
+	// The file is damaged, e. g. a block's hash doesn't match its content
+	ErrCorrupted = errors.New("File is corrupted")
+	// The file ends too early
+	ErrTruncated = util.ErrTruncated
+	// The file isn't a KeePass database at all
+	ErrNotKeePass = errors.New("Not a KeePass database")
+	// The file is a KeePass database of a version other than KDBX 3.1
+	ErrUnsupportedVersion = errors.New("Unsupported KDBX version")
+	// The database is encrypted with a cipher other than AES
+	ErrUnsupportedCipher = errors.New("Unsupported cipher")
+)
+
+// FileError is returned if the encrypted file, including its header, can't be read
+type FileError struct {
+	Err error
+}
+
+func (e FileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e FileError) Unwrap() error {
+	return e.Err
+}
+
+// ParseError is returned if the decrypted content of the database is malformed
+type ParseError struct {
+	Err error
+}
+
+func (e ParseError) Error() string {
+	return e.Err.Error()
+}
+
+func (e ParseError) Unwrap() error {
+	return e.Err
+}
+
+// BlockSizeError is returned if the length of the encrypted content isn't a multiple of the cipher's block size
+type BlockSizeError struct {
+	expectedBlockSize int
+}
+
+func (e BlockSizeError) Error() string {
+	return fmt.Sprintf("%s: length of cipher text must be multiple of block size %d", ErrCorrupted, e.expectedBlockSize)
+}
+
+func (e BlockSizeError) Is(target error) bool {
+	return target == ErrCorrupted
+}
+
+// corrupted returns a ParseError wrapping ErrCorrupted, with a message describing what is wrong
+func corrupted(format string, a ...interface{}) ParseError {
+	return ParseError{fmt.Errorf("%w: %s", ErrCorrupted, fmt.Sprintf(format, a...))}
+}
@@ -2,14 +2,13 @@ package database
 
 import (
 	"bytes"
+	"crypto/aes"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/binary"
-	"errors"
 	"fmt"
 	"io"
 	"log"
-	"os"
 
 	"github.com/Zaphoood/tresor/src/keepass/util"
 )
@@ -56,6 +55,19 @@ var obligatoryFields [9]headerCode = [9]headerCode{
 	InnerRandomStreamID,
 }
 
+// Lengths of the header fields which have a fixed length. Longer or shorter fields are rejected instead of
+// being sliced, so that a damaged header can't cause a panic.
+var fieldLengths = map[headerCode]int{
+	CipherID:            len(AES_CIPHER_ID),
+	CompressionFlag:     DWORD,
+	MasterSeed:          MASTER_SEED_LEN,
+	TransformSeed:       TRANSFORM_SEED_LEN,
+	TransformRounds:     QWORD,
+	EncryptionIV:        aes.BlockSize,
+	StreamStartBytes:    STREAM_START_BYTES_LEN,
+	InnerRandomStreamID: DWORD,
+}
+
 func validHeaderCode(c headerCode) bool {
 	return EOH <= c && c < NUM_HEADER_CODES
 }
@@ -180,8 +192,10 @@ func (h *header) randomize() {
 	rand.Read(h.innerRandomStreamKey[:])
 }
 
-func (h *header) read(stream *os.File) error {
-	startOfHeader, err := stream.Seek(0, io.SeekCurrent)
+// read parses the header from r, consuming exactly the header's bytes, and stores their hash
+func (h *header) read(r io.Reader) error {
+	hash := sha256.New()
+	stream := io.TeeReader(r, hash)
 
 	// Check filetype signature
 	eq, err := util.ReadCompare(stream, FILE_SIGNATURE[:])
@@ -189,7 +203,7 @@ func (h *header) read(stream *os.File) error {
 		return err
 	}
 	if !eq {
-		return FileError(errors.New("Invalid file signature"))
+		return FileError{ErrNotKeePass}
 	}
 
 	// Check KeePass version signature
@@ -198,7 +212,7 @@ func (h *header) read(stream *os.File) error {
 		return err
 	}
 	if !eq {
-		return FileError(errors.New("Invalid or unsupported version signature"))
+		return FileError{fmt.Errorf("%w: invalid version signature", ErrUnsupportedVersion)}
 	}
 
 	err = h.version.read(stream)
@@ -206,7 +220,7 @@ func (h *header) read(stream *os.File) error {
 		return err
 	}
 	if h.version.major != 3 || h.version.minor != 1 {
-		return FileError(errors.New("Only kdbx version 3.1 is supported"))
+		return FileError{fmt.Errorf("%w %d.%d, only 3.1 is supported", ErrUnsupportedVersion, h.version.major, h.version.minor)}
 	}
 
 	headerMap := make(map[headerCode][]byte)
@@ -242,33 +256,28 @@ func (h *header) read(stream *os.File) error {
 		headerMap[htype] = value
 	}
 
-	// Store hash
-	endOfHeader, err := stream.Seek(0, io.SeekCurrent)
-	if err != nil {
-		return err
-	}
-	if _, err = stream.Seek(startOfHeader, io.SeekStart); err != nil {
-		return err
-	}
-	headerLength := endOfHeader - startOfHeader
-	headerRaw := make([]byte, headerLength)
-	stream.Read(headerRaw)
-	h.hashOfRead = sha256.Sum256(headerRaw)
+	copy(h.hashOfRead[:], hash.Sum(nil))
 
 	// Parse header fields
 	for _, h := range obligatoryFields {
 		if _, present := headerMap[h]; !present {
-			return FileError(fmt.Errorf("Missing header with code %d", h))
+			return FileError{fmt.Errorf("%w: missing header with code %d", ErrCorrupted, h)}
+		}
+	}
+
+	for _, h := range obligatoryFields {
+		if length, fixed := fieldLengths[h]; fixed && len(headerMap[h]) != length {
+			return FileError{fmt.Errorf("%w: header with code %d is %d bytes long, expected %d", ErrCorrupted, h, len(headerMap[h]), length)}
 		}
 	}
 
 	if !bytes.Equal(headerMap[CipherID], AES_CIPHER_ID[:]) {
-		return FileError(errors.New("Invalid or unsupported cipher"))
+		return FileError{fmt.Errorf("%w, only AES is supported", ErrUnsupportedCipher)}
 	}
 
 	h.compression, err = getCompression(headerMap[CompressionFlag])
 	if err != nil {
-		return nil
+		return err
 	}
 
 	h.masterSeed = headerMap[MasterSeed]
@@ -280,7 +289,7 @@ func (h *header) read(stream *os.File) error {
 
 	irsid := binary.LittleEndian.Uint32(headerMap[InnerRandomStreamID])
 	if !validIRSID(irsid) {
-		return FileError(fmt.Errorf("Invalid Inner Random Stream ID: %d", irsid))
+		return FileError{fmt.Errorf("%w: inner random stream ID %d", ErrUnsupportedCipher, irsid)}
 	}
 	h.irsid = IRSID(irsid)
 
@@ -298,7 +307,7 @@ func (h *header) write(stream io.Writer) ([sha256.Size]byte, error) {
 	if err != nil {
 		return [sha256.Size]byte{}, err
 	}
-	h.version.write(buf)
+	err = h.version.write(buf)
 	if err != nil {
 		return [sha256.Size]byte{}, err
 	}
@@ -331,10 +340,9 @@ func (h *header) write(stream io.Writer) ([sha256.Size]byte, error) {
 			return [sha256.Size]byte{}, err
 		}
 	}
-	raw, _ := io.ReadAll(buf)
+	raw := buf.Bytes()
 	hash := sha256.Sum256(raw)
-	stream.Write(raw)
-	return hash, nil
+	return hash, util.WriteAssert(stream, raw)
 }
 
 func writeHeaderField(stream io.Writer, id headerCode, data []byte) error {
@@ -361,7 +369,7 @@ func getCompression(compressionFlag []byte) (bool, error) {
 	case COMPRESSION_GZip:
 		return true, nil
 	default:
-		return false, FileError(fmt.Errorf("Unknown compression flag: %d", flag))
+		return false, FileError{fmt.Errorf("%w: unknown compression flag %d", ErrCorrupted, flag)}
 	}
 }
 
@@ -0,0 +1,123 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+
+	"github.com/Zaphoood/tresor/src/keepass/crypto"
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/util"
+)
+
+// RecoveryReport describes what couldn't be recovered from a damaged database
+type RecoveryReport struct {
+	// IDs of the blocks whose hash doesn't match. Their content is kept, since damage to the encrypted
+	// content only garbles a few bytes, but it may contain garbage.
+	DamagedBlocks []uint32
+	// Why the content couldn't be read until its end, or nil if it could. Everything after that is lost.
+	ContentErr error
+	// Groups, entries and other parts of the XML which couldn't be parsed
+	Lost []error
+}
+
+// Damaged returns true if anything was damaged or lost
+func (r RecoveryReport) Damaged() bool {
+	return len(r.DamagedBlocks) > 0 || r.ContentErr != nil || len(r.Lost) > 0
+}
+
+// Recover decrypts and parses as much of a damaged database as possible. Unlike Decrypt and Parse, it
+// doesn't fail if blocks are damaged, the content ends early or the XML is malformed, but reports what was
+// lost instead. The database must have been loaded, even if that failed because of its length, and the key
+// must be correct.
+func (d *Database) Recover() (RecoveryReport, error) {
+	report := RecoveryReport{}
+	masterKey, err := d.masterKey()
+	if err != nil {
+		return report, err
+	}
+	content, err := d.openContent()
+	if err != nil {
+		return report, err
+	}
+	// Unlike Decrypt, this reads all of the content, since damaged parts are only noticed later on
+	ciphertext, err := io.ReadAll(content)
+	content.Close()
+	if err != nil {
+		return report, FileError{err}
+	}
+	// The last cipher block is incomplete if the file was truncated. Padding is left in place, since it
+	// follows the final block and is ignored anyway.
+	ciphertext = ciphertext[:len(ciphertext)/aes.BlockSize*aes.BlockSize]
+	plaintext, err := crypto.DecryptCBC(ciphertext, masterKey, d.header.encryptionIV)
+	if err != nil {
+		return report, err
+	}
+	startLength := len(d.header.streamStartBytes)
+	if len(plaintext) < startLength {
+		return report, FileError{fmt.Errorf("%w: content is shorter than the stream start bytes", ErrTruncated)}
+	}
+	err = d.checkStartBytes(plaintext[:startLength])
+	if err != nil {
+		return report, err
+	}
+
+	var decrypted []byte
+	decrypted, report.DamagedBlocks, report.ContentErr = recoverBlocks(plaintext[startLength:])
+	if d.header.compression {
+		decrypted, err = gunzipPartial(decrypted)
+		if err != nil && report.ContentErr == nil {
+			report.ContentErr = corrupted("failed to decompress content: %s", err)
+		}
+	}
+	d.parsed, report.Lost = parser.ParseLenient(decrypted, sha256.Sum256(d.header.innerRandomStreamKey))
+	return report, nil
+}
+
+// recoverBlocks is like blockReader, but keeps the content of damaged blocks and returns their IDs. If the
+// blocks can't be read until the final one, it returns what was read along with the error.
+func recoverBlocks(plainBlocks []byte) ([]byte, []uint32, error) {
+	in := bytes.NewReader(plainBlocks)
+	var out, block bytes.Buffer
+	damaged := []uint32{}
+	for blockCounter := uint32(0); ; blockCounter++ {
+		blockID, storedHash, err := readBlock(in, &block)
+		content := block.Bytes()
+		if err != nil {
+			// Keep what is left of a truncated block
+			if len(content) > 0 {
+				damaged = append(damaged, blockCounter)
+				out.Write(content)
+			}
+			return out.Bytes(), damaged, err
+		}
+		if blockID != blockCounter {
+			return out.Bytes(), damaged, corrupted("invalid block ID %d, expected %d", blockID, blockCounter)
+		}
+		if len(content) == 0 {
+			return out.Bytes(), damaged, nil
+		}
+		if hash := sha256.Sum256(content); !bytes.Equal(storedHash, hash[:]) {
+			damaged = append(damaged, blockID)
+		}
+		out.Write(content)
+	}
+}
+
+// gunzipPartial decompresses as much of in as possible and returns the error which stopped it, if any.
+// Like for Decrypt, the decompressed content is limited to MAX_CONTENT_SIZE.
+func gunzipPartial(in []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(in))
+	if err != nil {
+		return nil, err
+	}
+	var out bytes.Buffer
+	n, err := io.Copy(&out, io.LimitReader(r, MAX_CONTENT_SIZE+1))
+	if n > MAX_CONTENT_SIZE {
+		return out.Bytes()[:MAX_CONTENT_SIZE], fmt.Errorf("%w: more than %d bytes", util.ErrTooLarge, MAX_CONTENT_SIZE)
+	}
+	return out.Bytes(), err
+}
@@ -0,0 +1,231 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Zaphoood/tresor/src/keepass/util"
+)
+
+// Size of the blocks the content is split into when writing, the same as KeePass uses
+const BLOCK_SIZE = 1024 * 1024
+
+// blockReader joins the hashed blocks of the decrypted content while they are being read. Each block is
+// verified before any of its content is passed on, so only one block is held in memory at a time.
+type blockReader struct {
+	r       io.Reader
+	counter uint32
+	block   bytes.Buffer
+	err     error
+}
+
+func newBlockReader(r io.Reader) *blockReader {
+	return &blockReader{r: r}
+}
+
+func (b *blockReader) Read(p []byte) (int, error) {
+	for b.block.Len() == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		b.err = b.next()
+	}
+	return b.block.Read(p)
+}
+
+// next reads and verifies the next block. It returns io.EOF after the final block. Nothing of a block is
+// kept if it can't be read or verified.
+func (b *blockReader) next() error {
+	blockID, storedHash, err := readBlock(b.r, &b.block)
+	if err == nil {
+		err = verifyBlock(blockID, b.counter, storedHash, b.block.Bytes())
+	}
+	if err != nil {
+		b.block.Reset()
+		return err
+	}
+	if b.block.Len() == 0 {
+		return io.EOF
+	}
+	b.counter++
+	return nil
+}
+
+// verifyBlock checks the ID and hash of a block. The final block has no content and a hash of all zeros.
+func verifyBlock(blockID, expectedID uint32, storedHash, content []byte) error {
+	if blockID != expectedID {
+		return corrupted("invalid block ID %d, expected %d", blockID, expectedID)
+	}
+	if len(content) == 0 {
+		for _, b := range storedHash {
+			if b != 0 {
+				return corrupted("hash of final block must be zero")
+			}
+		}
+		return nil
+	}
+	hash := sha256.Sum256(content)
+	if !bytes.Equal(storedHash, hash[:]) {
+		return corrupted("hash of block %d does not match", blockID)
+	}
+	return nil
+}
+
+// readBlock reads the ID, stored hash and content of the next block, replacing what content held before.
+// The final block has no content. If the block is truncated, content holds what is left of it.
+func readBlock(in io.Reader, content *bytes.Buffer) (uint32, []byte, error) {
+	content.Reset()
+	buf := make([]byte, DWORD+sha256.Size+DWORD)
+	err := util.ReadAssert(in, buf)
+	if err != nil {
+		return 0, nil, ParseError{err}
+	}
+	blockID := binary.LittleEndian.Uint32(buf)
+	storedHash := buf[DWORD : DWORD+sha256.Size]
+	blockSize := binary.LittleEndian.Uint32(buf[DWORD+sha256.Size:])
+
+	// The buffer grows while the content is read instead of being allocated up front, since the size may be
+	// arbitrarily large in a damaged file
+	n, err := io.CopyN(content, in, int64(blockSize))
+	if errors.Is(err, io.EOF) {
+		return blockID, storedHash, ParseError{fmt.Errorf("%w: block %d is %d bytes long, but only %d bytes are left", ErrTruncated, blockID, blockSize, n)}
+	}
+	if err != nil {
+		return blockID, storedHash, ParseError{err}
+	}
+	return blockID, storedHash, nil
+}
+
+// blockWriter splits the content into hashed blocks of BLOCK_SIZE bytes while it is being written. Close
+// writes the last block as well as the final, empty one.
+type blockWriter struct {
+	w       io.Writer
+	counter uint32
+	block   []byte
+}
+
+func newBlockWriter(w io.Writer) *blockWriter {
+	return &blockWriter{w: w}
+}
+
+func (b *blockWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		n := BLOCK_SIZE - len(b.block)
+		if n > len(p) {
+			n = len(p)
+		}
+		b.block = append(b.block, p[:n]...)
+		p = p[n:]
+		if len(b.block) == BLOCK_SIZE {
+			if err := b.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// Close writes the remaining content and the final block. It doesn't close the underlying writer.
+func (b *blockWriter) Close() error {
+	if len(b.block) > 0 {
+		if err := b.flush(); err != nil {
+			return err
+		}
+	}
+	return b.flush()
+}
+
+// flush writes the buffered content as a block
+func (b *blockWriter) flush() error {
+	var hash [sha256.Size]byte
+	if len(b.block) > 0 {
+		hash = sha256.Sum256(b.block)
+	}
+	buf := make([]byte, DWORD+sha256.Size+DWORD)
+	binary.LittleEndian.PutUint32(buf, b.counter)
+	copy(buf[DWORD:], hash[:])
+	binary.LittleEndian.PutUint32(buf[DWORD+sha256.Size:], uint32(len(b.block)))
+	err := util.WriteAssert(b.w, buf)
+	if err != nil {
+		return err
+	}
+	err = util.WriteAssert(b.w, b.block)
+	if err != nil {
+		return err
+	}
+	b.counter++
+	b.block = b.block[:0]
+	return nil
+}
+
+// contentReader returns a reader for the XML content of the decrypted blocks which are read from r.
+// Compressed content is decompressed, and fails with util.ErrTooLarge once it exceeds limit bytes.
+func contentReader(r io.Reader, compression bool, limit int64) (io.Reader, error) {
+	var content io.Reader = newBlockReader(r)
+	if !compression {
+		return content, nil
+	}
+	gz, err := gzip.NewReader(content)
+	if err != nil {
+		return nil, err
+	}
+	return util.LimitReader(gz, limit), nil
+}
+
+// contentWriter is the counterpart of contentReader. It compresses the XML content if needed and splits it
+// into blocks, which it writes to the underlying writer.
+type contentWriter struct {
+	io.Writer
+	gz     *gzip.Writer
+	blocks *blockWriter
+}
+
+func newContentWriter(w io.Writer, compression bool) *contentWriter {
+	c := &contentWriter{blocks: newBlockWriter(w)}
+	c.Writer = c.blocks
+	if compression {
+		c.gz = gzip.NewWriter(c.blocks)
+		c.Writer = c.gz
+	}
+	return c
+}
+
+// Close writes the rest of the content and the final block. It doesn't close the underlying writer.
+func (c *contentWriter) Close() error {
+	if c.gz != nil {
+		if err := c.gz.Close(); err != nil {
+			return err
+		}
+	}
+	return c.blocks.Close()
+}
+
+// countingReader counts the bytes read from the underlying reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// countingWriter counts the bytes written to the underlying writer
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/util"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlocks(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		size int
+		// Including the final, empty one
+		blocks uint32
+	}{
+		{0, 1},
+		{1, 2},
+		{BLOCK_SIZE - 1, 2},
+		{BLOCK_SIZE, 2},
+		{2*BLOCK_SIZE + 3, 4},
+	}
+	for _, c := range cases {
+		size := c.size
+		content := make([]byte, size)
+		rand.Read(content)
+		var blocks bytes.Buffer
+		w := newBlockWriter(&blocks)
+		// Write in odd pieces, so that they don't line up with the blocks
+		for rest := content; len(rest) > 0; {
+			n := 12345
+			if n > len(rest) {
+				n = len(rest)
+			}
+			_, err := w.Write(rest[:n])
+			assert.Nil(err)
+			rest = rest[n:]
+		}
+		assert.Nil(w.Close())
+		assert.Equal(c.blocks, w.counter, "size %d", size)
+
+		read, err := io.ReadAll(newBlockReader(bytes.NewReader(blocks.Bytes())))
+		assert.Nil(err, "size %d", size)
+		assert.True(bytes.Equal(content, read), "size %d", size)
+	}
+}
+
+func TestBlockReaderDamaged(t *testing.T) {
+	assert := assert.New(t)
+
+	var blocks bytes.Buffer
+	w := newBlockWriter(&blocks)
+	w.Write(bytes.Repeat([]byte("a"), BLOCK_SIZE+10))
+	w.Close()
+	damaged := blocks.Bytes()
+	damaged[len(damaged)-50] ^= 0xff
+
+	// The intact first block is passed on, but nothing of the damaged one
+	read, err := io.ReadAll(newBlockReader(bytes.NewReader(damaged)))
+	assert.ErrorIs(err, ErrCorrupted)
+	assert.Len(read, BLOCK_SIZE)
+}
+
+func TestBlockReaderHugeBlock(t *testing.T) {
+	assert := assert.New(t)
+
+	// A block which claims to be 4 GiB long mustn't be allocated before checking that there's enough input
+	blocks := make([]byte, DWORD+32+DWORD)
+	binary.LittleEndian.PutUint32(blocks[DWORD+32:], 0xffffffff)
+	_, err := io.ReadAll(newBlockReader(bytes.NewReader(blocks)))
+	assert.ErrorIs(err, ErrTruncated)
+
+	_, err = io.ReadAll(newBlockReader(bytes.NewReader(blocks[:10])))
+	assert.ErrorIs(err, ErrTruncated)
+}
+
+func TestReadContentTooLarge(t *testing.T) {
+	var blocks bytes.Buffer
+	w := newContentWriter(&blocks, true)
+	w.Write(make([]byte, 1024))
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	content, err := contentReader(bytes.NewReader(blocks.Bytes()), true, 1023)
+	if assert.Nil(t, err) {
+		_, err = io.ReadAll(content)
+		assert.ErrorIs(t, err, util.ErrTooLarge)
+	}
+	content, err = contentReader(bytes.NewReader(blocks.Bytes()), true, 1024)
+	if assert.Nil(t, err) {
+		out, err := io.ReadAll(content)
+		assert.Nil(t, err)
+		assert.Len(t, out, 1024)
+	}
+}
+
+// largeVault writes a copy of an example database with many entries and attachments, which are 64 MiB in
+// total, and returns its path
+func largeVault(b *testing.B, path string) string {
+	d := New(path)
+	if err := d.Load(); err != nil {
+		b.Fatal(err)
+	}
+	d.SetPassword("foo")
+	if err := d.Decrypt(); err != nil {
+		b.Fatal(err)
+	}
+	doc := d.Parsed()
+	attachment := make([]byte, 1<<20)
+	for i := 0; i < 64; i++ {
+		rand.Read(attachment)
+		doc.Meta.Binaries = append(doc.Meta.Binaries, parser.Binary{ID: i, Chardata: base64.StdEncoding.EncodeToString(attachment)})
+	}
+	group := &doc.Root.Groups[0]
+	for i := 0; i < 20000; i++ {
+		uuid, _ := parser.NewUUID()
+		entry := parser.Entry{UUID: uuid, Strings: []parser.String{{Key: "Title"}, {Key: "UserName"}}}
+		entry.Strings[0].Value.Inner = fmt.Sprintf("Entry %d", i)
+		entry.Strings[1].Value.Inner = "user@example.com"
+		if i < 64 {
+			entry.BinaryRefs = []parser.BinaryReference{{Key: "attachment.bin", Reference: parser.BinaryReferenceValue{ID: i}}}
+		}
+		group.Entries = append(group.Entries, entry)
+	}
+	out := filepath.Join(b.TempDir(), "large.kdbx")
+	if err := d.SaveToPath(out); err != nil {
+		b.Fatal(err)
+	}
+	return out
+}
+
+// reportPeakHeap samples the heap in use until the returned function is called, which reports its maximum
+func reportPeakHeap(b *testing.B) func() {
+	runtime.GC()
+	stop := make(chan struct{})
+	done := make(chan uint64)
+	go func() {
+		var stats runtime.MemStats
+		var peak uint64
+		for {
+			runtime.ReadMemStats(&stats)
+			if stats.HeapInuse > peak {
+				peak = stats.HeapInuse
+			}
+			select {
+			case <-stop:
+				done <- peak
+				return
+			case <-time.After(5 * time.Millisecond):
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		b.ReportMetric(float64(<-done)/(1<<20), "peak-MiB")
+	}
+}
+
+func benchmarkDecrypt(b *testing.B, path string) {
+	vault := largeVault(b, path)
+	info, err := os.Stat(vault)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(info.Size())
+	b.ReportAllocs()
+	b.ResetTimer()
+	defer reportPeakHeap(b)()
+	for i := 0; i < b.N; i++ {
+		d := New(vault)
+		if err := d.Load(); err != nil {
+			b.Fatal(err)
+		}
+		d.SetPassword("foo")
+		if err := d.Decrypt(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchmarkWriteTo(b *testing.B, path string) {
+	d := New(largeVault(b, path))
+	if err := d.Load(); err != nil {
+		b.Fatal(err)
+	}
+	d.SetPassword("foo")
+	if err := d.Decrypt(); err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	defer reportPeakHeap(b)()
+	for i := 0; i < b.N; i++ {
+		n, err := d.WriteTo(io.Discard)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(n)
+	}
+}
+
+func BenchmarkDecrypt(b *testing.B) {
+	benchmarkDecrypt(b, "../test/example.kdbx")
+}
+
+func BenchmarkDecryptCompressed(b *testing.B) {
+	benchmarkDecrypt(b, "../test/example_compressed.kdbx")
+}
+
+func BenchmarkWriteTo(b *testing.B) {
+	benchmarkWriteTo(b, "../test/example.kdbx")
+}
+
+func BenchmarkWriteToCompressed(b *testing.B) {
+	benchmarkWriteTo(b, "../test/example_compressed.kdbx")
+}
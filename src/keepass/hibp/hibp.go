@@ -0,0 +1,161 @@
+package hibp
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Environment variable specifying the directory containing the range files
+const ENV_DIR = "TRESOR_HIBP_DIR"
+
+// Length of the hash prefix by which the range files are named
+const PREFIX_LENGTH = 5
+
+// Extensions of range files. The official downloader writes '.txt' files,
+// while files fetched from the range API directly usually have none.
+var rangeFileExtensions = []string{".txt", ""}
+
+// Store looks up password hashes in a local copy of the Have I Been Pwned range files. Each file is
+// named after a five character prefix of the SHA-1 hash and contains lines of the form 'SUFFIX:COUNT'.
+type Store struct {
+	dir string
+}
+
+func Open(dir string) (*Store, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("'%s' is not a directory", dir)
+	}
+	return &Store{dir}, nil
+}
+
+// Hash returns the upper case hex encoded SHA-1 hash of a password, as used by Have I Been Pwned
+func Hash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// Count returns how often a password has been seen in breaches, 0 if it wasn't found
+func (s *Store) Count(password string) (int, error) {
+	return s.CountHash(Hash(password))
+}
+
+// CountHash returns the breach count for a hex encoded SHA-1 hash, 0 if it wasn't found
+func (s *Store) CountHash(hash string) (int, error) {
+	hash = strings.ToUpper(hash)
+	if len(hash) != 2*sha1.Size {
+		return 0, fmt.Errorf("Invalid SHA-1 hash: %s", hash)
+	}
+	prefix, suffix := hash[:PREFIX_LENGTH], hash[PREFIX_LENGTH:]
+	f, err := s.openRange(prefix)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineSuffix, count, found := strings.Cut(line, ":")
+		if !found || !strings.EqualFold(lineSuffix, suffix) {
+			continue
+		}
+		n, err := strconv.Atoi(count)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid count in range file %s: %s", f.Name(), line)
+		}
+		return n, nil
+	}
+	return 0, scanner.Err()
+}
+
+func (s *Store) openRange(prefix string) (*os.File, error) {
+	for _, name := range []string{prefix, strings.ToLower(prefix)} {
+		for _, ext := range rangeFileExtensions {
+			f, err := os.Open(filepath.Join(s.dir, name+ext))
+			if err == nil {
+				return f, nil
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				return nil, err
+			}
+		}
+	}
+	return nil, fmt.Errorf("No range file for prefix %s in %s", prefix, s.dir)
+}
+
+// Candidate is an entry whose password is to be checked. Candidates are collected up front, so that the
+// check itself doesn't need access to the document.
+type Candidate struct {
+	UUID string
+	// Path of the entry, i. e. the names of its parent groups and its title, see parser.JoinPath
+	Entry    string
+	password string
+}
+
+// Breach is an entry whose password was found in the dump
+type Breach struct {
+	UUID  string
+	Entry string
+	Count int
+}
+
+// Collect returns all entries of the document with a non-empty password. Previous versions of entries and
+// entries in the recycle bin are not included, like in the audit.
+func Collect(d *parser.Document) []Candidate {
+	candidates := []Candidate{}
+	d.WalkEntries(func(l parser.Location, entry parser.Entry) {
+		if password := entry.TryGet("Password", ""); len(password) > 0 {
+			candidates = append(candidates, Candidate{
+				UUID:     entry.UUID,
+				Entry:    parser.JoinPath(l.Names),
+				password: password,
+			})
+		}
+	})
+	return candidates
+}
+
+// Check looks up the passwords of all candidates and returns the breached ones, most frequently seen first
+func Check(candidates []Candidate, s *Store) ([]Breach, error) {
+	// Passwords shared between entries are only looked up once
+	counts := map[string]int{}
+	breaches := []Breach{}
+	for _, c := range candidates {
+		hash := Hash(c.password)
+		count, ok := counts[hash]
+		if !ok {
+			var err error
+			count, err = s.CountHash(hash)
+			if err != nil {
+				return nil, err
+			}
+			counts[hash] = count
+		}
+		if count > 0 {
+			breaches = append(breaches, Breach{c.UUID, c.Entry, count})
+		}
+	}
+	sort.SliceStable(breaches, func(i, j int) bool {
+		return breaches[i].Count > breaches[j].Count
+	})
+	return breaches, nil
+}
+
+// CheckDocument looks up the passwords of all entries in a document, see Check
+func CheckDocument(d *parser.Document, s *Store) ([]Breach, error) {
+	return Check(Collect(d), s)
+}
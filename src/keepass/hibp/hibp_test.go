@@ -0,0 +1,94 @@
+package hibp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+// SHA-1 of 'password' is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+// SHA-1 of 'hunter2' is F3BBBD66A63D4BF1747940578EC3D0103530E21D
+func writeRanges(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"5BAA6.txt": "003D68EB55068C33ACE09247EE4C639306B:3\r\n1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n",
+		// Lower case names without extension are accepted as well
+		"f3bbb": "D66A63D4BF1747940578EC3D0103530E21D:29271\n",
+		"00000": "0005AD76BD555C1D6D771DE417A4B87E4B4:10\n",
+	}
+	for name, content := range files {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestCount(t *testing.T) {
+	assert := assert.New(t)
+	s, err := Open(writeRanges(t))
+	if !assert.Nil(err) {
+		return
+	}
+
+	count, err := s.Count("password")
+	assert.Nil(err)
+	assert.Equal(9545824, count)
+
+	count, err = s.Count("hunter2")
+	assert.Nil(err)
+	assert.Equal(29271, count)
+
+	// Range file exists, but the hash isn't in it
+	count, err = s.CountHash("5BAA6FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF")
+	assert.Nil(err)
+	assert.Equal(0, count)
+
+	// Missing range files are an error, since the dump is incomplete
+	_, err = s.Count("correct horse battery staple")
+	assert.NotNil(err)
+
+	_, err = s.CountHash("abc")
+	assert.NotNil(err)
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing"))
+	assert.NotNil(err)
+}
+
+func TestCheckDocument(t *testing.T) {
+	assert := assert.New(t)
+	s, err := Open(writeRanges(t))
+	if !assert.Nil(err) {
+		return
+	}
+	entry := func(title, password string) parser.Entry {
+		return parser.Entry{UUID: title, Strings: []parser.String{
+			{Key: "Title", Value: wrappers.Value{Inner: title}},
+			{Key: "Password", Value: wrappers.Value{Inner: password, Protected: true}},
+		}}
+	}
+	d := parser.NewDocument()
+	d.Meta.RecycleBinUUID = "bin"
+	d.Root.Groups = []parser.Group{{
+		Name:    "Root",
+		Entries: []parser.Entry{entry("a", "hunter2"), entry("b", ""), entry("c", "password")},
+		Groups: []parser.Group{
+			{Name: "S/ub", Entries: []parser.Entry{entry("d", "hunter2")}},
+			// Deleted entries are left out
+			{UUID: "bin", Name: "Recycle Bin", Entries: []parser.Entry{entry("e", "password")}},
+		},
+	}}
+
+	breaches, err := CheckDocument(d, s)
+	assert.Nil(err)
+	assert.Equal([]Breach{
+		{"c", "Root/c", 9545824},
+		{"a", "Root/a", 29271},
+		{"d", `Root/S\/ub/d`, 29271},
+	}, breaches)
+}
@@ -0,0 +1,223 @@
+package integrity
+
+import (
+	"encoding/base64"
+	"fmt"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// UUID which KeePass uses for references which point to nothing, e. g. if there is no recycle bin
+const NULL_UUID = "AAAAAAAAAAAAAAAAAAAAAA=="
+
+type Check string
+
+const (
+	HEADER_HASH           Check = "header-hash"
+	MALFORMED_UUID        Check = "malformed-uuid"
+	DUPLICATE_UUID        Check = "duplicate-uuid"
+	MISSING_BINARY        Check = "missing-binary"
+	UNUSED_BINARY         Check = "unused-binary"
+	DANGLING_RECYCLE_BIN  Check = "dangling-recycle-bin"
+	DANGLING_LAST_GROUP   Check = "dangling-last-selected"
+	DELETED_OBJECT_EXISTS Check = "deleted-object-exists"
+)
+
+// Problem is a single inconsistency in a database
+type Problem struct {
+	Check Check `json:"check"`
+	// UUID of the affected group or entry, empty for problems concerning the whole database
+	UUID string `json:"uuid,omitempty"`
+	// Path of the affected group or entry, separated by '/'
+	Item    string `json:"item,omitempty"`
+	Message string `json:"message"`
+	// Whether Fix repairs the problem. Problems with the header hash are repaired by saving the database.
+	Fixable bool `json:"fixable"`
+}
+
+type Report struct {
+	Problems []Problem     `json:"problems"`
+	Summary  map[Check]int `json:"summary"`
+}
+
+// Run checks a decrypted database for inconsistencies, including its header hash
+func Run(d *database.Database) Report {
+	r := Report{Problems: []Problem{}, Summary: map[Check]int{}}
+	valid, err := d.VerifyHeaderHash()
+	switch {
+	case err != nil:
+		r.add(Problem{Check: HEADER_HASH, Message: fmt.Sprintf("Header hash can't be checked: %s", err), Fixable: true})
+	case !valid:
+		r.add(Problem{Check: HEADER_HASH, Message: "Header hash doesn't match, the header was modified", Fixable: true})
+	}
+	for _, p := range inspect(d.Parsed(), false) {
+		r.add(p)
+	}
+	return r
+}
+
+// Fix repairs the problems in a document which can be repaired without losing data, and returns them.
+// Malformed and duplicate UUIDs are replaced by new ones, references to missing binaries, unused binaries
+// and deleted objects which still exist are removed. References to groups whose UUID was replaced are
+// updated to the new UUID, and references to missing groups are cleared.
+func Fix(d *parser.Document) []Problem {
+	fixed := []Problem{}
+	for _, p := range inspect(d, true) {
+		if p.Fixable {
+			fixed = append(fixed, p)
+		}
+	}
+	return fixed
+}
+
+func (r *Report) add(p Problem) {
+	r.Problems = append(r.Problems, p)
+	r.Summary[p.Check]++
+}
+
+// inspect returns the problems in a document. If fix is true, the fixable ones are repaired along the way.
+func inspect(d *parser.Document, fix bool) []Problem {
+	problems := []Problem{}
+	newUUID := func(old string) string {
+		uuid, err := parser.NewUUID()
+		if err != nil {
+			return old
+		}
+		return uuid
+	}
+
+	// UUIDs of the groups and entries which were seen already, mapped to their path
+	seen := map[string]string{}
+	groups := map[string]bool{}
+	// Malformed or duplicate group UUIDs which were replaced, mapped to the first replacement
+	replaced := map[string]string{}
+	binaryIDs := map[int]bool{}
+	for _, binary := range d.Meta.Binaries {
+		binaryIDs[binary.ID] = true
+	}
+	usedBinaryIDs := map[int]bool{}
+
+	checkUUID := func(uuid *string, path string) {
+		if !validUUID(*uuid) {
+			problems = append(problems, Problem{Check: MALFORMED_UUID, UUID: *uuid, Item: path,
+				Message: fmt.Sprintf("UUID '%s' isn't 16 bytes encoded as base64", *uuid), Fixable: true})
+			if fix {
+				*uuid = newUUID(*uuid)
+			}
+		} else if first, duplicate := seen[*uuid]; duplicate {
+			problems = append(problems, Problem{Check: DUPLICATE_UUID, UUID: *uuid, Item: path,
+				Message: fmt.Sprintf("UUID is also used by %s", first), Fixable: true})
+			if fix {
+				*uuid = newUUID(*uuid)
+			}
+		}
+		if _, duplicate := seen[*uuid]; !duplicate {
+			seen[*uuid] = path
+		}
+	}
+	checkBinaryRefs := func(entry *parser.Entry, path string) {
+		kept := entry.BinaryRefs[:0:0]
+		for _, ref := range entry.BinaryRefs {
+			if binaryIDs[ref.Reference.ID] {
+				usedBinaryIDs[ref.Reference.ID] = true
+				kept = append(kept, ref)
+				continue
+			}
+			problems = append(problems, Problem{Check: MISSING_BINARY, UUID: entry.UUID, Item: path,
+				Message: fmt.Sprintf("Attachment '%s' refers to missing binary %d", ref.Key, ref.Reference.ID), Fixable: true})
+		}
+		if fix && len(kept) < len(entry.BinaryRefs) {
+			entry.BinaryRefs = kept
+		}
+	}
+
+	var visit func(gs []parser.Group, names []string)
+	visit = func(gs []parser.Group, names []string) {
+		for i := range gs {
+			group := &gs[i]
+			groupNames := append(names[:len(names):len(names)], group.Name)
+			oldGroupUUID := group.UUID
+			checkUUID(&group.UUID, parser.JoinPath(groupNames))
+			groups[group.UUID] = true
+			if _, ok := replaced[oldGroupUUID]; !ok && group.UUID != oldGroupUUID {
+				replaced[oldGroupUUID] = group.UUID
+			}
+			for j := range group.Entries {
+				entry := &group.Entries[j]
+				path := parser.JoinPath(append(groupNames[:len(groupNames):len(groupNames)], parser.ItemName(*entry)))
+				oldUUID := entry.UUID
+				checkUUID(&entry.UUID, path)
+				checkBinaryRefs(entry, path)
+				if entry.History == nil {
+					continue
+				}
+				for k := range *entry.History {
+					previous := &(*entry.History)[k]
+					// Previous versions share the entry's UUID
+					if previous.UUID == oldUUID {
+						previous.UUID = entry.UUID
+					}
+					checkBinaryRefs(previous, path)
+				}
+			}
+			visit(group.Groups, groupNames)
+		}
+	}
+	visit(d.Root.Groups, []string{})
+
+	keptBinaries := d.Meta.Binaries[:0:0]
+	for _, binary := range d.Meta.Binaries {
+		if usedBinaryIDs[binary.ID] {
+			keptBinaries = append(keptBinaries, binary)
+			continue
+		}
+		problems = append(problems, Problem{Check: UNUSED_BINARY,
+			Message: fmt.Sprintf("Binary %d isn't used by any attachment", binary.ID), Fixable: true})
+	}
+	if fix && len(keptBinaries) < len(d.Meta.Binaries) {
+		d.Meta.Binaries = keptBinaries
+	}
+
+	checkGroupRef := func(check Check, uuid *string, name string) {
+		if len(*uuid) == 0 || *uuid == NULL_UUID || groups[*uuid] {
+			return
+		}
+		if replacement, ok := replaced[*uuid]; ok {
+			// The group still exists, only its UUID is fixed
+			if fix {
+				*uuid = replacement
+			}
+			return
+		}
+		problems = append(problems, Problem{Check: check, UUID: *uuid,
+			Message: fmt.Sprintf("%s refers to missing group '%s'", name, *uuid), Fixable: true})
+		if fix {
+			*uuid = NULL_UUID
+		}
+	}
+	checkGroupRef(DANGLING_RECYCLE_BIN, &d.Meta.RecycleBinUUID, "Recycle bin")
+	checkGroupRef(DANGLING_LAST_GROUP, &d.Meta.LastSelectedGroup, "Last selected group")
+
+	keptDeleted := d.Root.DeletedObjects[:0:0]
+	for _, deleted := range d.Root.DeletedObjects {
+		path, exists := seen[deleted.UUID]
+		if !exists {
+			keptDeleted = append(keptDeleted, deleted)
+			continue
+		}
+		problems = append(problems, Problem{Check: DELETED_OBJECT_EXISTS, UUID: deleted.UUID, Item: path,
+			Message: fmt.Sprintf("Marked as deleted on %s, but still exists", deleted.DeletionTime.Format("2006-01-02")), Fixable: true})
+	}
+	if fix && len(keptDeleted) < len(d.Root.DeletedObjects) {
+		d.Root.DeletedObjects = keptDeleted
+	}
+
+	return problems
+}
+
+// validUUID checks whether uuid is 16 bytes encoded as base64
+func validUUID(uuid string) bool {
+	decoded, err := base64.StdEncoding.DecodeString(uuid)
+	return err == nil && len(decoded) == parser.UUID_LENGTH
+}
@@ -0,0 +1,136 @@
+package integrity
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	ROOT_UUID  = "AQEBAQEBAQEBAQEBAQEBAQ=="
+	ENTRY_UUID = "AgICAgICAgICAgICAgICAg=="
+	OTHER_UUID = "AwMDAwMDAwMDAwMDAwMDAw=="
+)
+
+func newEntry(uuid, title string, binaryIDs ...int) parser.Entry {
+	entry := parser.Entry{
+		UUID:    uuid,
+		Strings: []parser.String{{Key: "Title", Value: wrappers.Value{Inner: title}}},
+	}
+	for _, id := range binaryIDs {
+		entry.BinaryRefs = append(entry.BinaryRefs, parser.BinaryReference{Key: "file.txt", Reference: parser.BinaryReferenceValue{ID: id}})
+	}
+	return entry
+}
+
+func testDocument() *parser.Document {
+	d := parser.NewDocument()
+	d.Meta.RecycleBinUUID = OTHER_UUID
+	d.Meta.LastSelectedGroup = ROOT_UUID
+	d.Meta.Binaries = []parser.Binary{{ID: 0}, {ID: 1}}
+	history := []parser.Entry{newEntry(ENTRY_UUID, "Old", 1)}
+	dup := newEntry(ENTRY_UUID, "Dup")
+	dup.History = &history
+	d.Root.Groups = []parser.Group{{
+		UUID: ROOT_UUID,
+		Name: "Root",
+		Entries: []parser.Entry{
+			newEntry(ENTRY_UUID, "Mail", 0, 2),
+			dup,
+			newEntry("not base64", "Bad"),
+		},
+	}}
+	d.Root.DeletedObjects = []parser.DeletedObject{
+		{UUID: ENTRY_UUID, DeletionTime: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{UUID: OTHER_UUID},
+	}
+	return d
+}
+
+func checks(problems []Problem) []Check {
+	result := []Check{}
+	for _, p := range problems {
+		result = append(result, p.Check)
+	}
+	return result
+}
+
+func TestInspect(t *testing.T) {
+	assert := assert.New(t)
+
+	problems := inspect(testDocument(), false)
+	assert.Equal([]Check{MISSING_BINARY, DUPLICATE_UUID, MALFORMED_UUID, DANGLING_RECYCLE_BIN, DELETED_OBJECT_EXISTS}, checks(problems))
+	assert.Equal("Root/Mail", problems[0].Item)
+	assert.Equal("Root/Dup", problems[1].Item)
+	assert.Equal("UUID is also used by Root/Mail", problems[1].Message)
+
+	// Binary 1 is only used by a previous version
+	d := testDocument()
+	d.Root.Groups[0].Entries[1].History = nil
+	assert.Contains(checks(inspect(d, false)), UNUSED_BINARY)
+}
+
+func TestFix(t *testing.T) {
+	assert := assert.New(t)
+
+	d := testDocument()
+	fixed := Fix(d)
+	assert.Len(fixed, 5)
+	assert.Empty(inspect(d, false))
+
+	entries := d.Root.Groups[0].Entries
+	assert.Equal(ENTRY_UUID, entries[0].UUID)
+	assert.NotEqual(ENTRY_UUID, entries[1].UUID)
+	assert.Equal(entries[1].UUID, (*entries[1].History)[0].UUID)
+	assert.True(validUUID(entries[2].UUID))
+	assert.Len(entries[0].BinaryRefs, 1)
+	assert.Equal(NULL_UUID, d.Meta.RecycleBinUUID)
+	assert.Equal(ROOT_UUID, d.Meta.LastSelectedGroup)
+	assert.Equal([]parser.DeletedObject{{UUID: OTHER_UUID}}, d.Root.DeletedObjects)
+	assert.Len(d.Meta.Binaries, 2)
+}
+
+func TestFixGroupRefs(t *testing.T) {
+	assert := assert.New(t)
+
+	d := parser.NewDocument()
+	d.Meta.RecycleBinUUID = "not base64"
+	d.Meta.LastSelectedGroup = "not base64"
+	d.Root.Groups = []parser.Group{{
+		UUID:   ROOT_UUID,
+		Name:   "Root",
+		Groups: []parser.Group{{UUID: "not base64", Name: "Recycle Bin"}},
+	}}
+
+	assert.Equal([]Check{MALFORMED_UUID}, checks(Fix(d)))
+	recycleBin := d.Root.Groups[0].Groups[0]
+	assert.True(validUUID(recycleBin.UUID))
+	assert.Equal(recycleBin.UUID, d.Meta.RecycleBinUUID)
+	assert.Equal(recycleBin.UUID, d.Meta.LastSelectedGroup)
+	assert.Empty(inspect(d, false))
+}
+
+func TestRun(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, path := range []string{"../test/example.kdbx", "../test/example_compressed.kdbx"} {
+		d := database.New(path)
+		if err := d.Load(); err != nil {
+			t.Fatal(err)
+		}
+		d.SetPassword("foo")
+		if err := d.Decrypt(); err != nil {
+			t.Fatal(err)
+		}
+		r := Run(d)
+		assert.Empty(r.Problems, path)
+
+		d.Parsed().Meta.HeaderHash = ""
+		r = Run(d)
+		assert.Equal(map[Check]int{HEADER_HASH: 1}, r.Summary)
+	}
+}
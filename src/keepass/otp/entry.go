@@ -0,0 +1,150 @@
+package otp
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Field used by KeePassXC to store a Key URI
+const FIELD_URI = "otp"
+
+// Fields used by KeePass 2.5x for time-based one-time passwords
+const (
+	FIELD_TOTP_PREFIX    = "TimeOtp-"
+	FIELD_TOTP_LENGTH    = "TimeOtp-Length"
+	FIELD_TOTP_PERIOD    = "TimeOtp-Period"
+	FIELD_TOTP_ALGORITHM = "TimeOtp-Algorithm"
+)
+
+// Fields used by KeePass 2.5x for counter-based one-time passwords
+const (
+	FIELD_HOTP_PREFIX  = "HmacOtp-"
+	FIELD_HOTP_COUNTER = "HmacOtp-Counter"
+)
+
+type NotConfigured struct{}
+
+func (_ NotConfigured) Error() string {
+	return "Entry has no OTP configuration"
+}
+
+// FromEntry returns the OTP key stored in the given entry. KeePassXC's 'otp' field as well
+// as KeePass' 'TimeOtp-*' and 'HmacOtp-*' fields are supported, in that order of precedence.
+// If the entry has no OTP configuration at all, NotConfigured is returned.
+func FromEntry(e *parser.Entry) (Key, error) {
+	if uri, err := e.Get(FIELD_URI); err == nil && len(uri.Inner) > 0 {
+		return ParseURI(uri.Inner)
+	}
+
+	secret, found, err := secretFromFields(e, FIELD_TOTP_PREFIX)
+	if err != nil {
+		return Key{}, err
+	}
+	if found {
+		return totpFromFields(e, secret)
+	}
+
+	secret, found, err = secretFromFields(e, FIELD_HOTP_PREFIX)
+	if err != nil {
+		return Key{}, err
+	}
+	if found {
+		return hotpFromFields(e, secret)
+	}
+
+	return Key{}, NotConfigured{}
+}
+
+func totpFromFields(e *parser.Entry, secret []byte) (Key, error) {
+	var err error
+	key := Key{
+		Type:   TOTP,
+		Secret: secret,
+		Digits: DEFAULT_DIGITS,
+		Period: DEFAULT_PERIOD,
+	}
+	if length := e.TryGet(FIELD_TOTP_LENGTH, ""); len(length) > 0 {
+		key.Digits, err = strconv.Atoi(strings.TrimSpace(length))
+		if err != nil {
+			return Key{}, fmt.Errorf("Invalid value for %s: %s", FIELD_TOTP_LENGTH, length)
+		}
+	}
+	if period := e.TryGet(FIELD_TOTP_PERIOD, ""); len(period) > 0 {
+		key.Period, err = strconv.Atoi(strings.TrimSpace(period))
+		if err != nil {
+			return Key{}, fmt.Errorf("Invalid value for %s: %s", FIELD_TOTP_PERIOD, period)
+		}
+	}
+	key.Algorithm, err = parseAlgorithm(strings.TrimSpace(e.TryGet(FIELD_TOTP_ALGORITHM, "")))
+	if err != nil {
+		return Key{}, err
+	}
+
+	return key, key.validate()
+}
+
+// hotpFromFields reads the counter of a KeePass HOTP configuration. KeePass always uses
+// six digits and HMAC-SHA-1 for HOTP, so there are no fields for these parameters.
+func hotpFromFields(e *parser.Entry, secret []byte) (Key, error) {
+	key := Key{
+		Type:      HOTP,
+		Secret:    secret,
+		Algorithm: SHA1,
+		Digits:    DEFAULT_DIGITS,
+	}
+	if counter := e.TryGet(FIELD_HOTP_COUNTER, ""); len(counter) > 0 {
+		var err error
+		key.Counter, err = strconv.ParseUint(strings.TrimSpace(counter), 10, 64)
+		if err != nil {
+			return Key{}, fmt.Errorf("Invalid value for %s: %s", FIELD_HOTP_COUNTER, counter)
+		}
+	}
+	return key, key.validate()
+}
+
+// NextHOTP returns the HOTP code for the entry's current counter, along with a copy
+// of the entry in which the counter has been incremented. The entry itself is not modified.
+func NextHOTP(e parser.Entry) (string, parser.Entry, error) {
+	key, err := FromEntry(&e)
+	if err != nil {
+		return "", e, err
+	}
+	if key.Type != HOTP {
+		return "", e, fmt.Errorf("Entry uses %s, not HOTP", key.Type)
+	}
+	code := key.HOTP()
+	e.SetField(FIELD_HOTP_COUNTER, strconv.FormatUint(key.Counter+1, 10))
+	return code, e, nil
+}
+
+// secretFromFields looks for a secret stored in any of the encodings supported by KeePass,
+// i. e. in one of the fields '<prefix>Secret', '<prefix>Secret-Hex', '<prefix>Secret-Base32'
+// or '<prefix>Secret-Base64'. The bool return value indicates wether any of them was present.
+func secretFromFields(e *parser.Entry, prefix string) ([]byte, bool, error) {
+	decoders := []struct {
+		suffix string
+		decode func(string) ([]byte, error)
+	}{
+		{"Secret", func(s string) ([]byte, error) { return []byte(s), nil }},
+		{"Secret-Hex", func(s string) ([]byte, error) { return hex.DecodeString(strings.ReplaceAll(s, " ", "")) }},
+		{"Secret-Base32", decodeBase32},
+		{"Secret-Base64", base64.StdEncoding.DecodeString},
+	}
+	for _, d := range decoders {
+		value, err := e.Get(prefix + d.suffix)
+		if err != nil || len(value.Inner) == 0 {
+			continue
+		}
+		secret, err := d.decode(strings.TrimSpace(value.Inner))
+		if err != nil {
+			return nil, true, fmt.Errorf("Invalid value for %s: %s", prefix+d.suffix, err)
+		}
+		return secret, true, nil
+	}
+	return nil, false, nil
+}
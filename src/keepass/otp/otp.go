@@ -0,0 +1,170 @@
+package otp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+const (
+	DEFAULT_DIGITS = 6
+	DEFAULT_PERIOD = 30
+	MIN_DIGITS     = 6
+	MAX_DIGITS     = 10
+)
+
+type Algorithm int
+
+const (
+	SHA1 Algorithm = iota
+	SHA256
+	SHA512
+)
+
+func (a Algorithm) String() string {
+	switch a {
+	case SHA1:
+		return "SHA1"
+	case SHA256:
+		return "SHA256"
+	case SHA512:
+		return "SHA512"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", int(a))
+	}
+}
+
+func (a Algorithm) hash() func() hash.Hash {
+	switch a {
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+// parseAlgorithm accepts both the otpauth notation ("SHA256") and the KeePass notation ("HMAC-SHA-256")
+func parseAlgorithm(s string) (Algorithm, error) {
+	normalized := strings.ToUpper(s)
+	normalized = strings.TrimPrefix(normalized, "HMAC-")
+	normalized = strings.ReplaceAll(normalized, "-", "")
+	switch normalized {
+	case "", "SHA1":
+		return SHA1, nil
+	case "SHA256":
+		return SHA256, nil
+	case "SHA512":
+		return SHA512, nil
+	default:
+		return SHA1, fmt.Errorf("Unsupported OTP algorithm: %s", s)
+	}
+}
+
+type Type int
+
+const (
+	// Time-based one-time password as per RFC 6238
+	TOTP Type = iota
+	// Counter-based one-time password as per RFC 4226
+	HOTP
+)
+
+func (t Type) String() string {
+	switch t {
+	case TOTP:
+		return "TOTP"
+	case HOTP:
+		return "HOTP"
+	default:
+		return fmt.Sprintf("Type(%d)", int(t))
+	}
+}
+
+// Key holds everything that is needed to generate one-time passwords
+type Key struct {
+	Type      Type
+	Secret    []byte
+	Algorithm Algorithm
+	Digits    int
+	// Period is the validity of a single code in seconds. Only used for TOTP
+	Period int
+	// Counter is the counter value for the next code. Only used for HOTP
+	Counter uint64
+}
+
+func (k Key) validate() error {
+	if len(k.Secret) == 0 {
+		return fmt.Errorf("OTP secret is empty")
+	}
+	if k.Digits < MIN_DIGITS || k.Digits > MAX_DIGITS {
+		return fmt.Errorf("Invalid number of OTP digits: %d", k.Digits)
+	}
+	if k.Type == TOTP && k.Period <= 0 {
+		return fmt.Errorf("Invalid OTP period: %d", k.Period)
+	}
+	return nil
+}
+
+// TOTP returns the code that is valid at time t, as per RFC 6238
+func (k Key) TOTP(t time.Time) string {
+	return generate(k.Secret, k.Algorithm, k.Digits, uint64(t.Unix())/uint64(k.Period))
+}
+
+// HOTP returns the code for the key's current counter value, as per RFC 4226
+func (k Key) HOTP() string {
+	return generate(k.Secret, k.Algorithm, k.Digits, k.Counter)
+}
+
+// Remaining returns the duration for which the code valid at time t will remain valid
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// generate computes an HOTP value as per RFC 4226
+func generate(secret []byte, algorithm Algorithm, digits int, counter uint64) string {
+	counterBuf := make([]byte, 8)
+	binary.BigEndian.PutUint64(counterBuf, counter)
+	mac := hmac.New(algorithm.hash(), secret)
+	mac.Write(counterBuf)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	truncated := uint64(binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff)
+	modulus := uint64(1)
+	for i := 0; i < digits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", digits, truncated%modulus)
+}
+
+// Format splits a code into two halves for better readability, e.g. "123456" becomes "123 456"
+func Format(code string) string {
+	if len(code) < 6 {
+		return code
+	}
+	return code[:len(code)/2] + " " + code[len(code)/2:]
+}
+
+func decodeBase32(s string) ([]byte, error) {
+	cleaned := strings.ToUpper(strings.Map(func(r rune) rune {
+		if r == ' ' || r == '-' || r == '=' {
+			return -1
+		}
+		return r
+	}, s))
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(cleaned)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid base32 secret: %s", err)
+	}
+	return decoded, nil
+}
@@ -0,0 +1,186 @@
+package otp
+
+import (
+	"encoding/base32"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+// Seeds from RFC 6238, Appendix B
+var rfcSecrets = map[Algorithm]string{
+	SHA1:   "12345678901234567890",
+	SHA256: "12345678901234567890123456789012",
+	SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+}
+
+func TestTOTP(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		time      int64
+		algorithm Algorithm
+		expected  string
+	}{
+		{59, SHA1, "94287082"},
+		{59, SHA256, "46119246"},
+		{59, SHA512, "90693936"},
+		{1111111109, SHA1, "07081804"},
+		{1111111109, SHA256, "68084774"},
+		{1111111109, SHA512, "25091201"},
+		{1234567890, SHA1, "89005924"},
+		{1234567890, SHA256, "91819424"},
+		{1234567890, SHA512, "93441116"},
+		{2000000000, SHA1, "69279037"},
+		{2000000000, SHA256, "90698825"},
+		{2000000000, SHA512, "38618901"},
+	}
+	for _, c := range cases {
+		key := Key{
+			Secret:    []byte(rfcSecrets[c.algorithm]),
+			Algorithm: c.algorithm,
+			Digits:    8,
+			Period:    30,
+		}
+		assert.Equal(c.expected, key.TOTP(time.Unix(c.time, 0)), fmt.Sprintf("%s at %d", c.algorithm, c.time))
+	}
+}
+
+func TestHOTP(t *testing.T) {
+	// Test values from RFC 4226, Appendix D
+	expected := []string{"755224", "287082", "359152", "969429", "338314", "254676", "287922", "162583", "399871", "520489"}
+	key := Key{
+		Type:      HOTP,
+		Secret:    []byte(rfcSecrets[SHA1]),
+		Algorithm: SHA1,
+		Digits:    6,
+	}
+	for counter, code := range expected {
+		key.Counter = uint64(counter)
+		assert.Equal(t, code, key.HOTP(), fmt.Sprintf("Counter %d", counter))
+	}
+}
+
+func TestRemaining(t *testing.T) {
+	key := Key{Period: 30}
+	assert.Equal(t, 1*time.Second, key.Remaining(time.Unix(59, 0)))
+	assert.Equal(t, 30*time.Second, key.Remaining(time.Unix(60, 0)))
+}
+
+func TestParseURI(t *testing.T) {
+	assert := assert.New(t)
+
+	secret := base32.StdEncoding.EncodeToString([]byte(rfcSecrets[SHA256]))
+	key, err := ParseURI(fmt.Sprintf("otpauth://totp/ACME:alice?secret=%s&algorithm=SHA256&digits=8&period=60", secret))
+	if assert.Nil(err) {
+		assert.Equal([]byte(rfcSecrets[SHA256]), key.Secret)
+		assert.Equal(SHA256, key.Algorithm)
+		assert.Equal(8, key.Digits)
+		assert.Equal(60, key.Period)
+	}
+
+	key, err = ParseURI("otpauth://totp/alice?secret=gezdgnbvgy3tqojq")
+	if assert.Nil(err) {
+		assert.Equal([]byte("1234567890"), key.Secret)
+		assert.Equal(SHA1, key.Algorithm)
+		assert.Equal(DEFAULT_DIGITS, key.Digits)
+		assert.Equal(DEFAULT_PERIOD, key.Period)
+	}
+
+	invalid := []string{
+		"https://totp/alice?secret=GEZDGNBV",
+		"otpauth://totp/alice?secret=",
+		"otpauth://totp/alice?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://totp/alice?secret=GEZDGNBV&digits=2",
+		"otpauth://totp/alice?secret=GEZDGNBV&encoder=steam",
+	}
+	for _, uri := range invalid {
+		_, err := ParseURI(uri)
+		assert.NotNil(err, uri)
+	}
+}
+
+func newEntry(fields map[string]string) parser.Entry {
+	e := parser.Entry{}
+	for key, value := range fields {
+		e.Strings = append(e.Strings, parser.String{Key: key, Value: wrappers.Value{Inner: value}})
+	}
+	return e
+}
+
+func TestFromEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	e := newEntry(map[string]string{
+		"TimeOtp-Secret-Base32": base32.StdEncoding.EncodeToString([]byte(rfcSecrets[SHA512])),
+		"TimeOtp-Length":        "8",
+		"TimeOtp-Period":        "30",
+		"TimeOtp-Algorithm":     "HMAC-SHA-512",
+	})
+	key, err := FromEntry(&e)
+	if assert.Nil(err) {
+		assert.Equal("90693936", key.TOTP(time.Unix(59, 0)))
+	}
+
+	e = newEntry(map[string]string{
+		"TimeOtp-Secret-Hex": "3132333435363738393031323334353637383930",
+		"otp":                "otpauth://totp/alice?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&digits=8",
+	})
+	key, err = FromEntry(&e)
+	if assert.Nil(err) {
+		assert.Equal("94287082", key.TOTP(time.Unix(59, 0)))
+	}
+
+	e = newEntry(map[string]string{"Title": "No OTP here"})
+	_, err = FromEntry(&e)
+	assert.IsType(NotConfigured{}, err)
+
+	e = newEntry(map[string]string{"TimeOtp-Secret-Base64": "not base64!"})
+	_, err = FromEntry(&e)
+	assert.NotNil(err)
+	assert.NotEqual(NotConfigured{}, err)
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "123 456", Format("123456"))
+	assert.Equal(t, "1234 5678", Format("12345678"))
+}
+
+func TestNextHOTP(t *testing.T) {
+	assert := assert.New(t)
+
+	e := newEntry(map[string]string{
+		"HmacOtp-Secret":  rfcSecrets[SHA1],
+		"HmacOtp-Counter": "3",
+	})
+	code, next, err := NextHOTP(e)
+	if !assert.Nil(err) {
+		return
+	}
+	assert.Equal("969429", code)
+	assert.Equal("4", next.TryGet(FIELD_HOTP_COUNTER, ""))
+	assert.Equal("3", e.TryGet(FIELD_HOTP_COUNTER, ""), "Original entry must not be modified")
+
+	code, next, err = NextHOTP(next)
+	if assert.Nil(err) {
+		assert.Equal("338314", code)
+		assert.Equal("5", next.TryGet(FIELD_HOTP_COUNTER, ""))
+	}
+
+	// A missing counter field is treated as zero and added
+	e = newEntry(map[string]string{"HmacOtp-Secret-Base32": base32.StdEncoding.EncodeToString([]byte(rfcSecrets[SHA1]))})
+	code, next, err = NextHOTP(e)
+	if assert.Nil(err) {
+		assert.Equal("755224", code)
+		assert.Equal("1", next.TryGet(FIELD_HOTP_COUNTER, ""))
+		assert.Equal(1, len(e.Strings))
+	}
+
+	e = newEntry(map[string]string{"TimeOtp-Secret": rfcSecrets[SHA1]})
+	_, _, err = NextHOTP(e)
+	assert.NotNil(err)
+}
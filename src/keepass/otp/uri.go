@@ -0,0 +1,57 @@
+package otp
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const URI_SCHEME = "otpauth"
+
+// ParseURI parses a Key URI such as the ones stored in KeePassXC's 'otp' field, e.g.
+// otpauth://totp/Issuer:alice?secret=JBSWY3DPEHPK3PXP&period=30&digits=6&algorithm=SHA1
+func ParseURI(uri string) (Key, error) {
+	u, err := url.Parse(strings.TrimSpace(uri))
+	if err != nil {
+		return Key{}, fmt.Errorf("Invalid OTP URI: %s", err)
+	}
+	if u.Scheme != URI_SCHEME {
+		return Key{}, fmt.Errorf("Invalid OTP URI: expected scheme '%s', got '%s'", URI_SCHEME, u.Scheme)
+	}
+	if u.Host != "totp" {
+		return Key{}, fmt.Errorf("Unsupported OTP type: %s", u.Host)
+	}
+
+	query := u.Query()
+	key := Key{
+		Type:   TOTP,
+		Digits: DEFAULT_DIGITS,
+		Period: DEFAULT_PERIOD,
+	}
+	if encoder := query.Get("encoder"); len(encoder) > 0 {
+		return Key{}, fmt.Errorf("Unsupported OTP encoder: %s", encoder)
+	}
+	key.Secret, err = decodeBase32(query.Get("secret"))
+	if err != nil {
+		return Key{}, err
+	}
+	key.Algorithm, err = parseAlgorithm(query.Get("algorithm"))
+	if err != nil {
+		return Key{}, err
+	}
+	if digits := query.Get("digits"); len(digits) > 0 {
+		key.Digits, err = strconv.Atoi(digits)
+		if err != nil {
+			return Key{}, fmt.Errorf("Invalid OTP digits: %s", digits)
+		}
+	}
+	if period := query.Get("period"); len(period) > 0 {
+		key.Period, err = strconv.Atoi(period)
+		if err != nil {
+			return Key{}, fmt.Errorf("Invalid OTP period: %s", period)
+		}
+	}
+
+	return key, key.validate()
+}
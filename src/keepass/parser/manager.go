@@ -116,6 +116,20 @@ func (e *Entry) UpdateField(key, value string) bool {
 	return changed
 }
 
+// SetField updates the field with the given key to the given value, adding the field if it doesn't exist yet
+// Returns true if a change was made, false otherwise
+func (e *Entry) SetField(key, value string) bool {
+	if _, err := e.Get(key); err == nil {
+		return e.UpdateField(key, value)
+	}
+	// Use a full slice expression so that copies of this entry which share the same array aren't affected
+	e.Strings = append(e.Strings[:len(e.Strings):len(e.Strings)], String{
+		Key:   key,
+		Value: wrappers.Value{Inner: value},
+	})
+	return true
+}
+
 type PathNotFound error
 
 // GetItem returns a group or an item specified by a path of UUIDs. The document is traversed,
@@ -0,0 +1,315 @@
+package parser
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+)
+
+// Length of a UUID in bytes, before encoding
+const UUID_LENGTH = 16
+
+// NoSuchItemError is returned if there is no group or entry with the given UUID
+type NoSuchItemError struct {
+	UUID string
+}
+
+func (e NoSuchItemError) Error() string {
+	return fmt.Sprintf("No group or entry with UUID '%s'", e.UUID)
+}
+
+// now returns the current time with the precision stored in KeePass files. It may be replaced in tests.
+var now = func() time.Time {
+	return time.Now().UTC().Truncate(time.Second)
+}
+
+// NewUUID returns a random UUID, encoded as base64 like all UUIDs in KeePass files
+func NewUUID() (string, error) {
+	b := make([]byte, UUID_LENGTH)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// AddEntry appends an entry to the group with the given UUID. Times which aren't set yet, such as the
+// creation time of a new entry, are set to the current time. If the entry was deleted before, it is
+// removed from the deleted objects.
+func (d *Document) AddEntry(groupUUID string, entry Entry) error {
+	setMissingTimes(&entry.Times, now())
+	return d.InsertItem(groupUUID, -1, entry)
+}
+
+// AddGroup appends a group, including its contents, to the group with the given UUID. An empty UUID adds
+// it as a top-level group. Like with AddEntry, missing times are set and deleted objects are updated.
+func (d *Document) AddGroup(parentUUID string, group Group) error {
+	return d.InsertItem(parentUUID, -1, withMissingTimes(group, now()))
+}
+
+// InsertItem inserts a group or entry at the given index of the subgroups or entries of the group with the
+// given UUID. If the index is out of range, the item is appended. Unlike AddEntry and AddGroup, the item's
+// times are kept exactly as they are, which is used to restore items that were removed.
+func (d *Document) InsertItem(groupUUID string, index int, item Item) error {
+	if len(item.GetUUID()) == 0 {
+		if _, isEntry := item.(Entry); isEntry {
+			return errors.New("Entry has no UUID")
+		}
+		return errors.New("Group has no UUID")
+	}
+	uuids := itemUUIDs(item)
+	for _, uuid := range uuids {
+		if _, exists := d.FindPath(uuid); exists {
+			return fmt.Errorf("An item with UUID '%s' already exists", uuid)
+		}
+	}
+	switch item := item.(type) {
+	case Group:
+		groups, err := d.subgroups(groupUUID)
+		if err != nil {
+			return err
+		}
+		*groups = insertAt(*groups, index, item)
+	case Entry:
+		group := findGroup(d.Root.Groups, groupUUID)
+		if group == nil {
+			return NoSuchItemError{groupUUID}
+		}
+		group.Entries = insertAt(group.Entries, index, item)
+	default:
+		return fmt.Errorf("Can't insert item of type %T", item)
+	}
+	d.forgetDeleted(uuids...)
+	return nil
+}
+
+// ItemIndex returns the index of the group or entry with the given UUID among the subgroups or entries
+// of its parent
+func (d *Document) ItemIndex(uuid string) (int, error) {
+	path, found := d.FindPath(uuid)
+	if !found {
+		return 0, NoSuchItemError{uuid}
+	}
+	parentUUID := ""
+	if len(path) > 1 {
+		parentUUID = path[len(path)-2]
+	}
+	if groups, err := d.subgroups(parentUUID); err == nil {
+		for i, group := range *groups {
+			if group.UUID == uuid {
+				return i, nil
+			}
+		}
+	}
+	if group := findGroup(d.Root.Groups, parentUUID); group != nil {
+		for i, entry := range group.Entries {
+			if entry.UUID == uuid {
+				return i, nil
+			}
+		}
+	}
+	return 0, NoSuchItemError{uuid}
+}
+
+// RemoveItem permanently deletes the group or entry with the given UUID and returns it. The item and
+// everything it contains is recorded in the deleted objects, so that the deletion is synchronized.
+func (d *Document) RemoveItem(uuid string) (Item, error) {
+	item, found := removeItem(&d.Root.Groups, uuid)
+	if !found {
+		return nil, NoSuchItemError{uuid}
+	}
+	deletionTime := now()
+	for _, deleted := range itemUUIDs(item) {
+		d.Root.DeletedObjects = append(d.Root.DeletedObjects, DeletedObject{UUID: deleted, DeletionTime: deletionTime})
+	}
+	return item, nil
+}
+
+// MoveItem moves the group or entry with the given UUID to the end of another group and updates the time
+// its location changed. Groups may be moved to the top level by passing an empty UUID. It is an error to
+// move a group into itself or one of its subgroups.
+func (d *Document) MoveItem(uuid, groupUUID string) error {
+	path, found := d.FindPath(uuid)
+	if !found {
+		return NoSuchItemError{uuid}
+	}
+	parentUUID := ""
+	if len(path) > 1 {
+		parentUUID = path[len(path)-2]
+	}
+	if parentUUID == groupUUID {
+		return nil
+	}
+	item, err := d.GetItem(path)
+	if err != nil {
+		return err
+	}
+	if _, isEntry := item.(Entry); isEntry && len(groupUUID) == 0 {
+		return errors.New("Entries must be inside a group")
+	}
+	if len(groupUUID) > 0 {
+		groupPath, found := d.FindPath(groupUUID)
+		if !found {
+			return NoSuchItemError{groupUUID}
+		}
+		if findGroup(d.Root.Groups, groupUUID) == nil {
+			return fmt.Errorf("'%s' is not a group", groupUUID)
+		}
+		for _, ancestor := range groupPath {
+			if ancestor == uuid {
+				return errors.New("Cannot move a group into itself")
+			}
+		}
+	}
+
+	item, _ = removeItem(&d.Root.Groups, uuid)
+	switch item := item.(type) {
+	case Group:
+		item.Times.LocationChanged = now()
+		groups, _ := d.subgroups(groupUUID)
+		n := len(*groups)
+		*groups = append((*groups)[:n:n], item)
+	case Entry:
+		item.Times.LocationChanged = now()
+		group := findGroup(d.Root.Groups, groupUUID)
+		n := len(group.Entries)
+		group.Entries = append(group.Entries[:n:n], item)
+	}
+	return nil
+}
+
+// UpdateGroup replaces the metadata of the group with the same UUID, such as its name and notes, while
+// keeping its entries and subgroups. If the metadata changed, the modification time is set to the current
+// time, unless it was changed as well.
+func (d *Document) UpdateGroup(newGroup Group) error {
+	group := findGroup(d.Root.Groups, newGroup.UUID)
+	if group == nil {
+		return NoSuchItemError{newGroup.UUID}
+	}
+	newGroup.Entries = group.Entries
+	newGroup.Groups = group.Groups
+	oldMeta, newMeta := group.CopyMeta().(Group), newGroup.CopyMeta().(Group)
+	oldMeta.Times, newMeta.Times = Times{}, Times{}
+	if !reflect.DeepEqual(oldMeta, newMeta) && newGroup.Times.LastModificationTime.Equal(group.Times.LastModificationTime) {
+		newGroup.Times.LastModificationTime = now()
+	}
+	*group = newGroup
+	return nil
+}
+
+// findGroup returns a pointer to the group with the given UUID, or nil if there is no such group
+func findGroup(groups []Group, uuid string) *Group {
+	for i := range groups {
+		if groups[i].UUID == uuid {
+			return &groups[i]
+		}
+		if group := findGroup(groups[i].Groups, uuid); group != nil {
+			return group
+		}
+	}
+	return nil
+}
+
+// subgroups returns a pointer to the subgroups of the group with the given UUID, or to the top-level groups
+// if the UUID is empty
+func (d *Document) subgroups(groupUUID string) (*[]Group, error) {
+	if len(groupUUID) == 0 {
+		return &d.Root.Groups, nil
+	}
+	group := findGroup(d.Root.Groups, groupUUID)
+	if group == nil {
+		return nil, NoSuchItemError{groupUUID}
+	}
+	return &group.Groups, nil
+}
+
+// removeItem removes the group or entry with the given UUID from groups or their descendants
+func removeItem(groups *[]Group, uuid string) (Item, bool) {
+	for i := range *groups {
+		group := &(*groups)[i]
+		if group.UUID == uuid {
+			removed := *group
+			// Use full slice expressions so that copies which share the same array aren't affected
+			*groups = append((*groups)[:i:i], (*groups)[i+1:]...)
+			return removed, true
+		}
+		for j, entry := range group.Entries {
+			if entry.UUID == uuid {
+				group.Entries = append(group.Entries[:j:j], group.Entries[j+1:]...)
+				return entry, true
+			}
+		}
+		if item, found := removeItem(&group.Groups, uuid); found {
+			return item, true
+		}
+	}
+	return nil, false
+}
+
+// insertAt returns a copy of items with item inserted at index, or appended if index is out of range.
+// The copy doesn't share its array with items, so that copies of the containing group aren't affected.
+func insertAt[T any](items []T, index int, item T) []T {
+	if index < 0 || index > len(items) {
+		index = len(items)
+	}
+	inserted := make([]T, 0, len(items)+1)
+	inserted = append(inserted, items[:index]...)
+	inserted = append(inserted, item)
+	return append(inserted, items[index:]...)
+}
+
+// itemUUIDs returns the UUID of an item and of everything it contains
+func itemUUIDs(item Item) []string {
+	uuids := []string{item.GetUUID()}
+	if group, ok := item.(Group); ok {
+		for _, entry := range group.Entries {
+			uuids = append(uuids, entry.UUID)
+		}
+		for _, subgroup := range group.Groups {
+			uuids = append(uuids, itemUUIDs(subgroup)...)
+		}
+	}
+	return uuids
+}
+
+// forgetDeleted removes the given UUIDs from the deleted objects
+func (d *Document) forgetDeleted(uuids ...string) {
+	remove := map[string]bool{}
+	for _, uuid := range uuids {
+		remove[uuid] = true
+	}
+	kept := make([]DeletedObject, 0, len(d.Root.DeletedObjects))
+	for _, deleted := range d.Root.DeletedObjects {
+		if !remove[deleted.UUID] {
+			kept = append(kept, deleted)
+		}
+	}
+	d.Root.DeletedObjects = kept
+}
+
+// setMissingTimes sets the times which haven't been set yet to t
+func setMissingTimes(times *Times, t time.Time) {
+	for _, field := range []*time.Time{&times.CreationTime, &times.LastModificationTime, &times.LastAccessTime, &times.LocationChanged} {
+		if field.IsZero() {
+			*field = t
+		}
+	}
+}
+
+// withMissingTimes returns a copy of group in which the missing times of the group and everything it
+// contains are set to t
+func withMissingTimes(group Group, t time.Time) Group {
+	setMissingTimes(&group.Times, t)
+	group.Entries = append([]Entry(nil), group.Entries...)
+	for i := range group.Entries {
+		setMissingTimes(&group.Entries[i].Times, t)
+	}
+	group.Groups = append([]Group(nil), group.Groups...)
+	for i := range group.Groups {
+		group.Groups[i] = withMissingTimes(group.Groups[i], t)
+	}
+	return group
+}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fixTime makes now return t until the returned function is called
+func fixTime(t time.Time) func() {
+	original := now
+	now = func() time.Time { return t }
+	return func() { now = original }
+}
+
+func TestNewUUID(t *testing.T) {
+	assert := assert.New(t)
+
+	a, err := NewUUID()
+	assert.Nil(err)
+	b, err := NewUUID()
+	assert.Nil(err)
+	assert.Len(a, 24)
+	assert.NotEqual(a, b)
+}
+
+func TestAddGroupTimes(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	t1 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer fixTime(t1)()
+
+	existing := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	kept := newTestEntry("CAgICAgICAgICAgICAgICA==", map[string]string{"Title": "Kept"})
+	kept.Times.CreationTime = existing
+	group := Group{
+		UUID:    "BwcHBwcHBwcHBwcHBwcHBw==",
+		Name:    "New",
+		Entries: []Entry{newTestEntry("CQkJCQkJCQkJCQkJCQkJCQ==", map[string]string{"Title": "Fresh"}), kept},
+	}
+	assert.Nil(d.AddGroup("AAAAAAAAAAAAAAAAAAAAAA==", group))
+	assert.NotNil(d.AddGroup("AAAAAAAAAAAAAAAAAAAAAA==", Group{UUID: "ZZZZ", Entries: []Entry{kept}}))
+	// The group passed in isn't modified
+	assert.True(group.Entries[0].Times.CreationTime.IsZero())
+
+	l, err := d.Resolve("Root/New")
+	if assert.Nil(err) {
+		added := l.Item.(Group)
+		assert.Equal(t1, added.Times.CreationTime)
+		assert.Equal(t1, added.Times.LocationChanged)
+		assert.Equal(t1, added.Entries[0].Times.CreationTime)
+		assert.Equal(existing, added.Entries[1].Times.CreationTime)
+		assert.Equal(t1, added.Entries[1].Times.LastModificationTime)
+	}
+}
+
+func TestRemoveItem(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	t1 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer fixTime(t1)()
+
+	item, err := d.RemoveItem("BAQEBAQEBAQEBAQEBAQEBA==")
+	assert.Nil(err)
+	assert.Equal("Internet", ItemName(item))
+	assert.Equal([]DeletedObject{
+		{"BAQEBAQEBAQEBAQEBAQEBA==", t1},
+		{"BQUFBQUFBQUFBQUFBQUFBQ==", t1},
+		{"BgYGBgYGBgYGBgYGBgYGBg==", t1},
+	}, d.Root.DeletedObjects)
+
+	// Adding it back undoes the deletion
+	assert.Nil(d.AddGroup("AAAAAAAAAAAAAAAAAAAAAA==", item.(Group)))
+	assert.Empty(d.Root.DeletedObjects)
+	_, err = d.Resolve("Root/Internet/GitHub")
+	assert.Nil(err)
+}
+
+func TestInsertItem(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	index, err := d.ItemIndex("AgICAgICAgICAgICAgICAg==")
+	assert.Nil(err)
+	assert.Equal(1, index)
+	item, err := d.RemoveItem("AgICAgICAgICAgICAgICAg==")
+	assert.Nil(err)
+
+	// Times are kept, even if they aren't set
+	assert.Nil(d.InsertItem("AAAAAAAAAAAAAAAAAAAAAA==", index, item))
+	assert.Empty(d.Root.DeletedObjects)
+	assert.Equal(pathTestDocument().Root.Groups, d.Root.Groups)
+	assert.NotNil(d.InsertItem("AAAAAAAAAAAAAAAAAAAAAA==", 0, item))
+	assert.Equal(NoSuchItemError{"nope"}, d.InsertItem("nope", 0, Entry{UUID: "BwcHBwcHBwcHBwcHBwcHBw=="}))
+
+	// Out of range indices append
+	assert.Nil(d.InsertItem("", 5, Group{UUID: "BwcHBwcHBwcHBwcHBwcHBw==", Name: "Last"}))
+	index, err = d.ItemIndex("BwcHBwcHBwcHBwcHBwcHBw==")
+	assert.Nil(err)
+	assert.Equal(1, index)
+	_, err = d.ItemIndex("nope")
+	assert.Equal(NoSuchItemError{"nope"}, err)
+}
+
+func TestMoveItem(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	t1 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer fixTime(t1)()
+
+	assert.Nil(d.MoveItem("BQUFBQUFBQUFBQUFBQUFBQ==", "AAAAAAAAAAAAAAAAAAAAAA=="))
+	l, err := d.Resolve("Root/GitHub")
+	if assert.Nil(err) {
+		assert.Equal(t1, l.Item.(Entry).Times.LocationChanged)
+	}
+	_, err = d.Resolve("Root/Internet/GitHub")
+	assert.Equal(NoSuchPathError{"Root/Internet/GitHub"}, err)
+
+	assert.Nil(d.MoveItem("BgYGBgYGBgYGBgYGBgYGBg==", ""))
+	_, err = d.Resolve(`back\\slash`)
+	assert.Nil(err)
+
+	assert.NotNil(d.MoveItem("AAAAAAAAAAAAAAAAAAAAAA==", "BAQEBAQEBAQEBAQEBAQEBA=="))
+	assert.NotNil(d.MoveItem("AQEBAQEBAQEBAQEBAQEBAQ==", ""))
+	assert.NotNil(d.MoveItem("AQEBAQEBAQEBAQEBAQEBAQ==", "BQUFBQUFBQUFBQUFBQUFBQ=="))
+	assert.Equal(NoSuchItemError{"nope"}, d.MoveItem("nope", ""))
+	assert.Equal(NoSuchItemError{"nope"}, d.MoveItem("AQEBAQEBAQEBAQEBAQEBAQ==", "nope"))
+	_, err = d.Resolve(`Root/a\/b`)
+	assert.Nil(err)
+}
+
+func TestUpdateGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	t1 := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	defer fixTime(t1)()
+
+	l, _ := d.Resolve("Root/Internet")
+	group := l.Item.(Group).CopyMeta().(Group)
+	group.Name = "Web"
+	assert.Nil(d.UpdateGroup(group))
+	l, err := d.Resolve("Root/Web")
+	if assert.Nil(err) {
+		updated := l.Item.(Group)
+		assert.Equal(t1, updated.Times.LastModificationTime)
+		// Contents are kept
+		assert.Len(updated.Entries, 1)
+		assert.Len(updated.Groups, 1)
+	}
+
+	// Explicitly set times are kept
+	explicit := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	group.Name = "Internet"
+	group.Times.LastModificationTime = explicit
+	assert.Nil(d.UpdateGroup(group))
+	l, _ = d.Resolve("Root/Internet")
+	assert.Equal(explicit, l.Item.(Group).Times.LastModificationTime)
+
+	assert.Equal(NoSuchItemError{"nope"}, d.UpdateGroup(Group{UUID: "nope"}))
+}
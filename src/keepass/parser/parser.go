@@ -1,7 +1,9 @@
 package parser
 
 import (
+	"bytes"
 	"encoding/xml"
+	"io"
 	"time"
 
 	"github.com/Zaphoood/tresor/src/keepass/crypto"
@@ -131,7 +133,8 @@ type BinaryReferenceValue struct {
 type AutoType struct {
 	Enabled                 wrappers.Bool
 	DataTransferObfuscation int
-	Association             *Association `xml:",omitempty"`
+	DefaultSequence         string        `xml:",omitempty"`
+	Associations            []Association `xml:"Association"`
 }
 
 type Association struct {
@@ -146,11 +149,17 @@ type String struct {
 }
 
 func Parse(b []byte, innerRandomStreamKey [32]byte) (*Document, error) {
+	return Decode(bytes.NewReader(b), innerRandomStreamKey)
+}
+
+// Decode parses the XML content of a database while it is being read from r, so that it doesn't need to be
+// held in memory. It stops at the end of the document.
+func Decode(r io.Reader, innerRandomStreamKey [32]byte) (*Document, error) {
 	p := NewDocument()
 
 	salsa := crypto.NewSalsa20Stream(innerRandomStreamKey)
 	wrappers.SetInnerRandomStream(salsa)
-	err := xml.Unmarshal(b, &p)
+	err := xml.NewDecoder(r).Decode(&p)
 	if err != nil {
 		return nil, err
 	}
@@ -158,16 +167,24 @@ func Parse(b []byte, innerRandomStreamKey [32]byte) (*Document, error) {
 }
 
 func Unparse(d *Document, key [32]byte) ([]byte, error) {
+	var buf bytes.Buffer
+	err := Encode(&buf, d, key)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Encode writes the XML content of a database to w while it is being generated
+func Encode(w io.Writer, d *Document, key [32]byte) error {
 	salsa := crypto.NewSalsa20Stream(key)
 	wrappers.SetInnerRandomStream(salsa)
 
-	marshalled, err := xml.MarshalIndent(d, "", "\t")
+	_, err := io.WriteString(w, xml.Header)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	out := make([]byte, 0, len(xml.Header)+len(marshalled))
-	out = append(out, xml.Header...)
-	out = append(out, marshalled...)
-
-	return out, nil
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	return enc.Encode(d)
 }
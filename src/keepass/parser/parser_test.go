@@ -2,7 +2,7 @@ package parser
 
 import (
 	"encoding/hex"
-	"io/ioutil"
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -16,20 +16,22 @@ const (
 	PROTECTED_STREAM_KEY = "be3723cc9496ac62a51976df67314e68203140178c1aba143ce6c2441f1068f4"
 )
 
-func parseDecryptedExample(t *testing.T) *Document {
+// readDecryptedExample returns the content of the decrypted example database and its protected stream key
+func readDecryptedExample(t testing.TB) ([]byte, [32]byte) {
 	key, err := hex.DecodeString(PROTECTED_STREAM_KEY)
 	if err != nil || len(key) != 32 {
 		t.Fatal("Failed to decode hex string")
 	}
-	xmlFile, err := os.Open("../test/example_decrypted.xml")
-	defer xmlFile.Close()
+	content, err := os.ReadFile("../test/example_decrypted.xml")
 	if err != nil {
 		t.Fatal(err)
 	}
+	return content, *(*[32]byte)(key)
+}
 
-	content, _ := ioutil.ReadAll(xmlFile)
-
-	parsed, err := Parse(content, *(*[32]byte)(key))
+func parseDecryptedExample(t *testing.T) *Document {
+	content, key := readDecryptedExample(t)
+	parsed, err := Parse(content, key)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -57,6 +59,10 @@ func TestParse(t *testing.T) {
 	result := firstEntry.TryGet("foo", fallback)
 	assert.Equal(fallback, result)
 
+	if assert.Equal(1, len(firstEntry.AutoType.Associations)) {
+		assert.Equal("Target Window", firstEntry.AutoType.Associations[0].Window)
+	}
+
 	assert.Equal(2, len(firstEntry.BinaryRefs))
 	for _, bref := range firstEntry.BinaryRefs {
 		if bref.Reference.ID == 0 {
@@ -159,3 +165,116 @@ func TestUpdateEntry(t *testing.T) {
 	assert.Equal("foo", retrievedEntry.Strings[0].Key)
 	assert.Equal("bar", retrievedEntry.Strings[0].Value.Inner)
 }
+
+func TestAddRemoveEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	document := parseDecryptedExample(t)
+	groupUUID := "M0Gbdz4OmEaVH1j8pqgWFA=="
+	entry := Entry{UUID: "bmV3IGVudHJ5IHV1aWQhIQ=="}
+
+	assert.Equal(NoSuchItemError{"no such group"}, document.AddEntry("no such group", entry))
+	assert.Nil(document.AddEntry(groupUUID, entry))
+	assert.NotNil(document.AddEntry(groupUUID, entry))
+	path, found := document.FindPath(entry.UUID)
+	if assert.True(found) {
+		assert.Equal([]string{groupUUID, entry.UUID}, path)
+	}
+
+	_, err := document.RemoveItem(entry.UUID)
+	assert.Nil(err)
+	_, err = document.RemoveItem(entry.UUID)
+	assert.Equal(NoSuchItemError{entry.UUID}, err)
+	_, found = document.FindPath(entry.UUID)
+	assert.False(found)
+}
+
+func TestSetField(t *testing.T) {
+	assert := assert.New(t)
+
+	entry := Entry{Strings: []String{{Key: "Title", Value: wrappers.Value{Inner: "foo"}}}}
+	copied := entry
+
+	assert.True(entry.SetField("Title", "bar"))
+	assert.False(entry.SetField("Title", "bar"))
+	assert.True(entry.SetField("New", "baz"))
+
+	assert.Equal("bar", entry.TryGet("Title", ""))
+	assert.Equal("baz", entry.TryGet("New", ""))
+	assert.Equal("foo", copied.TryGet("Title", ""))
+	assert.Equal(1, len(copied.Strings))
+}
+
+func TestTags(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"a", "b c", "d"}, SplitTags(" a;b c,,d ;"))
+	assert.Equal([]string{}, SplitTags(""))
+
+	e := Entry{Tags: "work,Finance"}
+	assert.True(e.HasTag("finance"))
+	assert.False(e.HasTag("fin"))
+
+	changed, err := e.AddTag("shared")
+	assert.Nil(err)
+	assert.True(changed)
+	assert.Equal("work;Finance;shared", e.Tags)
+
+	changed, err = e.AddTag("WORK")
+	assert.Nil(err)
+	assert.False(changed)
+
+	_, err = e.AddTag("a;b")
+	assert.NotNil(err)
+	_, err = e.AddTag(" ")
+	assert.NotNil(err)
+
+	assert.True(e.RemoveTag("finance"))
+	assert.False(e.RemoveTag("finance"))
+	assert.Equal([]string{"work", "shared"}, e.TagList())
+}
+
+func FuzzParse(f *testing.F) {
+	content, err := os.ReadFile("../test/example_decrypted.xml")
+	if err != nil {
+		f.Fatal(err)
+	}
+	f.Add(content)
+	key, _ := hex.DecodeString(PROTECTED_STREAM_KEY)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Parse(data, *(*[32]byte)(key))
+	})
+}
+
+// BenchmarkParseProtected parses a document with many protected values, which share one inner random stream
+func BenchmarkParseProtected(b *testing.B) {
+	content, key := readDecryptedExample(b)
+	d, err := Parse(content, key)
+	if err != nil {
+		b.Fatal(err)
+	}
+	group := &d.Root.Groups[0]
+	for i := 0; i < 5000; i++ {
+		entry := newTestEntry(fmt.Sprintf("%022d==", i), map[string]string{"Title": fmt.Sprintf("Entry %d", i)})
+		entry.Strings = append(entry.Strings, String{Key: "Password", Value: wrappers.Value{Inner: "correct horse battery", Protected: true}})
+		group.Entries = append(group.Entries, entry)
+	}
+	content, err = Unparse(d, key)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.SetBytes(int64(len(content)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		parsed, err := Parse(content, key)
+		if err != nil {
+			b.Fatal(err)
+		}
+		entries := parsed.Root.Groups[0].Entries
+		if password, _ := entries[len(entries)-1].Get("Password"); password.Inner != "correct horse battery" {
+			b.Fatalf("Password of last entry is '%s'", password.Inner)
+		}
+	}
+}
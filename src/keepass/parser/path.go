@@ -0,0 +1,221 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Separator between names in a name path, e. g. "Root/Internet/GitHub"
+const PATH_SEPARATOR = '/'
+
+// Escapes PATH_SEPARATOR and itself in names
+const PATH_ESCAPE = '\\'
+
+// SkipGroup may be returned by the function passed to Walk in order to skip the contents of a group
+var SkipGroup = errors.New("Skip this group")
+
+// stopWalk is used internally to end a walk early
+var stopWalk = errors.New("Stop walking")
+
+// Location is a group or entry along with the path leading to it
+type Location struct {
+	Item Item
+	// UUIDs of the item's parent groups, followed by the item's own UUID
+	Path []string
+	// Names of the item's parent groups, followed by the item's own name
+	Names []string
+}
+
+// Depth returns the number of groups containing the item, which is zero for top-level groups
+func (l Location) Depth() int {
+	return len(l.Path) - 1
+}
+
+// NoSuchPathError is returned by Resolve if no item has the given path
+type NoSuchPathError struct {
+	Path string
+}
+
+func (e NoSuchPathError) Error() string {
+	return fmt.Sprintf("No such group or entry: %s", e.Path)
+}
+
+// AmbiguousPathError is returned by Resolve if more than one item has the given path
+type AmbiguousPathError struct {
+	Path  string
+	Count int
+}
+
+func (e AmbiguousPathError) Error() string {
+	return fmt.Sprintf("Ambiguous path '%s': %d items match", e.Path, e.Count)
+}
+
+// SplitPath splits a name path at every unescaped PATH_SEPARATOR. Leading and trailing separators are ignored.
+func SplitPath(path string) []string {
+	names := []string{}
+	var current strings.Builder
+	escaped := false
+	for _, r := range path {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == PATH_ESCAPE:
+			escaped = true
+		case r == PATH_SEPARATOR:
+			names = append(names, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	names = append(names, current.String())
+	if len(names[0]) == 0 {
+		names = names[1:]
+	}
+	if len(names) > 0 && len(names[len(names)-1]) == 0 {
+		names = names[:len(names)-1]
+	}
+	return names
+}
+
+// EscapeName escapes PATH_SEPARATOR and PATH_ESCAPE in a group name or entry title
+func EscapeName(name string) string {
+	var b strings.Builder
+	for _, r := range name {
+		if r == PATH_SEPARATOR || r == PATH_ESCAPE {
+			b.WriteRune(PATH_ESCAPE)
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// JoinPath is the inverse of SplitPath
+func JoinPath(names []string) string {
+	escaped := make([]string, len(names))
+	for i, name := range names {
+		escaped[i] = EscapeName(name)
+	}
+	return strings.Join(escaped, string(PATH_SEPARATOR))
+}
+
+// ItemName returns the name of a group or the title of an entry
+func ItemName(item Item) string {
+	switch item := item.(type) {
+	case Group:
+		return item.Name
+	case Entry:
+		return item.TryGet("Title", "")
+	}
+	return ""
+}
+
+// Walk calls fn for every group and entry in the document, in document order: each group is followed by
+// its entries and then by its subgroups. Entries are passed including their history, groups including
+// their contents. If fn returns SkipGroup for a group, its contents are skipped. Any other error ends the
+// walk and is returned.
+func (d *Document) Walk(fn func(l Location) error) error {
+	err := walkGroups(d.Root.Groups, []string{}, []string{}, fn)
+	if errors.Is(err, stopWalk) {
+		return nil
+	}
+	return err
+}
+
+// WalkEntries calls fn for every entry in the document, in document order, leaving out the recycle bin
+func (d *Document) WalkEntries(fn func(l Location, e Entry)) {
+	d.Walk(func(l Location) error {
+		switch item := l.Item.(type) {
+		case Group:
+			if len(d.Meta.RecycleBinUUID) > 0 && item.UUID == d.Meta.RecycleBinUUID {
+				return SkipGroup
+			}
+		case Entry:
+			fn(l, item)
+		}
+		return nil
+	})
+}
+
+func walkGroups(groups []Group, path, names []string, fn func(l Location) error) error {
+	for _, group := range groups {
+		// Use full slice expressions so that locations don't share their underlying arrays
+		groupPath := append(path[:len(path):len(path)], group.UUID)
+		groupNames := append(names[:len(names):len(names)], group.Name)
+		err := fn(Location{group, groupPath, groupNames})
+		if errors.Is(err, SkipGroup) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		for _, entry := range group.Entries {
+			err = fn(Location{
+				entry,
+				append(groupPath[:len(groupPath):len(groupPath)], entry.UUID),
+				append(groupNames[:len(groupNames):len(groupNames)], entry.TryGet("Title", "")),
+			})
+			if err != nil && !errors.Is(err, SkipGroup) {
+				return err
+			}
+		}
+		err = walkGroups(group.Groups, groupPath, groupNames, fn)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve returns the group or entry with the given name path, e. g. "Root/Internet/GitHub". The path
+// starts with the name of a top-level group; a PATH_SEPARATOR or PATH_ESCAPE within a name must be escaped
+// with PATH_ESCAPE. Returns a NoSuchPathError if there is no such item, and an AmbiguousPathError if
+// several items have the same path, in which case they can be told apart using ResolveAll.
+func (d *Document) Resolve(path string) (Location, error) {
+	matches := d.ResolveAll(path)
+	switch len(matches) {
+	case 0:
+		return Location{}, NoSuchPathError{path}
+	case 1:
+		return matches[0], nil
+	default:
+		return Location{}, AmbiguousPathError{path, len(matches)}
+	}
+}
+
+// ResolveAll returns every group and entry with the given name path, in document order
+func (d *Document) ResolveAll(path string) []Location {
+	names := SplitPath(path)
+	matches := []Location{}
+	if len(names) == 0 {
+		return matches
+	}
+	d.Walk(func(l Location) error {
+		depth := l.Depth()
+		if l.Names[depth] != names[depth] {
+			return SkipGroup
+		}
+		if depth == len(names)-1 {
+			matches = append(matches, l)
+			return SkipGroup
+		}
+		return nil
+	})
+	return matches
+}
+
+// PathOf returns the names of the parent groups of the item with the given UUID, followed by the item's
+// own name, and false if there is no such item
+func (d *Document) PathOf(uuid string) ([]string, bool) {
+	var names []string
+	d.Walk(func(l Location) error {
+		if l.Item.GetUUID() == uuid {
+			names = l.Names
+			return stopWalk
+		}
+		return nil
+	})
+	return names, names != nil
+}
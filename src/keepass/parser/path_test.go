@@ -0,0 +1,136 @@
+package parser
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func pathTestDocument() *Document {
+	d := NewDocument()
+	d.Root.Groups = []Group{{
+		UUID: "AAAAAAAAAAAAAAAAAAAAAA==",
+		Name: "Root",
+		Entries: []Entry{
+			newTestEntry("AQEBAQEBAQEBAQEBAQEBAQ==", map[string]string{"Title": "a/b"}),
+			newTestEntry("AgICAgICAgICAgICAgICAg==", map[string]string{"Title": "Twin"}),
+			newTestEntry("AwMDAwMDAwMDAwMDAwMDAw==", map[string]string{"Title": "Twin"}),
+		},
+		Groups: []Group{{
+			UUID:    "BAQEBAQEBAQEBAQEBAQEBA==",
+			Name:    "Internet",
+			Entries: []Entry{newTestEntry("BQUFBQUFBQUFBQUFBQUFBQ==", map[string]string{"Title": "GitHub"})},
+			Groups:  []Group{{UUID: "BgYGBgYGBgYGBgYGBgYGBg==", Name: `back\slash`}},
+		}},
+	}}
+	return d
+}
+
+func TestSplitJoinPath(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"Root", "Internet", "GitHub"}, SplitPath("/Root/Internet/GitHub/"))
+	assert.Equal([]string{"Root", "a/b", `c\d`}, SplitPath(`Root/a\/b/c\\d`))
+	assert.Equal([]string{}, SplitPath(""))
+	assert.Equal(`Root/a\/b/c\\d`, JoinPath([]string{"Root", "a/b", `c\d`}))
+}
+
+func TestWalk(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	names := []string{}
+	depths := []int{}
+	d.Walk(func(l Location) error {
+		names = append(names, JoinPath(l.Names))
+		depths = append(depths, l.Depth())
+		assert.Equal(l.Item.GetUUID(), l.Path[len(l.Path)-1])
+		return nil
+	})
+	assert.Equal([]string{"Root", `Root/a\/b`, "Root/Twin", "Root/Twin", "Root/Internet", "Root/Internet/GitHub", `Root/Internet/back\\slash`}, names)
+	assert.Equal([]int{0, 1, 1, 1, 1, 2, 2}, depths)
+
+	names = []string{}
+	d.Walk(func(l Location) error {
+		names = append(names, JoinPath(l.Names))
+		if ItemName(l.Item) == "Internet" {
+			return SkipGroup
+		}
+		return nil
+	})
+	assert.Equal([]string{"Root", `Root/a\/b`, "Root/Twin", "Root/Twin", "Root/Internet"}, names)
+
+	stop := errors.New("stop")
+	count := 0
+	err := d.Walk(func(l Location) error {
+		count++
+		return stop
+	})
+	assert.Equal(stop, err)
+	assert.Equal(1, count)
+}
+
+func TestWalkEntries(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	d.Meta.RecycleBinUUID = "BAQEBAQEBAQEBAQEBAQEBA=="
+	names := []string{}
+	d.WalkEntries(func(l Location, e Entry) {
+		assert.Equal(e.UUID, l.Item.GetUUID())
+		names = append(names, JoinPath(l.Names))
+	})
+	assert.Equal([]string{`Root/a\/b`, "Root/Twin", "Root/Twin"}, names)
+}
+
+func TestResolve(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	l, err := d.Resolve("Root/Internet/GitHub")
+	if assert.Nil(err) {
+		assert.Equal("BQUFBQUFBQUFBQUFBQUFBQ==", l.Item.GetUUID())
+		assert.Equal([]string{"AAAAAAAAAAAAAAAAAAAAAA==", "BAQEBAQEBAQEBAQEBAQEBA==", "BQUFBQUFBQUFBQUFBQUFBQ=="}, l.Path)
+		assert.Equal([]string{"Root", "Internet", "GitHub"}, l.Names)
+	}
+	l, err = d.Resolve(`Root/a\/b`)
+	if assert.Nil(err) {
+		assert.Equal("AQEBAQEBAQEBAQEBAQEBAQ==", l.Item.GetUUID())
+	}
+	l, err = d.Resolve(`Root/Internet/back\\slash`)
+	if assert.Nil(err) {
+		assert.Equal("BgYGBgYGBgYGBgYGBgYGBg==", l.Item.GetUUID())
+	}
+
+	_, err = d.Resolve("Root/a/b")
+	assert.Equal(NoSuchPathError{"Root/a/b"}, err)
+	_, err = d.Resolve("Internet/GitHub")
+	assert.Equal(NoSuchPathError{"Internet/GitHub"}, err)
+	_, err = d.Resolve("")
+	assert.Equal(NoSuchPathError{""}, err)
+
+	_, err = d.Resolve("Root/Twin")
+	assert.Equal(AmbiguousPathError{"Root/Twin", 2}, err)
+	matches := d.ResolveAll("Root/Twin")
+	if assert.Len(matches, 2) {
+		assert.Equal("AgICAgICAgICAgICAgICAg==", matches[0].Item.GetUUID())
+		assert.Equal("AwMDAwMDAwMDAwMDAwMDAw==", matches[1].Item.GetUUID())
+	}
+}
+
+func TestPathOf(t *testing.T) {
+	assert := assert.New(t)
+
+	d := pathTestDocument()
+	names, found := d.PathOf("BQUFBQUFBQUFBQUFBQUFBQ==")
+	if assert.True(found) {
+		assert.Equal([]string{"Root", "Internet", "GitHub"}, names)
+	}
+	names, found = d.PathOf("AAAAAAAAAAAAAAAAAAAAAA==")
+	if assert.True(found) {
+		assert.Equal([]string{"Root"}, names)
+	}
+	_, found = d.PathOf("no such uuid")
+	assert.False(found)
+}
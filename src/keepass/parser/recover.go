@@ -0,0 +1,257 @@
+package parser
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Zaphoood/tresor/src/keepass/crypto"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+)
+
+// Name of the group which entries that aren't inside any group are recovered to
+const RECOVERED_GROUP_NAME = "Recovered"
+
+// ParseLenient parses the XML content of a damaged database. Unlike Parse, it doesn't give up at the first
+// error: malformed XML is skipped, elements which aren't closed are closed, and groups and entries which
+// can't be decoded are left out. It returns what could be recovered along with an error for everything that
+// was lost. Note that protected values after a lost one are garbled, since they are encrypted with a single
+// stream in document order.
+func ParseLenient(b []byte, innerRandomStreamKey [32]byte) (*Document, []error) {
+	salsa := crypto.NewSalsa20Stream(innerRandomStreamKey)
+	wrappers.SetInnerRandomStream(salsa)
+
+	repaired, lost := repairXML(b)
+	r := recoverer{dec: xml.NewDecoder(bytes.NewReader(repaired)), lost: lost}
+	p := NewDocument()
+	p.XMLName.Local = "KeePassFile"
+	p.Root.XMLName.Local = "Root"
+	orphans := []Entry{}
+	for {
+		token, err := r.dec.Token()
+		if err != nil {
+			// The repaired XML is well-formed, so this is the end of input
+			break
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		// Other elements, such as KeePassFile and Root, are descended into
+		switch start.Name.Local {
+		case "Meta":
+			if err := r.decode(start, &p.Meta); err != nil {
+				r.lose("Meta", err)
+			}
+		case "Group":
+			p.Root.Groups = append(p.Root.Groups, r.group(start))
+		case "Entry":
+			entry, err := r.entry(start)
+			if err != nil {
+				r.lose("Entry outside of any group", err)
+				continue
+			}
+			orphans = append(orphans, entry)
+		case "DeletedObjects":
+			var deleted struct {
+				Objects []DeletedObject `xml:"DeletedObject"`
+			}
+			if err := r.decode(start, &deleted); err != nil {
+				r.lose("Deleted objects", err)
+				continue
+			}
+			p.Root.DeletedObjects = deleted.Objects
+		}
+	}
+
+	if len(orphans) > 0 {
+		group := Group{Name: RECOVERED_GROUP_NAME, Entries: orphans}
+		group.UUID, _ = NewUUID()
+		p.Root.Groups = append(p.Root.Groups, group)
+	}
+	return p, r.lost
+}
+
+type recoverer struct {
+	dec  *xml.Decoder
+	lost []error
+}
+
+// element consumes the element which starts with start, including its content, from the decoder and
+// returns it as XML
+func (r *recoverer) element(start xml.StartElement) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := xml.NewEncoder(&buf)
+	err := enc.EncodeToken(start)
+	for depth := 1; depth > 0 && err == nil; {
+		var token xml.Token
+		token, err = r.dec.Token()
+		if err != nil {
+			break
+		}
+		switch token.(type) {
+		case xml.StartElement:
+			depth++
+		case xml.EndElement:
+			depth--
+		}
+		err = enc.EncodeToken(token)
+	}
+	if err == nil {
+		err = enc.Flush()
+	}
+	return buf.Bytes(), err
+}
+
+// decode consumes the element which starts with start and decodes it into v
+func (r *recoverer) decode(start xml.StartElement, v interface{}) error {
+	raw, err := r.element(start)
+	if err != nil {
+		return err
+	}
+	return xml.Unmarshal(raw, v)
+}
+
+// lose records that something couldn't be recovered
+func (r *recoverer) lose(what string, err error) {
+	r.lost = append(r.lost, fmt.Errorf("%s: %w", what, err))
+}
+
+// entry decodes the entry which starts with start. Entries without UUID are given a new one.
+func (r *recoverer) entry(start xml.StartElement) (Entry, error) {
+	var entry Entry
+	err := r.decode(start, &entry)
+	if err != nil {
+		return Entry{}, err
+	}
+	if len(entry.UUID) == 0 {
+		entry.UUID, _ = NewUUID()
+	}
+	return entry, nil
+}
+
+// group decodes the group which starts with start. Its entries and subgroups are decoded one by one, so
+// that only the ones which are damaged are lost. If the group's own fields can't be decoded, it is named
+// after RECOVERED_GROUP_NAME.
+func (r *recoverer) group(start xml.StartElement) Group {
+	fields := []byte("<Group>")
+	var entries []Entry
+	var groups []Group
+	// Lost entries are described by the group's name, which is only known at the end
+	entryErrs := []error{}
+	for {
+		token, err := r.dec.Token()
+		if err != nil {
+			break
+		}
+		if _, ok := token.(xml.EndElement); ok {
+			break
+		}
+		t, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch t.Name.Local {
+		case "Entry":
+			entry, err := r.entry(t)
+			if err != nil {
+				entryErrs = append(entryErrs, err)
+				continue
+			}
+			entries = append(entries, entry)
+		case "Group":
+			groups = append(groups, r.group(t))
+		default:
+			raw, err := r.element(t)
+			if err == nil {
+				fields = append(fields, raw...)
+			}
+		}
+	}
+	fields = append(fields, "</Group>"...)
+
+	var group Group
+	err := xml.Unmarshal(fields, &group)
+	if err != nil {
+		r.lose("Fields of a group", err)
+		group = Group{Name: RECOVERED_GROUP_NAME}
+	}
+	if len(group.UUID) == 0 {
+		group.UUID, _ = NewUUID()
+	}
+	for _, err := range entryErrs {
+		r.lose(fmt.Sprintf("Entry in group '%s'", group.Name), err)
+	}
+	group.Entries = entries
+	group.Groups = groups
+	return group
+}
+
+// repairXML turns b into well-formed XML. Parts which can't be tokenized are skipped up to the next tag,
+// end tags which don't match are dropped and elements which are left open are closed. Comments, processing
+// instructions and directives are dropped as well. It returns an error for each part that was skipped.
+func repairXML(b []byte) ([]byte, []error) {
+	var out bytes.Buffer
+	enc := xml.NewEncoder(&out)
+	lost := []error{}
+	open := []xml.Name{}
+	closeTo := func(depth int) {
+		for len(open) > depth {
+			enc.EncodeToken(xml.EndElement{Name: open[len(open)-1]})
+			open = open[:len(open)-1]
+		}
+	}
+
+	offset := 0
+	dec := newLenientDecoder(b)
+	for {
+		token, err := dec.RawToken()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			errOffset := offset + int(dec.InputOffset())
+			lost = append(lost, fmt.Errorf("Malformed XML at byte %d: %w", errOffset, err))
+			if errOffset >= len(b) {
+				break
+			}
+			next := bytes.IndexByte(b[errOffset+1:], '<')
+			if next < 0 {
+				break
+			}
+			offset = errOffset + 1 + next
+			dec = newLenientDecoder(b[offset:])
+			continue
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if enc.EncodeToken(t) == nil {
+				open = append(open, t.Name)
+			}
+		case xml.EndElement:
+			// Also close the elements inside the matching one which weren't closed
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == t.Name {
+					closeTo(i)
+					break
+				}
+			}
+		case xml.CharData:
+			if len(open) > 0 {
+				enc.EncodeToken(t)
+			}
+		}
+	}
+	closeTo(0)
+	enc.Flush()
+	return out.Bytes(), lost
+}
+
+func newLenientDecoder(b []byte) *xml.Decoder {
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.Strict = false
+	return dec
+}
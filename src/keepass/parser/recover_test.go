@@ -0,0 +1,74 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLenientIntact(t *testing.T) {
+	assert := assert.New(t)
+
+	content, key := readDecryptedExample(t)
+	expected, err := Parse(content, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	recovered, lost := ParseLenient(content, key)
+	assert.Empty(lost)
+	assert.Equal(expected, recovered)
+}
+
+func TestParseLenientDamaged(t *testing.T) {
+	assert := assert.New(t)
+
+	content, key := readDecryptedExample(t)
+	// Damage the second entry of the top group, so that it can't be decoded
+	damaged := string(content)
+	i := strings.Index(damaged, "ib2WJReSIE6e3CX7sBft9g==")
+	damaged = damaged[:i] + strings.Replace(damaged[i:], "<UsageCount>1</UsageCount>", "<UsageCount>one</UsageCount>", 1)
+	// Damage the XML itself within the first entry's notes
+	damaged = strings.Replace(damaged, "Notes on Email account", "Notes <<& on\x00 Email account", 1)
+
+	d, lost := ParseLenient([]byte(damaged), key)
+	assert.Len(lost, 2)
+	assert.Contains(lost[len(lost)-1].Error(), "Entry in group 'test'")
+	_, err := d.Resolve("test/Sample Entry #2")
+	assert.Equal(NoSuchPathError{"test/Sample Entry #2"}, err)
+	l, err := d.Resolve("test/Email Account")
+	if assert.Nil(err) {
+		entry := l.Item.(Entry)
+		password, _ := entry.Get("Password")
+		assert.Equal("Password", password.Inner)
+	}
+	_, err = d.Resolve("test/General")
+	assert.Nil(err)
+}
+
+func TestParseLenientTruncated(t *testing.T) {
+	assert := assert.New(t)
+
+	content, key := readDecryptedExample(t)
+	d, lost := ParseLenient(content[:len(content)/2], key)
+	assert.NotEmpty(lost)
+	_, err := d.Resolve("test/Email Account")
+	assert.Nil(err)
+
+	d, _ = ParseLenient([]byte("<Entry><UUID>AQEBAQEBAQEBAQEBAQEBAQ==</UUID><String><Key>Title</Key><Value>Lone"), key)
+	l, err := d.Resolve(RECOVERED_GROUP_NAME + "/Lone")
+	if assert.Nil(err) {
+		assert.Equal("AQEBAQEBAQEBAQEBAQEBAQ==", l.Item.GetUUID())
+	}
+}
+
+func FuzzParseLenient(f *testing.F) {
+	content, key := readDecryptedExample(f)
+	f.Add(content)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		d, _ := ParseLenient(data, key)
+		// Whatever was recovered can be written again
+		_, err := Unparse(d, key)
+		assert.Nil(t, err)
+	})
+}
@@ -0,0 +1,231 @@
+package parser
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+)
+
+// Maximum nesting depth of references, as in KeePass
+const MAX_RESOLVE_DEPTH = 12
+
+// Standard fields and the letters by which they are referred to in field references
+var refFields = map[byte]string{
+	'T': "Title",
+	'U': "UserName",
+	'P': "Password",
+	'A': "URL",
+	'N': "Notes",
+}
+
+// Placeholders which are replaced by a field of the entry itself
+var fieldPlaceholders = map[string]string{
+	"TITLE":    "Title",
+	"USERNAME": "UserName",
+	"PASSWORD": "Password",
+	"URL":      "URL",
+	"NOTES":    "Notes",
+}
+
+func isStandardField(key string) bool {
+	for _, field := range refFields {
+		if field == key {
+			return true
+		}
+	}
+	return false
+}
+
+// ResolveField returns the value of the field with the given key of entry e, with all field references
+// (e. g. '{REF:P@I:<uuid>}') and placeholders (e. g. '{USERNAME}') resolved. The second return value is
+// true if the field itself or any field which went into the result is protected, in which case the result
+// should be treated like a protected value, e. g. masked.
+func (d *Document) ResolveField(e *Entry, key string) (string, bool, error) {
+	value, err := e.Get(key)
+	if err != nil {
+		return "", false, err
+	}
+	r := resolver{document: d}
+	resolved, err := r.resolveField(e, key, value, 0)
+	return resolved, r.protected, err
+}
+
+// IsReference checks whether a value refers to a field of another entry, e. g. '{REF:P@I:<uuid>}'
+func IsReference(value string) bool {
+	return strings.HasPrefix(strings.ToUpper(value), "{REF:")
+}
+
+// ResolveValue resolves field references and placeholders in value, where placeholders refer to entry e
+func (d *Document) ResolveValue(e *Entry, value string) (string, error) {
+	r := resolver{document: d}
+	return r.resolve(e, value, 0)
+}
+
+type resolver struct {
+	document *Document
+	// Fields which are currently being resolved, used to detect circular references
+	stack []string
+	// Whether any of the resolved fields is protected
+	protected bool
+}
+
+func (r *resolver) resolveField(e *Entry, key string, value wrappers.Value, depth int) (string, error) {
+	id := e.UUID + "/" + key
+	for _, visited := range r.stack {
+		if visited == id {
+			return "", fmt.Errorf("Circular reference to field '%s' of entry '%s'", key, e.TryGet("Title", e.UUID))
+		}
+	}
+	r.stack = append(r.stack, id)
+	defer func() { r.stack = r.stack[:len(r.stack)-1] }()
+
+	r.protected = r.protected || value.Protected
+	return r.resolve(e, value.Inner, depth)
+}
+
+func (r *resolver) resolve(e *Entry, value string, depth int) (string, error) {
+	if depth > MAX_RESOLVE_DEPTH {
+		return "", fmt.Errorf("Maximum reference depth of %d exceeded", MAX_RESOLVE_DEPTH)
+	}
+	var out strings.Builder
+	for {
+		start := strings.IndexByte(value, '{')
+		if start == -1 {
+			out.WriteString(value)
+			break
+		}
+		end := strings.IndexByte(value[start:], '}')
+		if end == -1 {
+			out.WriteString(value)
+			break
+		}
+		end += start
+		out.WriteString(value[:start])
+
+		placeholder := value[start+1 : end]
+		replacement, ok, err := r.resolvePlaceholder(e, placeholder, depth)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			out.WriteString(replacement)
+		} else {
+			// Unknown placeholders and references to nonexistent entries are left as they are
+			out.WriteString(value[start : end+1])
+		}
+		value = value[end+1:]
+	}
+	return out.String(), nil
+}
+
+// resolvePlaceholder returns the replacement for a placeholder (without braces) and a bool
+// indicating wether the placeholder could be resolved
+func (r *resolver) resolvePlaceholder(e *Entry, placeholder string, depth int) (string, bool, error) {
+	upper := strings.ToUpper(placeholder)
+	if strings.HasPrefix(upper, "REF:") {
+		return r.resolveRef(placeholder[len("REF:"):], depth)
+	}
+	if strings.HasPrefix(upper, "S:") {
+		key := placeholder[len("S:"):]
+		value, err := e.Get(key)
+		if err != nil {
+			return "", false, nil
+		}
+		resolved, err := r.resolveField(e, key, value, depth+1)
+		return resolved, true, err
+	}
+	if key, ok := fieldPlaceholders[upper]; ok {
+		value, err := e.Get(key)
+		if err != nil {
+			return "", true, nil
+		}
+		resolved, err := r.resolveField(e, key, value, depth+1)
+		return resolved, true, err
+	}
+	return "", false, nil
+}
+
+// resolveRef resolves a field reference of the form '<wanted field>@<search in>:<text>'
+func (r *resolver) resolveRef(ref string, depth int) (string, bool, error) {
+	if len(ref) < 4 || ref[1] != '@' || ref[3] != ':' {
+		return "", false, nil
+	}
+	wanted := strings.ToUpper(ref[:1])[0]
+	searchIn := strings.ToUpper(ref[2:3])[0]
+	text := ref[4:]
+
+	target, found := r.findRefTarget(searchIn, text)
+	if !found {
+		return "", false, nil
+	}
+	if wanted == 'I' {
+		return uuidToHex(target.UUID), true, nil
+	}
+	key, ok := refFields[wanted]
+	if !ok {
+		return "", false, nil
+	}
+	value, err := target.Get(key)
+	if err != nil {
+		return "", true, nil
+	}
+	resolved, err := r.resolveField(target, key, value, depth+1)
+	return resolved, true, err
+}
+
+// findRefTarget returns the first entry in the document whose field specified by searchIn
+// contains text (ignoring case). If searchIn is 'I', the entry with the given hex UUID is returned.
+func (r *resolver) findRefTarget(searchIn byte, text string) (*Entry, bool) {
+	text = strings.ToLower(text)
+	var matches func(e *Entry) bool
+	switch searchIn {
+	case 'I':
+		matches = func(e *Entry) bool {
+			return uuidToHex(e.UUID) == strings.ToUpper(text)
+		}
+	case 'O':
+		matches = func(e *Entry) bool {
+			for _, field := range e.Strings {
+				if !isStandardField(field.Key) && strings.Contains(strings.ToLower(field.Value.Inner), text) {
+					return true
+				}
+			}
+			return false
+		}
+	default:
+		key, ok := refFields[searchIn]
+		if !ok {
+			return nil, false
+		}
+		matches = func(e *Entry) bool {
+			return strings.Contains(strings.ToLower(e.TryGet(key, "")), text)
+		}
+	}
+	return findEntryInGroups(r.document.Root.Groups, matches)
+}
+
+func findEntryInGroups(groups []Group, matches func(e *Entry) bool) (*Entry, bool) {
+	for i := range groups {
+		for j := range groups[i].Entries {
+			if matches(&groups[i].Entries[j]) {
+				return &groups[i].Entries[j], true
+			}
+		}
+		if entry, found := findEntryInGroups(groups[i].Groups, matches); found {
+			return entry, true
+		}
+	}
+	return nil, false
+}
+
+// uuidToHex converts a base64 encoded UUID, as used in the XML, into the hex notation used by field references
+func uuidToHex(uuid string) string {
+	decoded, err := base64.StdEncoding.DecodeString(uuid)
+	if err != nil {
+		return ""
+	}
+	return strings.ToUpper(hex.EncodeToString(decoded))
+}
@@ -0,0 +1,171 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestEntry(uuid string, fields map[string]string) Entry {
+	e := Entry{UUID: uuid}
+	for key, value := range fields {
+		e.Strings = append(e.Strings, String{Key: key, Value: wrappers.Value{Inner: value}})
+	}
+	return e
+}
+
+func resolveTestDocument() *Document {
+	d := NewDocument()
+	d.Root.Groups = []Group{{
+		UUID: "AAAAAAAAAAAAAAAAAAAAAA==",
+		Name: "Root",
+		Entries: []Entry{
+			// Hex UUID: 00112233445566778899AABBCCDDEEFF
+			newTestEntry("ABEiM0RVZneImaq7zN3u/w==", map[string]string{
+				"Title":    "Shared Login",
+				"UserName": "alice",
+				"Password": "hunter2",
+				"URL":      "https://example.com/?user={USERNAME}",
+				"Notes":    "Ask {S:Admin}",
+				"Admin":    "bob",
+			}),
+			newTestEntry("AQEBAQEBAQEBAQEBAQEBAQ==", map[string]string{
+				"Title":    "Uses Shared",
+				"UserName": "{REF:U@T:shared login}",
+				"Password": "{REF:P@I:00112233445566778899aabbccddeeff}",
+				"URL":      "{REF:A@I:00112233445566778899AABBCCDDEEFF}",
+				"Notes":    "{REF:N@O:bob} {REF:I@U:alice}",
+			}),
+		},
+		Groups: []Group{{
+			UUID: "AgICAgICAgICAgICAgICAg==",
+			Name: "Nested",
+			Entries: []Entry{
+				newTestEntry("AwMDAwMDAwMDAwMDAwMDAw==", map[string]string{
+					"Title":    "Cycle A",
+					"Password": "{REF:P@T:Cycle B}",
+				}),
+				newTestEntry("BAQEBAQEBAQEBAQEBAQEBA==", map[string]string{
+					"Title":    "Cycle B",
+					"Password": "{REF:P@T:Cycle A}",
+					"UserName": "{PASSWORD}",
+				}),
+				newTestEntry("BQUFBQUFBQUFBQUFBQUFBQ==", map[string]string{
+					"Title":    "Self",
+					"Password": "x{PASSWORD}",
+					"Notes":    "{REF:P@T:does not exist} {UNKNOWN} {unclosed",
+				}),
+			},
+		}},
+	}}
+	return d
+}
+
+func TestResolveField(t *testing.T) {
+	assert := assert.New(t)
+	d := resolveTestDocument()
+	shared := &d.Root.Groups[0].Entries[0]
+	user := &d.Root.Groups[0].Entries[1]
+
+	cases := []struct {
+		entry    *Entry
+		key      string
+		expected string
+	}{
+		{shared, "URL", "https://example.com/?user=alice"},
+		{shared, "Notes", "Ask bob"},
+		{user, "UserName", "alice"},
+		{user, "Password", "hunter2"},
+		{user, "URL", "https://example.com/?user=alice"},
+		{user, "Notes", "Ask bob 00112233445566778899AABBCCDDEEFF"},
+		{&d.Root.Groups[0].Groups[0].Entries[2], "Notes", "{REF:P@T:does not exist} {UNKNOWN} {unclosed"},
+	}
+	for _, c := range cases {
+		resolved, _, err := d.ResolveField(c.entry, c.key)
+		if assert.Nil(err, c.key) {
+			assert.Equal(c.expected, resolved)
+		}
+	}
+
+	_, _, err := d.ResolveField(user, "DoesNotExist")
+	assert.NotNil(err)
+}
+
+func TestResolveProtected(t *testing.T) {
+	assert := assert.New(t)
+	d := resolveTestDocument()
+	shared := &d.Root.Groups[0].Entries[0]
+	user := &d.Root.Groups[0].Entries[1]
+	for _, e := range []*Entry{shared, user} {
+		for i := range e.Strings {
+			e.Strings[i].Value.Protected = e.Strings[i].Key == "Password"
+		}
+	}
+	shared.Strings = append(shared.Strings, String{Key: "Login", Value: wrappers.Value{Inner: "https://x?p={PASSWORD}"}})
+
+	cases := []struct {
+		entry     *Entry
+		key       string
+		expected  string
+		protected bool
+	}{
+		{shared, "Login", "https://x?p=hunter2", true},
+		{shared, "Password", "hunter2", true},
+		{shared, "URL", "https://example.com/?user=alice", false},
+		{user, "Password", "hunter2", true},
+		{user, "UserName", "alice", false},
+	}
+	for _, c := range cases {
+		resolved, protected, err := d.ResolveField(c.entry, c.key)
+		if assert.Nil(err, c.key) {
+			assert.Equal(c.expected, resolved)
+			assert.Equal(c.protected, protected, c.key)
+		}
+	}
+}
+
+func TestIsReference(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(IsReference("{REF:P@I:00112233445566778899AABBCCDDEEFF}"))
+	assert.True(IsReference("{ref:p@t:Shared Login}"))
+	assert.False(IsReference("{PASSWORD}"))
+	assert.False(IsReference("x{REF:P@T:a}"))
+}
+
+func TestResolveCycles(t *testing.T) {
+	assert := assert.New(t)
+	d := resolveTestDocument()
+	nested := d.Root.Groups[0].Groups[0].Entries
+
+	_, _, err := d.ResolveField(&nested[0], "Password")
+	assert.NotNil(err, "Mutual references must be detected")
+	_, _, err = d.ResolveField(&nested[1], "UserName")
+	assert.NotNil(err, "Indirect mutual references must be detected")
+	_, _, err = d.ResolveField(&nested[2], "Password")
+	assert.NotNil(err, "Self references must be detected")
+}
+
+func TestResolveDepth(t *testing.T) {
+	assert := assert.New(t)
+	d := NewDocument()
+	group := Group{UUID: "AAAAAAAAAAAAAAAAAAAAAA=="}
+	// Build a chain of entries, each of which references the next one
+	titles := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l", "m", "n", "o"}
+	for i, title := range titles {
+		password := "end"
+		if i < len(titles)-1 {
+			password = "{REF:P@T:" + titles[i+1] + "}"
+		}
+		group.Entries = append(group.Entries, newTestEntry(title, map[string]string{"Title": title, "Password": password}))
+	}
+	d.Root.Groups = []Group{group}
+
+	resolved, _, err := d.ResolveField(&d.Root.Groups[0].Entries[len(titles)-MAX_RESOLVE_DEPTH], "Password")
+	if assert.Nil(err) {
+		assert.Equal("end", resolved)
+	}
+	_, _, err = d.ResolveField(&d.Root.Groups[0].Entries[0], "Password")
+	assert.NotNil(err)
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Characters separating tags. KeePass uses ';' while KeePassXC also accepts ','
+const TAG_SEPARATORS = ";,"
+
+// Separator used when writing tags
+const TAG_SEPARATOR = ";"
+
+// SplitTags splits a string of tags at any of TAG_SEPARATORS, omitting empty tags
+func SplitTags(tags string) []string {
+	result := []string{}
+	for _, tag := range strings.FieldsFunc(tags, func(r rune) bool { return strings.ContainsRune(TAG_SEPARATORS, r) }) {
+		if tag = strings.TrimSpace(tag); len(tag) > 0 {
+			result = append(result, tag)
+		}
+	}
+	return result
+}
+
+// TagList returns the entry's tags
+func (e *Entry) TagList() []string {
+	return SplitTags(e.Tags)
+}
+
+// SetTags replaces the entry's tags
+func (e *Entry) SetTags(tags []string) {
+	e.Tags = strings.Join(tags, TAG_SEPARATOR)
+}
+
+// HasTag checks wether the entry has the given tag, ignoring case
+func (e *Entry) HasTag(tag string) bool {
+	for _, t := range e.TagList() {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// AddTag adds a tag to the entry. Returns true if a change was made, false if the entry already has the tag
+func (e *Entry) AddTag(tag string) (bool, error) {
+	tag = strings.TrimSpace(tag)
+	if len(tag) == 0 {
+		return false, fmt.Errorf("Empty tag")
+	}
+	if strings.ContainsAny(tag, TAG_SEPARATORS) {
+		return false, fmt.Errorf("Tag '%s' must not contain any of '%s'", tag, TAG_SEPARATORS)
+	}
+	if e.HasTag(tag) {
+		return false, nil
+	}
+	e.SetTags(append(e.TagList(), tag))
+	return true, nil
+}
+
+// RemoveTag removes a tag from the entry, ignoring case
+// Returns true if a change was made, false otherwise
+func (e *Entry) RemoveTag(tag string) bool {
+	tags := e.TagList()
+	newTags := make([]string, 0, len(tags))
+	for _, t := range tags {
+		if !strings.EqualFold(t, strings.TrimSpace(tag)) {
+			newTags = append(newTags, t)
+		}
+	}
+	if len(newTags) == len(tags) {
+		return false
+	}
+	e.SetTags(newTags)
+	return true
+}
@@ -12,6 +12,11 @@ type Bool struct {
 	value bool
 }
 
+// NewBool returns a Bool which is set to the given value
+func NewBool(value bool) Bool {
+	return Bool{isSet: true, value: value}
+}
+
 func (b *Bool) IsSet() bool {
 	return b.isSet
 }
@@ -0,0 +1,189 @@
+package search
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Scores used for ranking fuzzy matches, loosely modeled after fzf
+const (
+	SCORE_MATCH           = 16
+	BONUS_START           = 10
+	BONUS_BOUNDARY        = 8
+	BONUS_CAMEL_CASE      = 7
+	BONUS_CONSECUTIVE     = 8
+	PENALTY_GAP_START     = 3
+	PENALTY_GAP_EXTENSION = 1
+)
+
+// Characters after which a match is considered to be at the start of a word
+const boundaryChars = " /-_.:@,;()[]"
+
+// Separator between the fields of a candidate's text
+const FIELD_SEPARATOR = "  "
+
+// Candidate is an entry which can be found by the fuzzy finder
+type Candidate struct {
+	Result
+	// The text that is matched against, consisting of the entry's full name, user name and URL
+	Text string
+}
+
+// Ranked is a candidate that matched a query
+type Ranked struct {
+	Candidate
+	Score int
+	// Indices of the matched runes in Text, in ascending order
+	Positions []int
+}
+
+// Candidates returns every entry in the document, in document order
+func Candidates(d *parser.Document) []Candidate {
+	candidates := []Candidate{}
+	d.Walk(func(l parser.Location) error {
+		if entry, ok := l.Item.(parser.Entry); ok {
+			result := Result{entry.CopyMeta(), l.Path, l.Names[:len(l.Names)-1]}
+			candidates = append(candidates, Candidate{result, candidateText(result, entry)})
+		}
+		return nil
+	})
+	return candidates
+}
+
+func candidateText(r Result, e parser.Entry) string {
+	fields := []string{r.FullName()}
+	for _, key := range []string{"UserName", "URL"} {
+		if value := e.TryGet(key, ""); len(value) > 0 {
+			fields = append(fields, value)
+		}
+	}
+	return strings.Join(fields, FIELD_SEPARATOR)
+}
+
+// Rank matches query against all candidates and returns the matching ones, best match first.
+// The query is split at whitespace and each term must match on its own. Terms containing uppercase
+// letters are matched case-sensitively. An empty query matches all candidates in their original order.
+func Rank(candidates []Candidate, query string) []Ranked {
+	terms := strings.Fields(query)
+	ranked := make([]Ranked, 0, len(candidates))
+	for _, candidate := range candidates {
+		if r, ok := rankCandidate(candidate, terms); ok {
+			ranked = append(ranked, r)
+		}
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return len(ranked[i].Text) < len(ranked[j].Text)
+	})
+	return ranked
+}
+
+func rankCandidate(candidate Candidate, terms []string) (Ranked, bool) {
+	text := []rune(candidate.Text)
+	r := Ranked{Candidate: candidate, Positions: []int{}}
+	matched := map[int]bool{}
+	for _, term := range terms {
+		score, positions, ok := FuzzyMatch([]rune(term), text)
+		if !ok {
+			return Ranked{}, false
+		}
+		r.Score += score
+		for _, pos := range positions {
+			if !matched[pos] {
+				matched[pos] = true
+				r.Positions = append(r.Positions, pos)
+			}
+		}
+	}
+	sort.Ints(r.Positions)
+	return r, true
+}
+
+// FuzzyMatch checks wether all runes of pattern occur in text in the same order. If so, it returns
+// a score, which is higher for matches that are close together and at the start of words, as well
+// as the matched positions in text.
+func FuzzyMatch(pattern, text []rune) (int, []int, bool) {
+	if len(pattern) == 0 {
+		return 0, []int{}, true
+	}
+	caseSensitive := false
+	for _, r := range pattern {
+		if unicode.IsUpper(r) {
+			caseSensitive = true
+			break
+		}
+	}
+	equal := func(a, b rune) bool {
+		if caseSensitive {
+			return a == b
+		}
+		return unicode.ToLower(a) == unicode.ToLower(b)
+	}
+
+	// Find the first occurence of the pattern scanning forward...
+	p := 0
+	end := -1
+	for i := 0; i < len(text); i++ {
+		if equal(text[i], pattern[p]) {
+			p++
+			if p == len(pattern) {
+				end = i
+				break
+			}
+		}
+	}
+	if end == -1 {
+		return 0, nil, false
+	}
+	// ...then scan backward from its end in order to find a shorter match
+	p = len(pattern) - 1
+	start := end
+	for i := end; i >= 0; i-- {
+		if equal(text[i], pattern[p]) {
+			p--
+			if p < 0 {
+				start = i
+				break
+			}
+		}
+	}
+
+	positions := make([]int, 0, len(pattern))
+	p = 0
+	for i := start; i <= end && p < len(pattern); i++ {
+		if equal(text[i], pattern[p]) {
+			positions = append(positions, i)
+			p++
+		}
+	}
+	return score(text, positions), positions, true
+}
+
+func score(text []rune, positions []int) int {
+	total := 0
+	for i, pos := range positions {
+		total += SCORE_MATCH
+		switch {
+		case pos == 0:
+			total += BONUS_START
+		case strings.ContainsRune(boundaryChars, text[pos-1]):
+			total += BONUS_BOUNDARY
+		case unicode.IsLower(text[pos-1]) && unicode.IsUpper(text[pos]):
+			total += BONUS_CAMEL_CASE
+		}
+		if i > 0 {
+			gap := pos - positions[i-1] - 1
+			if gap == 0 {
+				total += BONUS_CONSECUTIVE
+			} else {
+				total -= PENALTY_GAP_START + (gap-1)*PENALTY_GAP_EXTENSION
+			}
+		}
+	}
+	return total
+}
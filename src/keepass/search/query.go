@@ -0,0 +1,314 @@
+package search
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+)
+
+// Pseudo-fields which don't correspond to a single string field of an entry
+const (
+	// Matches any of the default fields, see defaultFields
+	FIELD_ANY = ""
+	// Matches any of the entry's tags
+	FIELD_TAGS = "\x00tags"
+	// Matches any custom string field
+	FIELD_CUSTOM = "\x00custom"
+)
+
+// Qualifiers which may be used to restrict a term to a field, e. g. 'user:alice'
+var qualifiers = map[string]string{
+	"title":    "Title",
+	"user":     "UserName",
+	"username": "UserName",
+	"url":      "URL",
+	"notes":    "Notes",
+	"password": "Password",
+	"pass":     "Password",
+	"tag":      FIELD_TAGS,
+	"tags":     FIELD_TAGS,
+	"custom":   FIELD_CUSTOM,
+}
+
+// Unqualified terms match any of these fields
+var defaultFields = []string{"Title", "UserName", "URL", "Notes"}
+
+// Fields which exist for every entry; any other string field is a custom field
+var standardFields = map[string]bool{
+	"Title":    true,
+	"UserName": true,
+	"Password": true,
+	"URL":      true,
+	"Notes":    true,
+}
+
+// Qualifier for setting options, e. g. 'with:history'
+const OPTION_QUALIFIER = "with"
+
+type term struct {
+	field  string
+	negate bool
+	// Lower case text for substring matching; unused if re is set
+	text string
+	re   *regexp.Regexp
+}
+
+// Query is a parsed search query. Consecutive terms must all match, e. g.
+//
+//	user:alice url:github.com -tag:old "two words" notes:/regex/
+//
+// Terms may be negated with '-' or '!'. By default, only non-protected values of the
+// standard fields are searched; 'with:custom' extends unqualified terms to custom fields,
+// 'with:history' also matches entries if one of their previous versions matches and
+// 'with:protected' allows matching protected values.
+type Query struct {
+	terms     []term
+	Custom    bool
+	History   bool
+	Protected bool
+}
+
+// ParseQuery parses a search query, see Query
+func ParseQuery(s string) (Query, error) {
+	q := Query{}
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		if unicode.IsSpace(runes[i]) {
+			i++
+			continue
+		}
+		t, next, err := q.parseTerm(runes, i)
+		if err != nil {
+			return Query{}, err
+		}
+		if t != nil {
+			q.terms = append(q.terms, *t)
+		}
+		i = next
+	}
+	if len(q.terms) == 0 {
+		return Query{}, fmt.Errorf("Empty query")
+	}
+	for _, t := range q.terms {
+		if t.field == "Password" && !q.Protected {
+			return Query{}, fmt.Errorf("Searching passwords requires '%s:protected'", OPTION_QUALIFIER)
+		}
+	}
+	return q, nil
+}
+
+// parseTerm parses the term starting at position i and returns it along with the position after it.
+// If the term sets an option, a nil term is returned.
+func (q *Query) parseTerm(runes []rune, i int) (*term, int, error) {
+	t := term{field: FIELD_ANY}
+	if (runes[i] == '-' || runes[i] == '!') && i+1 < len(runes) && !unicode.IsSpace(runes[i+1]) {
+		t.negate = true
+		i++
+	}
+
+	// A prefix is only treated as qualifier if it is a known one, so that e. g. 'https://...' can be searched
+	qualifier := ""
+	for j := i; j < len(runes) && unicode.IsLetter(runes[j]); j++ {
+		if j+1 < len(runes) && runes[j+1] == ':' {
+			qualifier = strings.ToLower(string(runes[i : j+1]))
+		}
+	}
+	if field, ok := qualifiers[qualifier]; ok {
+		t.field = field
+		i += len([]rune(qualifier)) + 1
+	} else if qualifier == OPTION_QUALIFIER {
+		value, next, err := readWord(runes, i+len(OPTION_QUALIFIER)+1)
+		if err != nil {
+			return nil, 0, err
+		}
+		if t.negate {
+			return nil, 0, fmt.Errorf("Options can't be negated: %s:%s", OPTION_QUALIFIER, value)
+		}
+		return nil, next, q.setOptions(value)
+	}
+
+	if i >= len(runes) || unicode.IsSpace(runes[i]) {
+		return nil, 0, fmt.Errorf("Missing value for '%s:'", qualifier)
+	}
+	switch runes[i] {
+	case '"':
+		end := indexRune(runes, '"', i+1)
+		if end == -1 {
+			return nil, 0, fmt.Errorf("Unclosed '\"' at position %d", i)
+		}
+		t.text = strings.ToLower(string(runes[i+1 : end]))
+		return &t, end + 1, nil
+	case '/':
+		pattern, next, err := readRegex(runes, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.re, err = regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, 0, fmt.Errorf("Invalid regular expression /%s/: %s", pattern, err)
+		}
+		return &t, next, nil
+	default:
+		value, next, err := readWord(runes, i)
+		if err != nil {
+			return nil, 0, err
+		}
+		t.text = strings.ToLower(value)
+		return &t, next, nil
+	}
+}
+
+func (q *Query) setOptions(value string) error {
+	for _, option := range strings.Split(value, ",") {
+		switch strings.ToLower(option) {
+		case "custom":
+			q.Custom = true
+		case "history":
+			q.History = true
+		case "protected":
+			q.Protected = true
+		default:
+			return fmt.Errorf("Unknown option: %s:%s", OPTION_QUALIFIER, option)
+		}
+	}
+	return nil
+}
+
+func readWord(runes []rune, i int) (string, int, error) {
+	start := i
+	for i < len(runes) && !unicode.IsSpace(runes[i]) {
+		i++
+	}
+	if i == start {
+		return "", 0, fmt.Errorf("Missing value at position %d", start)
+	}
+	return string(runes[start:i]), i, nil
+}
+
+// readRegex reads a regular expression enclosed in slashes starting at position i.
+// Slashes inside the expression can be escaped as '\/'.
+func readRegex(runes []rune, i int) (string, int, error) {
+	var b strings.Builder
+	for j := i + 1; j < len(runes); j++ {
+		switch {
+		case runes[j] == '\\' && j+1 < len(runes) && runes[j+1] == '/':
+			b.WriteRune('/')
+			j++
+		case runes[j] == '/':
+			return b.String(), j + 1, nil
+		default:
+			b.WriteRune(runes[j])
+		}
+	}
+	return "", 0, fmt.Errorf("Unclosed '/' at position %d", i)
+}
+
+func indexRune(runes []rune, r rune, from int) int {
+	for i := from; i < len(runes); i++ {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// Match checks wether an item matches the query. Entries must include their history
+// for 'with:history' to have an effect.
+func (q Query) Match(item parser.Item) bool {
+	switch item := item.(type) {
+	case parser.Group:
+		return q.matchGroup(item)
+	case parser.Entry:
+		if q.matchEntry(&item) {
+			return true
+		}
+		if q.History && item.History != nil {
+			for i := range *item.History {
+				if q.matchEntry(&(*item.History)[i]) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+func (q Query) matchGroup(g parser.Group) bool {
+	for _, t := range q.terms {
+		var values []string
+		switch t.field {
+		case FIELD_ANY, "Title":
+			values = []string{g.Name}
+		case "Notes":
+			values = []string{g.Notes}
+		}
+		if t.matchAny(values) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func (q Query) matchEntry(e *parser.Entry) bool {
+	for _, t := range q.terms {
+		if t.matchAny(q.values(e, t.field)) == t.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// values returns the values of an entry which a term with the given field is matched against
+func (q Query) values(e *parser.Entry, field string) []string {
+	values := []string{}
+	add := func(value wrappers.Value) {
+		if !value.Protected || q.Protected {
+			values = append(values, value.Inner)
+		}
+	}
+	switch field {
+	case FIELD_TAGS:
+		return e.TagList()
+	case FIELD_ANY, FIELD_CUSTOM:
+		if field == FIELD_ANY {
+			for _, key := range defaultFields {
+				if value, err := e.Get(key); err == nil {
+					add(value)
+				}
+			}
+		}
+		if field == FIELD_CUSTOM || q.Custom {
+			for _, s := range e.Strings {
+				if !standardFields[s.Key] {
+					add(s.Value)
+				}
+			}
+		}
+	default:
+		if value, err := e.Get(field); err == nil {
+			add(value)
+		}
+	}
+	return values
+}
+
+func (t term) matchAny(values []string) bool {
+	for _, value := range values {
+		if t.re != nil {
+			if t.re.MatchString(value) {
+				return true
+			}
+		} else if t.field == FIELD_TAGS {
+			if strings.ToLower(value) == t.text {
+				return true
+			}
+		} else if strings.Contains(strings.ToLower(value), t.text) {
+			return true
+		}
+	}
+	return false
+}
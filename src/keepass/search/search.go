@@ -0,0 +1,109 @@
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+)
+
+// Separator between group names in a result's full name
+const PATH_SEPARATOR = "/"
+
+// Matcher decides wether an item is included in the search results. Entries are passed
+// including their history.
+type Matcher func(item parser.Item) bool
+
+// Result is an item found by a search, along with its location in the document
+type Result struct {
+	// Copy of the item's metadata, see parser.Item.CopyMeta
+	Item parser.Item
+	// UUIDs of the item's parent groups, followed by the item's own UUID
+	Path []string
+	// Names of the item's parent groups
+	Groups []string
+}
+
+// Name returns the name of a group or the title of an entry
+func (r Result) Name() string {
+	switch item := r.Item.(type) {
+	case parser.Group:
+		return item.Name
+	case parser.Entry:
+		return item.TryGet("Title", "")
+	}
+	return ""
+}
+
+// FullName returns the names of the parent groups and the item itself, joined by PATH_SEPARATOR
+func (r Result) FullName() string {
+	return strings.Join(append(r.Groups[:len(r.Groups):len(r.Groups)], r.Name()), PATH_SEPARATOR)
+}
+
+// Search walks the whole document and returns all items for which match returns true, in document order.
+// Groups for which searching is disabled are skipped along with their contents, unless a subgroup
+// explicitly enables it again. The recycle bin is never searched.
+func Search(d *parser.Document, match Matcher) []Result {
+	results := []Result{}
+	// Wether searching is enabled in each group visited so far
+	enabled := map[string]bool{}
+	d.Walk(func(l parser.Location) error {
+		parentEnabled := true
+		if len(l.Path) > 1 {
+			parentEnabled = enabled[l.Path[len(l.Path)-2]]
+		}
+		switch item := l.Item.(type) {
+		case parser.Group:
+			if len(d.Meta.RecycleBinUUID) > 0 && item.UUID == d.Meta.RecycleBinUUID {
+				return parser.SkipGroup
+			}
+			groupEnabled := parentEnabled
+			if item.EnableSearching.IsSet() {
+				groupEnabled = item.EnableSearching.Value()
+			}
+			enabled[item.UUID] = groupEnabled
+			if groupEnabled && match(item.CopyMeta()) {
+				results = append(results, Result{item.CopyMeta(), l.Path, l.Names[:len(l.Names)-1]})
+			}
+		case parser.Entry:
+			if parentEnabled && match(item) {
+				results = append(results, Result{item.CopyMeta(), l.Path, l.Names[:len(l.Names)-1]})
+			}
+		}
+		return nil
+	})
+	return results
+}
+
+// TagCount is a tag along with the number of entries carrying it
+type TagCount struct {
+	Tag   string
+	Count int
+}
+
+// Tags returns all tags of the searchable entries in the document, sorted by name. Tags which
+// only differ in case are counted as one, using the spelling that occurs first.
+func Tags(d *parser.Document) []TagCount {
+	counts := []TagCount{}
+	indices := map[string]int{}
+	Search(d, func(item parser.Item) bool {
+		entry, ok := item.(parser.Entry)
+		if !ok {
+			return false
+		}
+		for _, tag := range entry.TagList() {
+			key := strings.ToLower(tag)
+			if i, ok := indices[key]; ok {
+				counts[i].Count++
+			} else {
+				indices[key] = len(counts)
+				counts = append(counts, TagCount{tag, 1})
+			}
+		}
+		return false
+	})
+	sort.Slice(counts, func(i, j int) bool {
+		return strings.ToLower(counts[i].Tag) < strings.ToLower(counts[j].Tag)
+	})
+	return counts
+}
@@ -0,0 +1,282 @@
+package search
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	PROTECTED_STREAM_KEY = "be3723cc9496ac62a51976df67314e68203140178c1aba143ce6c2441f1068f4"
+)
+
+func parseDecryptedExample(t *testing.T) *parser.Document {
+	key, err := hex.DecodeString(PROTECTED_STREAM_KEY)
+	if err != nil || len(key) != 32 {
+		t.Fatal("Failed to decode hex string")
+	}
+	xmlFile, err := os.Open("../test/example_decrypted.xml")
+	defer xmlFile.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content, _ := ioutil.ReadAll(xmlFile)
+
+	parsed, err := parser.Parse(content, *(*[32]byte)(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return parsed
+}
+
+func mustParse(t *testing.T, query string) Matcher {
+	q, err := ParseQuery(query)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return q.Match
+}
+
+func fullNames(results []Result) []string {
+	names := []string{}
+	for _, result := range results {
+		names = append(names, result.FullName())
+	}
+	return names
+}
+
+func TestSearch(t *testing.T) {
+	assert := assert.New(t)
+	d := parseDecryptedExample(t)
+
+	results := Search(d, mustParse(t, "account"))
+	assert.Equal([]string{"test/Email Account"}, fullNames(results))
+	if assert.Equal(1, len(results)) {
+		path, found := d.FindPath(results[0].Item.GetUUID())
+		assert.True(found)
+		assert.Equal(path, results[0].Path)
+	}
+
+	// History entries are not searched
+	results = Search(d, mustParse(t, `"sample entry"`))
+	assert.Equal([]string{"test/Sample Entry #2"}, fullNames(results))
+
+	// Other fields than the title are searched as well
+	results = Search(d, mustParse(t, "MICHAEL"))
+	assert.Equal([]string{"test/Sample Entry #2"}, fullNames(results))
+
+	// Groups are matched by their name
+	results = Search(d, mustParse(t, "net"))
+	assert.Equal([]string{"test/Network", "test/Internet"}, fullNames(results))
+
+	// Searching is disabled for 'General'
+	assert.Empty(Search(d, mustParse(t, `"House PIN"`)))
+
+	// The recycle bin is never searched, although it is enabled for its subgroups
+	assert.Empty(Search(d, mustParse(t, `"Recycle Bin"`)))
+	assert.Empty(Search(d, mustParse(t, "Homebanking")))
+}
+
+func TestSearchInheritance(t *testing.T) {
+	assert := assert.New(t)
+	var enabled, disabled wrappers.Bool
+	assert.Nil(xml.Unmarshal([]byte("<EnableSearching>True</EnableSearching>"), &enabled))
+	assert.Nil(xml.Unmarshal([]byte("<EnableSearching>False</EnableSearching>"), &disabled))
+
+	entry := func(title string) parser.Entry {
+		return parser.Entry{UUID: title, Strings: []parser.String{{Key: "Title", Value: wrappers.Value{Inner: title}}}}
+	}
+	d := parser.NewDocument()
+	d.Root.Groups = []parser.Group{{
+		UUID:    "root",
+		Name:    "Root",
+		Entries: []parser.Entry{entry("a")},
+		Groups: []parser.Group{{
+			UUID:            "disabled",
+			Name:            "Disabled",
+			EnableSearching: disabled,
+			Entries:         []parser.Entry{entry("b")},
+			Groups: []parser.Group{
+				{UUID: "inherited", Name: "Inherited", Entries: []parser.Entry{entry("c")}},
+				{UUID: "enabled", Name: "Enabled", EnableSearching: enabled, Entries: []parser.Entry{entry("d")}},
+			},
+		}},
+	}}
+
+	results := Search(d, func(item parser.Item) bool {
+		_, ok := item.(parser.Entry)
+		return ok
+	})
+	assert.Equal([]string{"Root/a", "Root/Disabled/Enabled/d"}, fullNames(results))
+	if assert.Equal(2, len(results)) {
+		assert.Equal([]string{"root", "disabled", "enabled", "d"}, results[1].Path)
+	}
+}
+
+func TestFuzzyMatch(t *testing.T) {
+	assert := assert.New(t)
+	cases := []struct {
+		pattern   string
+		text      string
+		ok        bool
+		positions []int
+	}{
+		{"gh", "GitHub", true, []int{0, 3}},
+		{"hub", "GitHub", true, []int{3, 4, 5}},
+		{"GH", "github", false, nil},
+		{"GH", "GitHub", true, []int{0, 3}},
+		{"xyz", "GitHub", false, nil},
+		// The shortest match ending at the first full occurence is preferred
+		{"ab", "a-a-ab", true, []int{4, 5}},
+		{"", "anything", true, []int{}},
+	}
+	for _, c := range cases {
+		_, positions, ok := FuzzyMatch([]rune(c.pattern), []rune(c.text))
+		if assert.Equal(c.ok, ok, c.pattern+" / "+c.text) && ok {
+			assert.Equal(c.positions, positions, c.pattern+" / "+c.text)
+		}
+	}
+
+	// Consecutive matches and matches at word boundaries score higher
+	consecutive, _, _ := FuzzyMatch([]rune("mail"), []rune("email"))
+	scattered, _, _ := FuzzyMatch([]rune("mail"), []rune("my account login"))
+	assert.Greater(consecutive, scattered)
+	boundary, _, _ := FuzzyMatch([]rune("b"), []rune("foo bar"))
+	inner, _, _ := FuzzyMatch([]rune("b"), []rune("foobar"))
+	assert.Greater(boundary, inner)
+}
+
+func TestRank(t *testing.T) {
+	assert := assert.New(t)
+	d := parseDecryptedExample(t)
+
+	candidates := Candidates(d)
+	texts := []string{}
+	for _, c := range candidates {
+		texts = append(texts, c.Text)
+	}
+	assert.Contains(texts, "test/Email Account  johndoe@example.com  https://keepass.info/")
+	assert.Contains(texts, "test/Recycle Bin/Entry in Recycle Bin")
+
+	ranked := Rank(candidates, "")
+	assert.Equal(len(candidates), len(ranked))
+
+	ranked = Rank(candidates, "samp 321")
+	if assert.Equal(1, len(ranked)) {
+		assert.Equal("Sample Entry #2", ranked[0].Name())
+	}
+
+	ranked = Rank(candidates, "recycle")
+	if assert.Equal(2, len(ranked)) {
+		// Shorter texts win if the score is equal
+		assert.Equal("Entry in Recycle Bin", ranked[0].Name())
+	}
+
+	ranked = Rank(candidates, "pin")
+	if assert.NotEmpty(ranked) {
+		assert.Equal("House PIN", ranked[0].Name())
+	}
+}
+
+func TestQuery(t *testing.T) {
+	assert := assert.New(t)
+	entry := parser.Entry{
+		UUID: "entry",
+		Tags: "work; Finance,shared",
+		Strings: []parser.String{
+			{Key: "Title", Value: wrappers.Value{Inner: "GitHub"}},
+			{Key: "UserName", Value: wrappers.Value{Inner: "alice"}},
+			{Key: "Password", Value: wrappers.Value{Inner: "hunter2", Protected: true}},
+			{Key: "URL", Value: wrappers.Value{Inner: "https://github.com/login"}},
+			{Key: "Notes", Value: wrappers.Value{Inner: "Recovery codes: 1234-5678"}},
+			{Key: "Recovery Email", Value: wrappers.Value{Inner: "alice@example.com"}},
+			{Key: "PIN", Value: wrappers.Value{Inner: "0000", Protected: true}},
+		},
+		History: &[]parser.Entry{{
+			Strings: []parser.String{
+				{Key: "Title", Value: wrappers.Value{Inner: "Old title"}},
+			},
+		}},
+	}
+	group := parser.Group{Name: "Development", Notes: "Code hosting"}
+
+	cases := []struct {
+		query string
+		entry bool
+		group bool
+	}{
+		{"git", true, false},
+		{"GIT hub", true, false},
+		{"dev", false, true},
+		{"user:alice url:github.com", true, false},
+		{"user:bob", false, false},
+		{"-user:bob", true, true},
+		{"!git", false, true},
+		{"title:hub", true, false},
+		{"notes:hosting", false, true},
+		{`notes:/\d{4}-\d{4}/`, true, false},
+		{`url:/^https:\/\/github/`, true, false},
+		{`"codes: 1234"`, true, false},
+		{`"codes 1234"`, false, false},
+		{"tag:work", true, false},
+		{"tag:finance", true, false},
+		{"tag:fin", false, false},
+		{"tag:/^fin/", true, false},
+		{"https://github.com", true, false},
+		// Custom fields are only searched on request...
+		{"example.com", false, false},
+		{"example.com with:custom", true, false},
+		{"custom:example.com", true, false},
+		// ...and protected values never without explicit opt-in
+		{"hunter2", false, false},
+		{"custom:0000", false, false},
+		{"custom:0000 with:protected", true, false},
+		{"password:hunter2 with:protected", true, false},
+		{"0000 with:custom,protected", true, false},
+		// Passwords are only searched when asked for explicitly
+		{"hunter2 with:custom,protected", false, false},
+		// History is only included on request
+		{"old", false, false},
+		{"old with:history", true, false},
+	}
+	for _, c := range cases {
+		q, err := ParseQuery(c.query)
+		if !assert.Nil(err, c.query) {
+			continue
+		}
+		assert.Equal(c.entry, q.Match(entry), c.query)
+		assert.Equal(c.group, q.Match(group), c.query)
+	}
+
+	invalid := []string{"", "  ", "password:hunter2", "user:", `"unclosed`, "notes:/unclosed", "notes:/(/", "with:everything", "-with:history"}
+	for _, query := range invalid {
+		_, err := ParseQuery(query)
+		assert.NotNil(err, query)
+	}
+}
+
+func TestTags(t *testing.T) {
+	assert := assert.New(t)
+	entry := func(uuid, tags string) parser.Entry {
+		return parser.Entry{UUID: uuid, Tags: tags}
+	}
+	d := parser.NewDocument()
+	d.Meta.RecycleBinUUID = "bin"
+	d.Root.Groups = []parser.Group{{
+		UUID:    "root",
+		Entries: []parser.Entry{entry("a", "work;Finance"), entry("b", "finance,shared")},
+		Groups: []parser.Group{
+			{UUID: "sub", Entries: []parser.Entry{entry("c", "Work")}},
+			{UUID: "bin", Entries: []parser.Entry{entry("d", "deleted")}},
+		},
+	}}
+	assert.Equal([]TagCount{{"Finance", 2}, {"shared", 1}, {"work", 2}}, Tags(d))
+}
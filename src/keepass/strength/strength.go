@@ -0,0 +1,197 @@
+package strength
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Passwords with an estimated entropy below this many bits are considered weak
+const WEAK_ENTROPY = 50
+
+// Minimum length of a run of sequential or repeated characters that is penalized
+const MIN_PATTERN_LENGTH = 3
+
+// Rows of a QWERTY keyboard, used for detecting keyboard walks such as 'qwerty' or 'asdf'
+var keyboardRows = []string{
+	"1234567890",
+	"qwertyuiop",
+	"asdfghjkl",
+	"zxcvbnm",
+}
+
+// A few of the most common passwords and password fragments. Passwords which consist mostly
+// of one of these are considered to be as strong as a single guess from a short dictionary.
+var commonWords = []string{
+	"password", "passwort", "letmein", "welcome", "admin", "login", "master", "secret",
+	"dragon", "monkey", "football", "baseball", "iloveyou", "princess", "sunshine",
+	"shadow", "superman", "trustno1", "hello", "freedom", "whatever", "qazwsx",
+	"abc123", "123456", "qwerty", "111111", "000000", "changeme", "default",
+}
+
+// Number of guesses a dictionary attack needs to try a common word, in bits
+const COMMON_WORD_ENTROPY = 10
+
+// Estimate returns an estimate of a password's entropy in bits. The estimate starts out with the
+// size of the character classes used, and reduces it for common words, keyboard walks, sequences
+// such as 'abc' or '123' and repeated characters.
+func Estimate(password string) float64 {
+	runes := []rune(password)
+	if len(runes) == 0 {
+		return 0
+	}
+	perChar := math.Log2(float64(charsetSize(runes)))
+
+	lower := []rune(strings.ToLower(password))
+	// Each rune is either counted with its full entropy, or as part of a pattern
+	inPattern := make([]bool, len(runes))
+	patternBits := 0.0
+
+	for _, run := range patternRuns(lower) {
+		// Runs are checked first, since they may be longer than the common words they contain
+		start, n := run[0], run[1]
+		if markPattern(inPattern, start, n) {
+			// A pattern is about as hard to guess as its first character plus its direction and length
+			patternBits += perChar + 2
+		}
+	}
+	for _, word := range commonWords {
+		for start := indexRunes(lower, []rune(word), 0); start != -1; start = indexRunes(lower, []rune(word), start+1) {
+			if markPattern(inPattern, start, len([]rune(word))) {
+				patternBits += COMMON_WORD_ENTROPY
+			}
+		}
+	}
+
+	bits := patternBits
+	for i := range runes {
+		if !inPattern[i] {
+			bits += perChar
+		}
+	}
+	return bits
+}
+
+// IsWeak checks wether a password's estimated entropy is below WEAK_ENTROPY
+func IsWeak(password string) bool {
+	return Estimate(password) < WEAK_ENTROPY
+}
+
+// charsetSize returns the number of possible characters for the character classes used in runes
+func charsetSize(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r > unicode.MaxASCII:
+			other = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		default:
+			symbol = true
+		}
+	}
+	size := 0
+	if lower {
+		size += 26
+	}
+	if upper {
+		size += 26
+	}
+	if digit {
+		size += 10
+	}
+	if symbol {
+		size += 33
+	}
+	if other {
+		size += 100
+	}
+	return size
+}
+
+// markPattern marks the runes in [start, start+n) as part of a pattern. Returns false if any of
+// them already belong to a pattern, in which case nothing is marked.
+func markPattern(inPattern []bool, start, n int) bool {
+	for i := start; i < start+n; i++ {
+		if inPattern[i] {
+			return false
+		}
+	}
+	for i := start; i < start+n; i++ {
+		inPattern[i] = true
+	}
+	return true
+}
+
+// patternRuns returns the start and length of all maximal runs of at least MIN_PATTERN_LENGTH
+// characters which are repeated, sequential ('abc', '321') or adjacent on the keyboard
+func patternRuns(runes []rune) [][2]int {
+	runs := [][2]int{}
+	start := 0
+	for start < len(runes)-1 {
+		step := pattern(runes[start], runes[start+1])
+		if step == nil {
+			start++
+			continue
+		}
+		end := start + 1
+		for end+1 < len(runes) && step(runes[end], runes[end+1]) {
+			end++
+		}
+		if end-start+1 >= MIN_PATTERN_LENGTH {
+			runs = append(runs, [2]int{start, end - start + 1})
+		}
+		start = end
+	}
+	return runs
+}
+
+// pattern returns a function checking wether two consecutive characters continue the pattern
+// started by a and b, or nil if a and b don't start a pattern
+func pattern(a, b rune) func(rune, rune) bool {
+	for _, delta := range []int{0, 1, -1} {
+		if int(b)-int(a) == delta {
+			d := delta
+			return func(x, y rune) bool { return int(y)-int(x) == d }
+		}
+	}
+	for _, direction := range []int{1, -1} {
+		if keyboardDistance(a, b) == direction {
+			d := direction
+			return func(x, y rune) bool { return keyboardDistance(x, y) == d }
+		}
+	}
+	return nil
+}
+
+// keyboardDistance returns the horizontal distance between two keys in the same keyboard row,
+// or 0 if they are in different rows
+func keyboardDistance(a, b rune) int {
+	for _, row := range keyboardRows {
+		i, j := strings.IndexRune(row, a), strings.IndexRune(row, b)
+		if i != -1 && j != -1 {
+			return j - i
+		}
+	}
+	return 0
+}
+
+func indexRunes(runes, sub []rune, from int) int {
+	for i := from; i+len(sub) <= len(runes); i++ {
+		match := true
+		for j := range sub {
+			if runes[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,41 @@
+package strength
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsWeak(t *testing.T) {
+	assert := assert.New(t)
+	weak := []string{
+		"",
+		"hunter2",
+		"Password123!",
+		"qwertyuiop1234567890",
+		"aaaaaaaaaaaaaaaaaaaaaaaa",
+		"abcdefghijklmnopqrstuvwxyz",
+		"letmeinletmeinletmein",
+	}
+	for _, password := range weak {
+		assert.True(IsWeak(password), password)
+	}
+	strong := []string{
+		"correct horse battery staple",
+		"x7#Kp2!vQ9@mL4zR",
+		"dbuvDegCWcexgJTz0Tcs",
+	}
+	for _, password := range strong {
+		assert.False(IsWeak(password), password)
+	}
+}
+
+func TestEstimate(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(0.0, Estimate(""))
+	// Longer passwords are stronger, patterns add less than random characters
+	assert.Less(Estimate("k9f"), Estimate("k9fq2"))
+	assert.Less(Estimate("k9f123"), Estimate("k9f1x3"))
+	assert.Less(Estimate("k9fqwe"), Estimate("k9fqxe"))
+	assert.Less(Estimate("passwordk9f"), Estimate("pxsswordk9f"))
+}
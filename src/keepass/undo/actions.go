@@ -2,6 +2,7 @@ package undo
 
 import (
 	"fmt"
+
 	"github.com/Zaphoood/tresor/src/keepass/parser"
 )
 
@@ -13,14 +14,18 @@ type UpdateEntryAction struct {
 	description       string
 }
 
-func (a UpdateEntryAction) Do(p *parser.Document) interface{} {
-	p.UpdateEntry(a.newEntry)
-	return a.afterUpdateReturn
+func (a UpdateEntryAction) Do(p *parser.Document) (interface{}, error) {
+	if !p.UpdateEntry(a.newEntry) {
+		return nil, parser.NoSuchItemError{UUID: a.newEntry.UUID}
+	}
+	return a.afterUpdateReturn, nil
 }
 
-func (a UpdateEntryAction) Undo(p *parser.Document) interface{} {
-	p.UpdateEntry(a.oldEntry)
-	return a.afterUpdateReturn
+func (a UpdateEntryAction) Undo(p *parser.Document) (interface{}, error) {
+	if !p.UpdateEntry(a.oldEntry) {
+		return nil, parser.NoSuchItemError{UUID: a.oldEntry.UUID}
+	}
+	return a.afterUpdateReturn, nil
 }
 
 func (a UpdateEntryAction) Description() string {
@@ -33,3 +38,210 @@ func NewUpdateEntryAction(newEntry, oldEntry parser.Entry, returnValue interface
 	}
 	return UpdateEntryAction{newEntry, oldEntry, returnValue, description}
 }
+
+// AddEntryAction adds an entry to a group, see parser.Document.AddEntry
+type AddEntryAction struct {
+	groupUUID         string
+	entry             parser.Entry
+	afterUpdateReturn interface{}
+	description       string
+}
+
+func (a AddEntryAction) Do(p *parser.Document) (interface{}, error) {
+	if err := p.AddEntry(a.groupUUID, a.entry); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a AddEntryAction) Undo(p *parser.Document) (interface{}, error) {
+	if _, err := removeAdded(p, a.entry.UUID); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a AddEntryAction) Description() string {
+	return a.description
+}
+
+func NewAddEntryAction(groupUUID string, entry parser.Entry, returnValue interface{}, description string) AddEntryAction {
+	return AddEntryAction{groupUUID, entry, returnValue, description}
+}
+
+// AddGroupAction adds a group to another group, or at the top level if parentUUID is empty,
+// see parser.Document.AddGroup
+type AddGroupAction struct {
+	parentUUID        string
+	group             parser.Group
+	afterUpdateReturn interface{}
+	description       string
+}
+
+func (a AddGroupAction) Do(p *parser.Document) (interface{}, error) {
+	if err := p.AddGroup(a.parentUUID, a.group); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a AddGroupAction) Undo(p *parser.Document) (interface{}, error) {
+	if _, err := removeAdded(p, a.group.UUID); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a AddGroupAction) Description() string {
+	return a.description
+}
+
+func NewAddGroupAction(parentUUID string, group parser.Group, returnValue interface{}, description string) AddGroupAction {
+	return AddGroupAction{parentUUID, group, returnValue, description}
+}
+
+// removeAdded removes an item which was added by an action and returns it. Unlike deleting it, this
+// doesn't leave a trace in the deleted objects.
+func removeAdded(p *parser.Document, uuid string) (parser.Item, error) {
+	deleted := p.Root.DeletedObjects
+	item, err := p.RemoveItem(uuid)
+	p.Root.DeletedObjects = deleted
+	return item, err
+}
+
+// position records an item as it was before an action changed its location, so that undoing the action
+// can put it back exactly, including its index among its siblings and its times
+type position struct {
+	item  parser.Item
+	index int
+}
+
+// record stores the item with the given UUID and its index
+func (pos *position) record(p *parser.Document, uuid string) error {
+	path, found := p.FindPath(uuid)
+	if !found {
+		return parser.NoSuchItemError{UUID: uuid}
+	}
+	item, err := p.GetItem(path)
+	if err != nil {
+		return err
+	}
+	index, err := p.ItemIndex(uuid)
+	if err != nil {
+		return err
+	}
+	pos.item, pos.index = item, index
+	return nil
+}
+
+// RemoveItemAction permanently deletes a group or entry, see parser.Document.RemoveItem
+type RemoveItemAction struct {
+	parentUUID string
+	item       parser.Item
+	// The item as it was before removing it, which is restored by Undo
+	removed           *position
+	afterUpdateReturn interface{}
+	description       string
+}
+
+func (a RemoveItemAction) Do(p *parser.Document) (interface{}, error) {
+	if err := a.removed.record(p, a.item.GetUUID()); err != nil {
+		return nil, err
+	}
+	if _, err := p.RemoveItem(a.item.GetUUID()); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a RemoveItemAction) Undo(p *parser.Document) (interface{}, error) {
+	// Inserting the item back also removes it from the deleted objects
+	if err := p.InsertItem(a.parentUUID, a.removed.index, a.removed.item); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a RemoveItemAction) Description() string {
+	return a.description
+}
+
+// NewRemoveItemAction returns an action which deletes item, including its contents, from the group
+// with UUID parentUUID
+func NewRemoveItemAction(parentUUID string, item parser.Item, returnValue interface{}, description string) RemoveItemAction {
+	return RemoveItemAction{parentUUID, item, &position{}, returnValue, description}
+}
+
+// MoveItemAction moves a group or entry to another group, see parser.Document.MoveItem
+type MoveItemAction struct {
+	uuid         string
+	oldGroupUUID string
+	newGroupUUID string
+	// The item as it was before moving it, which is restored by Undo
+	moved             *position
+	afterUpdateReturn interface{}
+	description       string
+}
+
+func (a MoveItemAction) Do(p *parser.Document) (interface{}, error) {
+	if err := a.moved.record(p, a.uuid); err != nil {
+		return nil, err
+	}
+	if err := p.MoveItem(a.uuid, a.newGroupUUID); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a MoveItemAction) Undo(p *parser.Document) (interface{}, error) {
+	if _, err := removeAdded(p, a.uuid); err != nil {
+		return nil, err
+	}
+	if err := p.InsertItem(a.oldGroupUUID, a.moved.index, a.moved.item); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a MoveItemAction) Description() string {
+	return a.description
+}
+
+// NewMoveItemAction returns an action which moves item from the group with UUID oldGroupUUID to the one
+// with UUID newGroupUUID. An empty UUID refers to the top level.
+func NewMoveItemAction(oldGroupUUID, newGroupUUID string, item parser.Item, returnValue interface{}, description string) MoveItemAction {
+	return MoveItemAction{item.GetUUID(), oldGroupUUID, newGroupUUID, &position{}, returnValue, description}
+}
+
+// UpdateGroupAction changes the metadata of a group, see parser.Document.UpdateGroup
+type UpdateGroupAction struct {
+	newGroup          parser.Group
+	oldGroup          parser.Group
+	afterUpdateReturn interface{}
+	description       string
+}
+
+func (a UpdateGroupAction) Do(p *parser.Document) (interface{}, error) {
+	if err := p.UpdateGroup(a.newGroup); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a UpdateGroupAction) Undo(p *parser.Document) (interface{}, error) {
+	if err := p.UpdateGroup(a.oldGroup); err != nil {
+		return nil, err
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a UpdateGroupAction) Description() string {
+	return a.description
+}
+
+func NewUpdateGroupAction(newGroup, oldGroup parser.Group, returnValue interface{}, description string) UpdateGroupAction {
+	if newGroup.UUID != oldGroup.UUID {
+		panic(fmt.Sprintf("ERROR: Different UUIDs for old and new group: '%s' != '%s'", newGroup.UUID, oldGroup.UUID))
+	}
+	return UpdateGroupAction{newGroup, oldGroup, returnValue, description}
+}
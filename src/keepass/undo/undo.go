@@ -1,8 +1,10 @@
 package undo
 
 type Action[T any] interface {
-	Do(*T) interface{}
-	Undo(*T) interface{}
+	// Do performs the action. If it fails, target must be left unchanged.
+	Do(*T) (interface{}, error)
+	// Undo reverts the action. If it fails, the action is left to be undone.
+	Undo(*T) (interface{}, error)
 	Description() string
 }
 
@@ -30,26 +32,76 @@ func NewUndoManager[T any]() UndoManager[T] {
 		step:    0,
 	}
 }
-func (u *UndoManager[T]) Do(target *T, action Action[T]) (interface{}, string) {
+
+// Do performs action and records it so that it can be undone. Actions which fail aren't recorded.
+func (u *UndoManager[T]) Do(target *T, action Action[T]) (interface{}, string, error) {
+	result, err := action.Do(target)
+	if err != nil {
+		return nil, "", err
+	}
 	u.actions = append(u.actions[:u.step], action)
 	u.step++
-	return action.Do(target), action.Description()
+	return result, action.Description(), nil
 }
 
 func (u *UndoManager[T]) Undo(target *T) (interface{}, string, error) {
 	if u.step == 0 {
 		return nil, "", AtOldestChange{}
 	}
+	result, err := u.actions[u.step-1].Undo(target)
+	if err != nil {
+		return nil, "", err
+	}
 	u.step--
-	return u.actions[u.step].Undo(target), u.actions[u.step].Description(), nil
+	return result, u.actions[u.step].Description(), nil
 }
 
 func (u *UndoManager[T]) Redo(target *T) (interface{}, string, error) {
 	if u.step >= len(u.actions) {
 		return nil, "", AtNewestChange{}
 	}
-	result := u.actions[u.step].Do(target)
+	result, err := u.actions[u.step].Do(target)
+	if err != nil {
+		return nil, "", err
+	}
 	description := u.actions[u.step].Description()
 	u.step++
 	return result, description, nil
 }
+
+// SequenceAction performs several actions as one, e. g. renaming and moving an entry.
+// They are undone in reverse order. If one of them fails, those performed before it are undone.
+type SequenceAction[T any] struct {
+	actions           []Action[T]
+	afterUpdateReturn interface{}
+	description       string
+}
+
+func (a SequenceAction[T]) Do(target *T) (interface{}, error) {
+	for i, action := range a.actions {
+		if _, err := action.Do(target); err != nil {
+			for j := i - 1; j >= 0; j-- {
+				a.actions[j].Undo(target)
+			}
+			return nil, err
+		}
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a SequenceAction[T]) Undo(target *T) (interface{}, error) {
+	for i := len(a.actions) - 1; i >= 0; i-- {
+		if _, err := a.actions[i].Undo(target); err != nil {
+			return nil, err
+		}
+	}
+	return a.afterUpdateReturn, nil
+}
+
+func (a SequenceAction[T]) Description() string {
+	return a.description
+}
+
+func NewSequenceAction[T any](actions []Action[T], returnValue interface{}, description string) SequenceAction[T] {
+	return SequenceAction[T]{actions, returnValue, description}
+}
@@ -69,9 +69,11 @@ func TestUpdateEntry(t *testing.T) {
 	newEntry.UpdateField("Title", newTitle)
 
 	description := "Description"
-	result, actualDescription := u.Do(document, NewUpdateEntryAction(newEntry, entry, returnSentinel{}, description))
-	assert.Equal(result, returnSentinel{})
-	assert.Equal(description, actualDescription)
+	result, actualDescription, err := u.Do(document, NewUpdateEntryAction(newEntry, entry, returnSentinel{}, description))
+	if assert.Nil(err) {
+		assert.Equal(result, returnSentinel{})
+		assert.Equal(description, actualDescription)
+	}
 
 	entry2 := assertGetEntry(document, path)
 	assert.Equal(newTitle, entry2.TryGet("Title", "(Failed to get field"))
@@ -96,3 +98,196 @@ func TestUpdateEntry(t *testing.T) {
 
 	assert.True(true)
 }
+
+func TestAddRemoveMoveEntry(t *testing.T) {
+	assert := assert.New(t)
+
+	document := parseDecryptedExample(t)
+	u := NewUndoManager[parser.Document]()
+
+	rootUUID := "M0Gbdz4OmEaVH1j8pqgWFA=="
+	otherGroupUUID := "TLnGe1+SlES04aiZ9Sk0Kg=="
+	entry := parser.Entry{UUID: "bmV3IGVudHJ5IHV1aWQhIQ=="}
+	entry.SetField("Title", "New")
+
+	u.Do(document, NewAddEntryAction(rootUUID, entry, nil, "Add"))
+	assertTitle(assert, document, []string{rootUUID, entry.UUID}, "New")
+	added := assertGetEntry(document, []string{rootUUID, entry.UUID})
+	assert.False(added.Times.CreationTime.IsZero())
+
+	moved := added
+	moved.UpdateField("Title", "Moved")
+	u.Do(document, NewSequenceAction([]Action[parser.Document]{
+		NewUpdateEntryAction(moved, added, nil, "Rename"),
+		NewMoveItemAction(rootUUID, otherGroupUUID, moved, nil, "Move"),
+	}, nil, "Rename and move"))
+	assertTitle(assert, document, []string{rootUUID, otherGroupUUID, entry.UUID}, "Moved")
+
+	deletedBefore := len(document.Root.DeletedObjects)
+	u.Do(document, NewRemoveItemAction(otherGroupUUID, moved, nil, "Remove"))
+	_, found := document.FindPath(entry.UUID)
+	assert.False(found)
+	if assert.Equal(deletedBefore+1, len(document.Root.DeletedObjects)) {
+		assert.Equal(entry.UUID, document.Root.DeletedObjects[deletedBefore].UUID)
+	}
+
+	_, _, err := u.Undo(document)
+	assert.Nil(err)
+	assert.Equal(deletedBefore, len(document.Root.DeletedObjects))
+	assertTitle(assert, document, []string{rootUUID, otherGroupUUID, entry.UUID}, "Moved")
+
+	_, _, err = u.Undo(document)
+	assert.Nil(err)
+	// Undoing restores the entry exactly, including its times
+	assert.Equal(added, assertGetEntry(document, []string{rootUUID, entry.UUID}))
+
+	_, _, err = u.Undo(document)
+	assert.Nil(err)
+	_, found = document.FindPath(entry.UUID)
+	assert.False(found)
+	assert.Equal(deletedBefore, len(document.Root.DeletedObjects))
+}
+
+func TestAddUpdateRemoveGroup(t *testing.T) {
+	assert := assert.New(t)
+
+	document := parseDecryptedExample(t)
+	u := NewUndoManager[parser.Document]()
+
+	rootUUID := "M0Gbdz4OmEaVH1j8pqgWFA=="
+	otherGroupUUID := "TLnGe1+SlES04aiZ9Sk0Kg=="
+	group := parser.Group{UUID: "bmV3IGdyb3VwIHV1aWQhIQ==", Name: "New"}
+
+	u.Do(document, NewAddGroupAction(rootUUID, group, nil, "Add"))
+	item, err := document.GetItem([]string{rootUUID, group.UUID})
+	if !assert.Nil(err) {
+		return
+	}
+	added := item.(parser.Group)
+	assert.Equal("New", added.Name)
+
+	renamed := added
+	renamed.Name = "Renamed"
+	u.Do(document, NewUpdateGroupAction(renamed, added, nil, "Rename"))
+	u.Do(document, NewMoveItemAction(rootUUID, otherGroupUUID, renamed, nil, "Move"))
+	item, err = document.GetItem([]string{rootUUID, otherGroupUUID, group.UUID})
+	if assert.Nil(err) {
+		assert.Equal("Renamed", item.(parser.Group).Name)
+	}
+
+	deletedBefore := len(document.Root.DeletedObjects)
+	u.Do(document, NewRemoveItemAction(otherGroupUUID, item, nil, "Remove"))
+	assert.Equal(deletedBefore+1, len(document.Root.DeletedObjects))
+
+	for i := 0; i < 3; i++ {
+		_, _, err = u.Undo(document)
+		assert.Nil(err)
+	}
+	assert.Equal(deletedBefore, len(document.Root.DeletedObjects))
+	item, err = document.GetItem([]string{rootUUID, group.UUID})
+	if assert.Nil(err) {
+		assert.Equal(added, item)
+	}
+
+	_, _, err = u.Undo(document)
+	assert.Nil(err)
+	_, found := document.FindPath(group.UUID)
+	assert.False(found)
+}
+
+func TestUndoKeepsOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	document := parseDecryptedExample(t)
+	u := NewUndoManager[parser.Document]()
+
+	rootUUID := "M0Gbdz4OmEaVH1j8pqgWFA=="
+	otherGroupUUID := "TLnGe1+SlES04aiZ9Sk0Kg=="
+	root := document.Root.Groups[0]
+	firstEntry := root.Entries[0]
+	firstGroup := root.Groups[0]
+	actions := []Action[parser.Document]{
+		NewMoveItemAction(rootUUID, otherGroupUUID, firstEntry, nil, "Move"),
+		NewRemoveItemAction(rootUUID, firstGroup, nil, "Remove"),
+		NewMoveItemAction(rootUUID, "", firstGroup, nil, "Move"),
+	}
+	for _, action := range actions {
+		_, _, err := u.Do(document, action)
+		if assert.Nil(err, action.Description()) {
+			_, _, err = u.Undo(document)
+			assert.Nil(err, action.Description())
+		}
+		// The item is back at its index, with the same times
+		assert.Equal(parseDecryptedExample(t).Root.Groups, document.Root.Groups, action.Description())
+	}
+}
+
+func TestFailedActions(t *testing.T) {
+	assert := assert.New(t)
+
+	document := parseDecryptedExample(t)
+	u := NewUndoManager[parser.Document]()
+
+	rootUUID := "M0Gbdz4OmEaVH1j8pqgWFA=="
+	otherGroupUUID := "TLnGe1+SlES04aiZ9Sk0Kg=="
+	missingUUID := "bm8gc3VjaCBpdGVtIGhlcmU="
+	path := []string{"M0Gbdz4OmEaVH1j8pqgWFA==", "A/ntiXf2VEW3qSstTnhbcA=="}
+	entry := assertGetEntry(document, path)
+	missingEntry := parser.Entry{UUID: missingUUID}
+
+	renamed := entry
+	renamed.UpdateField("Title", "Renamed")
+	actions := []Action[parser.Document]{
+		NewAddEntryAction(missingUUID, parser.Entry{UUID: "bmV3IGVudHJ5IHV1aWQhIQ=="}, nil, "Add"),
+		NewAddGroupAction(missingUUID, parser.Group{UUID: "bmV3IGdyb3VwIHV1aWQhIQ=="}, nil, "Add"),
+		NewUpdateEntryAction(missingEntry, missingEntry, nil, "Update"),
+		NewUpdateGroupAction(parser.Group{UUID: missingUUID}, parser.Group{UUID: missingUUID}, nil, "Update"),
+		NewRemoveItemAction(rootUUID, missingEntry, nil, "Remove"),
+		NewMoveItemAction(rootUUID, missingUUID, entry, nil, "Move"),
+		// The rename is undone once moving fails
+		NewSequenceAction([]Action[parser.Document]{
+			NewUpdateEntryAction(renamed, entry, nil, "Rename"),
+			NewMoveItemAction(rootUUID, missingUUID, renamed, nil, "Move"),
+		}, nil, "Rename and move"),
+	}
+	deletedBefore := len(document.Root.DeletedObjects)
+	for _, action := range actions {
+		_, _, err := u.Do(document, action)
+		assert.NotNil(err, action.Description())
+	}
+	assert.Equal(entry, assertGetEntry(document, path))
+	assert.Equal(deletedBefore, len(document.Root.DeletedObjects))
+	_, _, err := u.Undo(document)
+	assert.ErrorIs(err, AtOldestChange{}, "Failed actions mustn't be recorded")
+
+	// Redoing an action which fails now leaves it to be redone
+	moved := entry
+	_, _, err = u.Do(document, NewMoveItemAction(rootUUID, otherGroupUUID, moved, nil, "Move"))
+	assert.Nil(err)
+	_, _, err = u.Undo(document)
+	assert.Nil(err)
+	_, err = document.RemoveItem(otherGroupUUID)
+	assert.Nil(err)
+	for i := 0; i < 2; i++ {
+		_, _, err = u.Redo(document)
+		assert.NotNil(err)
+		assert.NotErrorIs(err, AtNewestChange{})
+	}
+
+	// The same goes for undoing
+	u = NewUndoManager[parser.Document]()
+	_, _, err = u.Do(document, NewUpdateEntryAction(renamed, entry, nil, "Rename"))
+	assert.Nil(err)
+	_, err = document.RemoveItem(entry.UUID)
+	assert.Nil(err)
+	for i := 0; i < 2; i++ {
+		_, _, err = u.Undo(document)
+		assert.NotNil(err)
+		assert.NotErrorIs(err, AtOldestChange{})
+	}
+}
+
+func assertTitle(assert *assert.Assertions, d *parser.Document, path []string, title string) {
+	entry := assertGetEntry(d, path)
+	assert.Equal(title, entry.TryGet("Title", ""))
+}
@@ -2,7 +2,7 @@ package util
 
 import (
 	"bytes"
-	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 )
@@ -10,7 +10,7 @@ import (
 // Read len(b) bytes from f and compare with b
 func ReadCompare(f io.Reader, b []byte) (bool, error) {
 	buf := make([]byte, len(b))
-	_, err := f.Read(buf)
+	err := ReadAssert(f, buf)
 	if err != nil {
 		return false, err
 	}
@@ -29,48 +29,45 @@ func WriteAssert(w io.Writer, b []byte) error {
 	return nil
 }
 
-// ReadAssert tries to read len(b) bytes and errors if reading failed or if less bytes were read
+// ErrTruncated is wrapped by the error ReadAssert returns if the input ended early
+var ErrTruncated = errors.New("File is truncated")
+
+// ReadAssert reads exactly len(b) bytes and errors if reading failed or if the input ended before
 func ReadAssert(r io.Reader, b []byte) error {
-	n, err := r.Read(b)
-	if err != nil {
-		return err
-	}
-	if n != len(b) {
-		return fmt.Errorf("File truncated: tried to read %d bytes but got only %d", len(b), n)
+	n, err := io.ReadFull(r, b)
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return fmt.Errorf("%w: tried to read %d bytes but got only %d", ErrTruncated, len(b), n)
 	}
-	return nil
+	return err
 }
 
-func GUnzip(in []byte) ([]byte, error) {
-	out := make([]byte, 1024)
-	var outBuf bytes.Buffer
-	inBuf := bytes.NewBuffer(in)
-	r, err := gzip.NewReader(inBuf)
-	if err != nil {
-		return nil, err
-	}
-	for {
-		n, err := r.Read(out)
-		outBuf.Write(out[:n])
-		if err == io.EOF || n == 0 {
-			break
-		}
-		if err != nil {
-			return nil, err
-		}
-	}
+// ErrTooLarge is returned by the reader LimitReader returns if the data exceeds the limit
+var ErrTooLarge = errors.New("Decompressed data is too large")
 
-	return outBuf.Bytes(), nil
+// LimitReader returns a reader which reads from r, but fails with ErrTooLarge as soon as more than limit bytes
+// were read. This protects against compression bombs.
+func LimitReader(r io.Reader, limit int64) io.Reader {
+	return &limitReader{r: r, limit: limit, left: limit}
 }
 
-func GZip(in []byte) ([]byte, error) {
-	var buf bytes.Buffer
-	writer := gzip.NewWriter(&buf)
-	err := WriteAssert(writer, in)
-	if err != nil {
-		return nil, err
-	}
-	writer.Close()
+type limitReader struct {
+	r     io.Reader
+	limit int64
+	left  int64
+}
 
-	return buf.Bytes(), nil
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.left < 0 {
+		return 0, fmt.Errorf("%w: more than %d bytes", ErrTooLarge, l.limit)
+	}
+	// Read one byte more than allowed, so that exceeding the limit is noticed
+	if int64(len(p)) > l.left+1 {
+		p = p[:l.left+1]
+	}
+	n, err := l.r.Read(p)
+	l.left -= int64(n)
+	if l.left < 0 {
+		return n + int(l.left), fmt.Errorf("%w: more than %d bytes", ErrTooLarge, l.limit)
+	}
+	return n, err
 }
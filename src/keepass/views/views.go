@@ -0,0 +1,124 @@
+package views
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/strength"
+)
+
+// Prefix of the UUIDs of views. Since it is not valid base64, it can't collide with the UUID of an actual group.
+const UUID_PREFIX = "\x00view:"
+
+// Entries modified or used within this duration are shown in the respective views
+const RECENT_DURATION = 30 * 24 * time.Hour
+
+// Maximum number of entries in the 'Recently modified' and 'Recently used' views
+const MAX_RECENT = 25
+
+// Entries expiring within this duration are shown in 'Expiring soon'
+const EXPIRING_DURATION = 14 * 24 * time.Hour
+
+const (
+	RECENTLY_MODIFIED = UUID_PREFIX + "recently-modified"
+	RECENTLY_USED     = UUID_PREFIX + "recently-used"
+	EXPIRING_SOON     = UUID_PREFIX + "expiring-soon"
+	EXPIRED           = UUID_PREFIX + "expired"
+	WEAK_PASSWORDS    = UUID_PREFIX + "weak-passwords"
+	REUSED_PASSWORDS  = UUID_PREFIX + "reused-passwords"
+	NO_PASSWORD       = UUID_PREFIX + "no-password"
+)
+
+// IsView checks wether uuid belongs to a view rather than an actual group
+func IsView(uuid string) bool {
+	return strings.HasPrefix(uuid, UUID_PREFIX)
+}
+
+// candidate is an entry which may be shown in views, along with precomputed properties
+type candidate struct {
+	entry    parser.Entry
+	password string
+}
+
+// Compute returns the smart views for a document as groups, each containing copies of the matching entries.
+// Entries in the recycle bin are left out. Recently modified and used entries are sorted with the most recent one
+// first, all other views keep document order.
+func Compute(d *parser.Document, now time.Time) []parser.Group {
+	candidates := collect(d)
+
+	recent := func(uuid, name string, t func(e *parser.Entry) time.Time) parser.Group {
+		entries := filter(candidates, func(c *candidate) bool {
+			at := t(&c.entry)
+			return !at.IsZero() && !at.After(now) && now.Sub(at) <= RECENT_DURATION
+		})
+		sort.SliceStable(entries, func(i, j int) bool {
+			return t(&entries[i]).After(t(&entries[j]))
+		})
+		if len(entries) > MAX_RECENT {
+			entries = entries[:MAX_RECENT]
+		}
+		return parser.Group{UUID: uuid, Name: name, Entries: entries}
+	}
+
+	passwordCounts := map[string]int{}
+	for _, c := range candidates {
+		if len(c.password) > 0 && !parser.IsReference(c.password) {
+			passwordCounts[c.password]++
+		}
+	}
+
+	return []parser.Group{
+		recent(RECENTLY_MODIFIED, "Recently modified", func(e *parser.Entry) time.Time { return e.Times.LastModificationTime }),
+		recent(RECENTLY_USED, "Recently used", func(e *parser.Entry) time.Time { return e.Times.LastAccessTime }),
+		{UUID: EXPIRING_SOON, Name: "Expiring soon", Entries: filter(candidates, func(c *candidate) bool {
+			expiry := c.entry.Times.ExpiryTime
+			return c.entry.Times.Expires.Value() && expiry.After(now) && expiry.Sub(now) <= EXPIRING_DURATION
+		})},
+		{UUID: EXPIRED, Name: "Expired", Entries: filter(candidates, func(c *candidate) bool {
+			return c.entry.Times.Expires.Value() && !c.entry.Times.ExpiryTime.After(now)
+		})},
+		{UUID: WEAK_PASSWORDS, Name: "Weak passwords", Entries: filter(candidates, func(c *candidate) bool {
+			return len(c.password) > 0 && !parser.IsReference(c.password) && strength.IsWeak(c.password)
+		})},
+		{UUID: REUSED_PASSWORDS, Name: "Reused passwords", Entries: filter(candidates, func(c *candidate) bool {
+			return passwordCounts[c.password] > 1
+		})},
+		{UUID: NO_PASSWORD, Name: "No password", Entries: filter(candidates, func(c *candidate) bool {
+			return len(c.password) == 0
+		})},
+	}
+}
+
+// Document returns a copy of d with the views added as top-level groups after the actual ones.
+// Groups and entries are shared with d, so the returned document must not be modified.
+func Document(d *parser.Document, now time.Time) *parser.Document {
+	views := Compute(d, now)
+	copied := *d
+	copied.Root.Groups = make([]parser.Group, 0, len(d.Root.Groups)+len(views))
+	copied.Root.Groups = append(copied.Root.Groups, d.Root.Groups...)
+	copied.Root.Groups = append(copied.Root.Groups, views...)
+	return &copied
+}
+
+func collect(d *parser.Document) []candidate {
+	candidates := []candidate{}
+	d.WalkEntries(func(_ parser.Location, e parser.Entry) {
+		candidates = append(candidates, candidate{
+			entry:    e.CopyMeta().(parser.Entry),
+			password: e.TryGet("Password", ""),
+		})
+	})
+	return candidates
+}
+
+func filter(candidates []candidate, predicate func(c *candidate) bool) []parser.Entry {
+	entries := []parser.Entry{}
+	for i := range candidates {
+		if predicate(&candidates[i]) {
+			entries = append(entries, candidates[i].entry)
+		}
+	}
+	return entries
+}
@@ -0,0 +1,91 @@
+package views
+
+import (
+	"encoding/xml"
+	"testing"
+	"time"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/stretchr/testify/assert"
+)
+
+var now = time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func newEntry(uuid, password string, times parser.Times) parser.Entry {
+	return parser.Entry{
+		UUID:  uuid,
+		Times: times,
+		Strings: []parser.String{
+			{Key: "Title", Value: wrappers.Value{Inner: uuid}},
+			{Key: "Password", Value: wrappers.Value{Inner: password, Protected: true}},
+		},
+	}
+}
+
+func expires(t *testing.T, at time.Time) parser.Times {
+	var b wrappers.Bool
+	if err := xml.Unmarshal([]byte("<Expires>True</Expires>"), &b); err != nil {
+		t.Fatal(err)
+	}
+	return parser.Times{Expires: b, ExpiryTime: at}
+}
+
+func uuids(entries []parser.Entry) []string {
+	result := []string{}
+	for _, e := range entries {
+		result = append(result, e.UUID)
+	}
+	return result
+}
+
+func TestCompute(t *testing.T) {
+	assert := assert.New(t)
+	const strong = "x7#Kp2!vQ9@mL4zR"
+	d := parser.NewDocument()
+	d.Meta.RecycleBinUUID = "bin"
+	d.Root.Groups = []parser.Group{{
+		UUID: "root",
+		Entries: []parser.Entry{
+			newEntry("modified", strong+"1", parser.Times{LastModificationTime: now.Add(-time.Hour)}),
+			newEntry("modified-earlier", strong+"2", parser.Times{LastModificationTime: now.Add(-48 * time.Hour)}),
+			newEntry("modified-long-ago", strong+"3", parser.Times{LastModificationTime: now.Add(-365 * 24 * time.Hour)}),
+			newEntry("used", strong+"4", parser.Times{LastAccessTime: now.Add(-time.Minute)}),
+			newEntry("expiring", strong+"5", expires(t, now.Add(24*time.Hour))),
+			newEntry("expired", strong+"6", expires(t, now.Add(-time.Hour))),
+			newEntry("weak", "hunter2", parser.Times{}),
+			newEntry("empty", "", parser.Times{}),
+			newEntry("reference", "{REF:P@I:0123}", parser.Times{}),
+			newEntry("reference2", "{REF:P@I:0123}", parser.Times{}),
+		},
+		Groups: []parser.Group{
+			{UUID: "sub", Entries: []parser.Entry{newEntry("reused", strong+"1", parser.Times{})}},
+			{UUID: "bin", Entries: []parser.Entry{newEntry("deleted", "", parser.Times{LastModificationTime: now})}},
+		},
+	}}
+
+	views := map[string][]string{}
+	for _, view := range Compute(d, now) {
+		assert.True(IsView(view.UUID))
+		views[view.UUID] = uuids(view.Entries)
+	}
+	assert.Equal([]string{"modified", "modified-earlier"}, views[RECENTLY_MODIFIED])
+	assert.Equal([]string{"used"}, views[RECENTLY_USED])
+	assert.Equal([]string{"expiring"}, views[EXPIRING_SOON])
+	assert.Equal([]string{"expired"}, views[EXPIRED])
+	assert.Equal([]string{"weak"}, views[WEAK_PASSWORDS])
+	assert.Equal([]string{"modified", "reused"}, views[REUSED_PASSWORDS])
+	assert.Equal([]string{"empty"}, views[NO_PASSWORD])
+
+	withViews := Document(d, now)
+	assert.Equal(1, len(d.Root.Groups), "Original document must not be modified")
+	assert.Equal(8, len(withViews.Root.Groups))
+	path, found := withViews.FindPath("reused")
+	assert.True(found)
+	assert.Equal([]string{"root", "sub", "reused"}, path, "Entries must be found in their actual group first")
+	item, err := withViews.GetItem([]string{NO_PASSWORD, "empty"})
+	if assert.Nil(err) {
+		assert.Equal("empty", item.GetUUID())
+	}
+	assert.False(IsView("root"))
+}
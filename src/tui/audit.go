@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/Zaphoood/tresor/src/keepass/audit"
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// auditView is an overlay showing the findings of a security audit
+type auditView struct {
+	model  table.Model
+	styles table.Styles
+	report audit.Report
+	active bool
+}
+
+func newAuditView(styles table.Styles) auditView {
+	return auditView{
+		model:  table.New(table.WithStyles(styles), table.WithFocused(true)),
+		styles: styles,
+	}
+}
+
+// Open runs an audit of the given database and activates the overlay
+func (v *auditView) Open(d *database.Database) {
+	v.report = audit.Run(d.Parsed(), d.TransformRounds(), audit.DefaultOptions())
+	rows := make([]table.Row, 0, len(v.report.Findings))
+	for _, f := range v.report.Findings {
+		entry := f.Entry
+		if len(entry) == 0 {
+			entry = "-"
+		}
+		rows = append(rows, table.Row{f.Severity.String(), string(f.Check), entry, f.Message})
+	}
+	if len(rows) == 0 {
+		rows = append(rows, table.Row{"", "", "No findings", ""})
+	}
+	v.model.SetRows(rows)
+	v.model.SetCursor(0)
+	v.active = true
+}
+
+func (v *auditView) Close() {
+	v.active = false
+}
+
+func (v *auditView) Active() bool {
+	return v.active
+}
+
+// Selected returns the UUID of the entry concerned by the finding under the cursor, and false
+// if the finding concerns the whole database
+func (v *auditView) Selected() (string, bool) {
+	if len(v.report.Findings) == 0 {
+		return "", false
+	}
+	uuid := v.report.Findings[v.model.Cursor()].UUID
+	return uuid, len(uuid) > 0
+}
+
+func (v *auditView) Resize(width, height int) {
+	v.model.SetWidth(width)
+	// The first line is taken up by the title
+	v.model.SetHeight(height - 1)
+	frameWidth, _ := v.styles.Header.GetFrameSize()
+	available := width - 4*2*frameWidth
+	severityWidth := len("medium")
+	checkWidth := len(audit.REUSED_PASSWORD)
+	entryWidth := (available - severityWidth - checkWidth) * 4 / 10
+	v.model.SetColumns([]table.Column{
+		{Title: "Severity", Width: severityWidth},
+		{Title: "Check", Width: checkWidth},
+		{Title: "Entry", Width: entryWidth},
+		{Title: "Details", Width: available - severityWidth - checkWidth - entryWidth},
+	})
+}
+
+func (v auditView) Update(msg tea.Msg) (auditView, tea.Cmd) {
+	if !v.active {
+		return v, nil
+	}
+	var cmd tea.Cmd
+	v.model, cmd = v.model.Update(msg)
+	return v, cmd
+}
+
+func (v auditView) View() string {
+	if !v.active {
+		return ""
+	}
+	title := fmt.Sprintf(
+		"Audit: %d findings in %d entries (%d AES rounds, master key changed %d days ago)",
+		len(v.report.Findings), v.report.Entries, v.report.TransformRounds, v.report.MasterKey.AgeDays,
+	)
+	return resultsHeaderStyle.Render(title) + "\n" + truncateHeader(v.model.View())
+}
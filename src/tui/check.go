@@ -0,0 +1,97 @@
+package tui
+
+import (
+	"fmt"
+
+	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/integrity"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// checkView is an overlay showing the problems found by an integrity check
+type checkView struct {
+	model  table.Model
+	styles table.Styles
+	report integrity.Report
+	active bool
+}
+
+func newCheckView(styles table.Styles) checkView {
+	return checkView{
+		model:  table.New(table.WithStyles(styles), table.WithFocused(true)),
+		styles: styles,
+	}
+}
+
+// Open checks the integrity of the given database and activates the overlay
+func (v *checkView) Open(d *database.Database) {
+	v.report = integrity.Run(d)
+	rows := make([]table.Row, 0, len(v.report.Problems))
+	for _, p := range v.report.Problems {
+		item := p.Item
+		if len(item) == 0 {
+			item = "-"
+		}
+		rows = append(rows, table.Row{string(p.Check), item, p.Message})
+	}
+	if len(rows) == 0 {
+		rows = append(rows, table.Row{"", "No problems", ""})
+	}
+	v.model.SetRows(rows)
+	v.model.SetCursor(0)
+	v.active = true
+}
+
+func (v *checkView) Close() {
+	v.active = false
+}
+
+func (v *checkView) Active() bool {
+	return v.active
+}
+
+// Selected returns the UUID of the group or entry concerned by the problem under the cursor, and false
+// if the problem concerns the whole database
+func (v *checkView) Selected() (string, bool) {
+	if len(v.report.Problems) == 0 {
+		return "", false
+	}
+	p := v.report.Problems[v.model.Cursor()]
+	return p.UUID, len(p.Item) > 0
+}
+
+func (v *checkView) Resize(width, height int) {
+	v.model.SetWidth(width)
+	// The first line is taken up by the title
+	v.model.SetHeight(height - 1)
+	frameWidth, _ := v.styles.Header.GetFrameSize()
+	available := width - 3*2*frameWidth
+	checkWidth := len(integrity.DELETED_OBJECT_EXISTS)
+	itemWidth := (available - checkWidth) * 4 / 10
+	v.model.SetColumns([]table.Column{
+		{Title: "Check", Width: checkWidth},
+		{Title: "Item", Width: itemWidth},
+		{Title: "Details", Width: available - checkWidth - itemWidth},
+	})
+}
+
+func (v checkView) Update(msg tea.Msg) (checkView, tea.Cmd) {
+	if !v.active {
+		return v, nil
+	}
+	var cmd tea.Cmd
+	v.model, cmd = v.model.Update(msg)
+	return v, cmd
+}
+
+func (v checkView) View() string {
+	if !v.active {
+		return ""
+	}
+	title := fmt.Sprintf("Integrity check: %d problems (repair with `tresor check -fix`)", len(v.report.Problems))
+	if len(v.report.Problems) == 0 {
+		title = "Integrity check: no problems"
+	}
+	return resultsHeaderStyle.Render(title) + "\n" + truncateHeader(v.model.View())
+}
@@ -2,14 +2,17 @@ package tui
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/Zaphoood/tresor/src/keepass/otp"
 	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
+	"github.com/Zaphoood/tresor/src/keepass/undo"
+	"github.com/Zaphoood/tresor/src/util"
 	tea "github.com/charmbracelet/bubbletea"
 	"golang.design/x/clipboard"
 )
 
-const CLEAR_CLIPBOARD_DELAY = 10
-
 func initClipboard() {
 	err := clipboard.Init()
 	if err != nil {
@@ -24,8 +27,8 @@ func copyToClipboard(value string, clearClipboardDelay int) tea.Cmd {
 	commandLineMsg := "Copied to clipboard."
 	var clearClipboardCmd tea.Cmd = nil
 	if clearClipboardDelay > 0 {
-		commandLineMsg += fmt.Sprintf(" (Clearing in %d seconds)", CLEAR_CLIPBOARD_DELAY)
-		clearClipboardCmd = scheduleClearClipboard(CLEAR_CLIPBOARD_DELAY, notifyChangeChan)
+		commandLineMsg += fmt.Sprintf(" (Clearing in %d seconds)", util.CLEAR_CLIPBOARD_DELAY)
+		clearClipboardCmd = scheduleClearClipboard(util.CLEAR_CLIPBOARD_DELAY, notifyChangeChan)
 	}
 	setMsgCmd := func() tea.Msg {
 		return setCommandLineMessageMsg{commandLineMsg}
@@ -33,14 +36,51 @@ func copyToClipboard(value string, clearClipboardDelay int) tea.Cmd {
 	return tea.Batch(setMsgCmd, clearClipboardCmd)
 }
 
-func copyEntryFieldToClipboard(entry parser.Entry, field string, clearClipboardDelay int) (tea.Cmd, error) {
-	value, err := entry.Get(field)
+func copyEntryFieldToClipboard(d *parser.Document, entry parser.Entry, field string, raw bool, clearClipboardDelay int) (tea.Cmd, error) {
+	value, err := fieldValue(d, entry, field, raw)
 	if err != nil {
-		return nil, fmt.Errorf("ERROR: Cannot copy to clipboard. Failed to get field '%s' for Entry '%s'\n", field, entry.UUID)
+		return nil, fmt.Errorf("ERROR: Cannot copy to clipboard. Failed to get field '%s' for Entry '%s': %s\n", field, entry.UUID, err)
 	}
 	return copyToClipboard(value.Inner, clearClipboardDelay), nil
 }
 
+// fieldValue returns the value of an entry's field. Unless raw is set, field references and
+// placeholders are resolved, and the value is protected if any of the fields it was resolved from is.
+func fieldValue(d *parser.Document, entry parser.Entry, key string, raw bool) (wrappers.Value, error) {
+	value, err := entry.Get(key)
+	if err != nil || raw {
+		return value, err
+	}
+	value.Inner, value.Protected, err = d.ResolveField(&entry, key)
+	return value, err
+}
+
+// copyOTPToClipboard copies the current one-time password of the given entry. For HOTP, the counter
+// is advanced by an undoable action, so that the entry stays in sync with the server.
+func copyOTPToClipboard(entry parser.Entry) tea.Cmd {
+	key, err := otp.FromEntry(&entry)
+	if err != nil {
+		return func() tea.Msg { return setCommandLineMessageMsg{err.Error()} }
+	}
+	if key.Type != otp.HOTP {
+		return copyToClipboard(key.TOTP(time.Now()), util.CLEAR_CLIPBOARD_DELAY)
+	}
+
+	code, newEntry, err := otp.NextHOTP(entry)
+	if err != nil {
+		return func() tea.Msg { return setCommandLineMessageMsg{err.Error()} }
+	}
+	advanceCounterCmd := func() tea.Msg {
+		return undoableActionMsg{undo.NewUpdateEntryAction(
+			newEntry,
+			entry,
+			focusChangedItemCmd(newEntry.UUID),
+			fmt.Sprintf("Advance HOTP counter to %d", key.Counter+1),
+		)}
+	}
+	return tea.Batch(advanceCounterCmd, copyToClipboard(code, util.CLEAR_CLIPBOARD_DELAY))
+}
+
 func clearClipboard() {
 	clipboard.Write(clipboard.FmtText, []byte(""))
 }
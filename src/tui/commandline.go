@@ -14,6 +14,9 @@ const PROMPT_COMMAND = ":"
 const PROMPT_SEARCH = "/"
 const PROMPT_REV_SEARCH = "?"
 
+// Not named after a key, unlike the other prompts, since database-wide search is started with C-f
+const PROMPT_GLOBAL_SEARCH = "Search all: "
+
 type CmdLineInputCallback func(string) tea.Cmd
 
 type CommandLine struct {
@@ -131,6 +134,10 @@ type searchInputMsg struct {
 	reverse bool
 }
 
+type globalSearchInputMsg struct {
+	query string
+}
+
 func CommandCallback(s string) tea.Cmd {
 	cmdAsStrings, err := parseInputAsCommand(s)
 	if err != nil || len(cmdAsStrings) == 0 {
@@ -149,6 +156,14 @@ func SearchCallback(reverse bool) CmdLineInputCallback {
 	}
 }
 
+func GlobalSearchCallback(s string) tea.Cmd {
+	inputAsSearch, err := parseInputAsSearch(s)
+	if err != nil {
+		return nil
+	}
+	return func() tea.Msg { return globalSearchInputMsg{inputAsSearch} }
+}
+
 func parseInputAsCommand(input string) ([]string, error) {
 	if len(input) == 0 {
 		return nil, errors.New("Empty command")
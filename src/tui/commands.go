@@ -7,8 +7,8 @@ import (
 	"os"
 	"time"
 
-	"github.com/Zaphoood/tresor/src/keepass/crypto"
 	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/hibp"
 	"github.com/Zaphoood/tresor/src/keepass/parser"
 	"github.com/Zaphoood/tresor/src/keepass/undo"
 	tea "github.com/charmbracelet/bubbletea"
@@ -38,27 +38,19 @@ func fileSelectedCmd(path string) tea.Cmd {
 	}
 }
 
-func decryptFileCmd(database *database.Database, password string) tea.Cmd {
+func decryptFileCmd(d *database.Database, password string) tea.Cmd {
 	return func() tea.Msg {
-		database.SetPassword(password)
-		err := database.Decrypt()
-		if err != nil {
-			if _, ok := err.(crypto.DecryptError); ok {
-				return decryptFailedMsg{errors.New("Incorrect password")}
-			}
-		}
-		err = database.Parse()
+		d.SetPassword(password)
+		err := d.Decrypt()
 		if err != nil {
 			return decryptFailedMsg{err}
 		}
-		valid, err := database.VerifyHeaderHash()
+		err = d.CheckHeaderHash()
 		if err != nil {
-			log.Println("Could not verify header hash")
-		}
-		if !valid {
-			return decryptFailedMsg{errors.New("Invalid header hash")}
+			log.Printf("Could not verify header hash: %s", err)
+			return decryptFailedMsg{err}
 		}
-		return decryptDoneMsg{database}
+		return decryptDoneMsg{d}
 	}
 }
 
@@ -120,8 +112,32 @@ func scheduleClearClipboard(delay int, notify <-chan struct{}) tea.Cmd {
 
 type clearClipboardMsg struct{}
 
+// otpTickCmd triggers a refresh of the displayed TOTP code once every second
+func otpTickCmd() tea.Cmd {
+	return tea.Tick(time.Second, func(t time.Time) tea.Msg {
+		return otpTickMsg{t}
+	})
+}
+
+type otpTickMsg struct {
+	time time.Time
+}
+
 type clearClipboardAndQuitMsg struct{}
 
+// breachCheckCmd looks up the passwords of the given candidates in the local breach database
+func breachCheckCmd(candidates []hibp.Candidate, store *hibp.Store) tea.Cmd {
+	return func() tea.Msg {
+		breaches, err := hibp.Check(candidates, store)
+		return breachCheckDoneMsg{breaches, err}
+	}
+}
+
+type breachCheckDoneMsg struct {
+	breaches []hibp.Breach
+	err      error
+}
+
 /* When any model receives a tea.WindowSizeMsg, it should emit this command
 in order to alert the main model of the resize. The main model will store the new
 window size and pass it to other models upon initialization */
@@ -4,10 +4,14 @@ import (
 	"fmt"
 	"log"
 	"strings"
+	"time"
 
 	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/otp"
 	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/parser/wrappers"
 	"github.com/Zaphoood/tresor/src/keepass/undo"
+	"github.com/Zaphoood/tresor/src/util"
 	"github.com/Zaphoood/tresor/src/util/set"
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
@@ -15,6 +19,13 @@ import (
 
 const ENCRYPTED_PLACEH = "•"
 
+// Key of the row showing the current one-time password. This is not a valid field key,
+// so that it can't collide with any of the entry's actual fields
+const OTP_ROW_KEY = "\x00otp"
+
+// Key of the row showing the entry's tags
+const TAGS_ROW_KEY = "\x00tags"
+
 var defaultEntryFields []entryField = []entryField{
 	{"Title", "Title", NO_TITLE_PLACEHOLDER},
 	{"UserName", "Username", ""},
@@ -35,9 +46,14 @@ type entryTable struct {
 	stylesFocused table.Styles
 	stylesBlurred table.Styles
 
-	entry parser.Entry
+	entry    parser.Entry
+	document *parser.Document
 	// The keys of the currently viewed entry's string fields, in order they are displayed
 	fieldKeys []string
+	// If true, field values are not resolved
+	raw bool
+	// If true, the entry can't be changed, e. g. because it is shown in a smart view
+	readOnly bool
 }
 
 func newEntryTable(stylesFocused table.Styles, stylesBlurred table.Styles, options ...table.Option) entryTable {
@@ -65,23 +81,15 @@ func (t *entryTable) Resize(width, height int) {
 
 func (t *entryTable) LoadEntry(entry parser.Entry, d *database.Database) {
 	t.entry = entry
+	t.document = d.Parsed()
 	t.fieldKeys = make([]string, 0, len(entry.Strings))
 	rows := make([]table.Row, 0, len(entry.Strings))
 	visited := set.New[string]()
 
-	var value string
 	for _, field := range defaultEntryFields {
-		r, err := entry.Get(field.key)
-		if err != nil {
-			value = field.defaultValue
-		} else if r.Protected {
-			if r.Inner == "" {
-				value = ""
-			} else {
-				value = strings.Repeat(ENCRYPTED_PLACEH, len(r.Inner))
-			}
-		} else {
-			value = r.Inner
+		value := field.defaultValue
+		if _, err := entry.Get(field.key); err == nil {
+			value = t.viewField(field.key)
 		}
 		rows = append(rows, table.Row{field.displayName, value})
 		t.fieldKeys = append(t.fieldKeys, field.key)
@@ -91,21 +99,88 @@ func (t *entryTable) LoadEntry(entry parser.Entry, d *database.Database) {
 		if visited.Contains(field.Key) {
 			continue
 		}
-		if field.Value.Protected {
-			if field.Value.Inner == "" {
-				value = ""
-			} else {
-				value = strings.Repeat(ENCRYPTED_PLACEH, len(field.Value.Inner))
-			}
-		} else {
-			value = field.Value.Inner
-		}
-		rows = append(rows, table.Row{field.Key, value})
+		rows = append(rows, table.Row{field.Key, t.viewField(field.Key)})
 		t.fieldKeys = append(t.fieldKeys, field.Key)
 	}
+	if tags := entry.TagList(); len(tags) > 0 {
+		rows = append(rows, table.Row{"Tags", strings.Join(tags, ", ")})
+		t.fieldKeys = append(t.fieldKeys, TAGS_ROW_KEY)
+	}
+	if otpLabel, otpValue, ok := viewOTP(&entry, time.Now()); ok {
+		rows = append(rows, table.Row{otpLabel, otpValue})
+		t.fieldKeys = append(t.fieldKeys, OTP_ROW_KEY)
+	}
 	t.model.SetRows(rows)
 }
 
+// fieldValue returns the value of the field with the given key of the current entry.
+// Unless raw values are shown, references and placeholders are resolved.
+func (t *entryTable) fieldValue(key string) (wrappers.Value, error) {
+	return fieldValue(t.document, t.entry, key, t.raw)
+}
+
+// viewField returns the value of a field as it should be displayed, i. e. masked if it is protected or
+// contains a protected value through a reference
+func (t *entryTable) viewField(key string) string {
+	value, err := t.fieldValue(key)
+	if err != nil {
+		log.Printf("ERROR: Failed to resolve field '%s' of entry '%s': %s", key, t.entry.UUID, err)
+		value, _ = t.entry.Get(key)
+	}
+	if value.Protected && len(value.Inner) > 0 {
+		return strings.Repeat(ENCRYPTED_PLACEH, len(value.Inner))
+	}
+	return value.Inner
+}
+
+// SetRaw sets wether field values are displayed and copied as they are stored,
+// instead of with references and placeholders resolved
+func (t *entryTable) SetRaw(raw bool) {
+	t.raw = raw
+}
+
+// SetReadOnly sets wether changes to the entry are prohibited
+func (t *entryTable) SetReadOnly(readOnly bool) {
+	t.readOnly = readOnly
+}
+
+// viewOTP returns a label and a value for the row which shows an entry's one-time password.
+// For TOTP, the value is the current code along with the remaining time until it expires. For HOTP,
+// the code isn't shown since that would require advancing the counter. The third return value is
+// false if the entry has no OTP configuration.
+func viewOTP(entry *parser.Entry, now time.Time) (string, string, bool) {
+	key, err := otp.FromEntry(entry)
+	if err != nil {
+		if _, ok := err.(otp.NotConfigured); ok {
+			return "", "", false
+		}
+		return "OTP", fmt.Sprintf("(%s)", err), true
+	}
+	switch key.Type {
+	case otp.HOTP:
+		return "HOTP", fmt.Sprintf("Press 't' to copy (counter %d)", key.Counter), true
+	default:
+		return "TOTP", fmt.Sprintf("%s (%ds)", otp.Format(key.TOTP(now)), int(key.Remaining(now).Seconds())), true
+	}
+}
+
+// refreshOTP updates the row showing the current TOTP code, if there is one
+func (t *entryTable) refreshOTP(now time.Time) {
+	for i, key := range t.fieldKeys {
+		if key != OTP_ROW_KEY {
+			continue
+		}
+		rows := t.model.Rows()
+		if i >= len(rows) {
+			return
+		}
+		otpLabel, otpValue, _ := viewOTP(&t.entry, now)
+		rows[i] = table.Row{otpLabel, otpValue}
+		t.model.SetRows(rows)
+		return
+	}
+}
+
 func (t entryTable) Update(msg tea.Msg) (entryTable, tea.Cmd) {
 	if !t.Focused() {
 		return t, nil
@@ -120,6 +195,8 @@ func (t entryTable) Update(msg tea.Msg) (entryTable, tea.Cmd) {
 		case "y":
 			cmd = t.copyFocusedToClipboard()
 			return t, cmd
+		case "t":
+			return t, copyOTPToClipboard(t.entry)
 		case "d":
 			cmd = t.deleteFocused()
 			return t, cmd
@@ -154,21 +231,38 @@ func (t *entryTable) copyFocusedToClipboard() tea.Cmd {
 	// order of strings may be different from the order in t.entry.Strings
 	// TODO: This is a bit hacky, maybe find a less confusing solution
 	key := t.fieldKeys[t.model.Cursor()]
-	value, err := t.entry.Get(key)
+	if key == OTP_ROW_KEY {
+		return copyOTPToClipboard(t.entry)
+	}
+	if key == TAGS_ROW_KEY {
+		return copyToClipboard(strings.Join(t.entry.TagList(), ", "), 0)
+	}
+	value, err := t.fieldValue(key)
 	if err != nil {
-		log.Printf("ERROR: Could not retrieve value for key '%s' of entry '%s'", key, t.entry.GetUUID())
-		return nil
+		log.Printf("ERROR: Could not retrieve value for key '%s' of entry '%s': %s", key, t.entry.GetUUID(), err)
+		return func() tea.Msg { return setCommandLineMessageMsg{err.Error()} }
 	}
 
 	clipboardDelay := 0
 	if value.Protected {
-		clipboardDelay = CLEAR_CLIPBOARD_DELAY
+		clipboardDelay = util.CLEAR_CLIPBOARD_DELAY
 	}
 	return copyToClipboard(value.Inner, clipboardDelay)
 }
 
 func (t *entryTable) deleteFocused() tea.Cmd {
+	if t.readOnly {
+		return func() tea.Msg { return setCommandLineMessageMsg{ERR_READ_ONLY} }
+	}
 	focusedKey := t.fieldKeys[t.model.Cursor()]
+	if focusedKey == OTP_ROW_KEY {
+		return func() tea.Msg {
+			return setCommandLineMessageMsg{"Cannot delete one-time password, delete the OTP fields instead"}
+		}
+	}
+	if focusedKey == TAGS_ROW_KEY {
+		return makeSetTagsAction(t.entry, "", "Remove all tags")
+	}
 	newEntry := t.entry
 	if isDefaultEntryField(focusedKey) {
 		changed := newEntry.UpdateField(focusedKey, "")
@@ -197,7 +291,18 @@ func (t *entryTable) deleteFocused() tea.Cmd {
 }
 
 func (t *entryTable) changeFocused(newValue string) tea.Cmd {
+	if t.readOnly {
+		return func() tea.Msg { return setCommandLineMessageMsg{ERR_READ_ONLY} }
+	}
 	focusedKey := t.fieldKeys[t.model.Cursor()]
+	if focusedKey == OTP_ROW_KEY {
+		return func() tea.Msg {
+			return setCommandLineMessageMsg{"Cannot change one-time password, change the OTP fields instead"}
+		}
+	}
+	if focusedKey == TAGS_ROW_KEY {
+		return makeSetTagsAction(t.entry, newValue, fmt.Sprintf("Change tags to '%s'", newValue))
+	}
 	return makeChangeFieldAction(t.entry, focusedKey, newValue, focusChangedItemCmd(t.entry.UUID))
 }
 
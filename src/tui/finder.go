@@ -0,0 +1,177 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/search"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const PROMPT_FINDER = "> "
+
+var finderBoxStyle = lipgloss.NewStyle().
+	Padding(0, 1).
+	BorderStyle(lipgloss.NormalBorder())
+var finderHighlightStyle = lipgloss.NewStyle().
+	Bold(true).
+	Foreground(lipgloss.Color("#f4c69d"))
+var finderCountStyle = lipgloss.NewStyle().Faint(true)
+
+// finder is an overlay for fuzzy-finding any entry in the database
+type finder struct {
+	input      textinput.Model
+	candidates []search.Candidate
+	ranked     []search.Ranked
+	cursor     int
+	active     bool
+
+	width  int
+	height int
+}
+
+func newFinder() finder {
+	input := textinput.New()
+	input.Prompt = PROMPT_FINDER
+	return finder{input: input}
+}
+
+// Open activates the finder with all entries of the given document as candidates
+func (f *finder) Open(d *parser.Document, query string) tea.Cmd {
+	f.candidates = search.Candidates(d)
+	f.active = true
+	f.input.SetValue(query)
+	f.input.SetCursor(len(query))
+	f.rank()
+	return f.input.Focus()
+}
+
+func (f *finder) Close() {
+	f.active = false
+	f.input.Blur()
+	f.candidates = nil
+	f.ranked = nil
+}
+
+func (f *finder) Active() bool {
+	return f.active
+}
+
+// Selected returns the entry under the cursor, and false if nothing matches the query
+func (f *finder) Selected() (parser.Entry, bool) {
+	if len(f.ranked) == 0 {
+		return parser.Entry{}, false
+	}
+	entry, ok := f.ranked[f.cursor].Item.(parser.Entry)
+	return entry, ok
+}
+
+func (f *finder) rank() {
+	f.ranked = search.Rank(f.candidates, f.input.Value())
+	f.cursor = 0
+}
+
+func (f *finder) Resize(width, height int) {
+	f.width = width
+	f.height = height
+	f.input.Width = f.innerWidth() - len(PROMPT_FINDER) - 1
+}
+
+// innerWidth returns the width available for the content of the overlay
+func (f *finder) innerWidth() int {
+	return f.width - finderBoxStyle.GetHorizontalFrameSize()
+}
+
+// visibleRows returns the number of results that fit into the overlay
+func (f *finder) visibleRows() int {
+	// The input and the match count take up one line each
+	rows := f.height - finderBoxStyle.GetVerticalFrameSize() - 2
+	if rows < 0 {
+		return 0
+	}
+	return rows
+}
+
+func (f *finder) moveCursor(offset int) {
+	if len(f.ranked) == 0 {
+		return
+	}
+	f.cursor = mod(f.cursor+offset, len(f.ranked))
+}
+
+func (f finder) Update(msg tea.Msg) (finder, tea.Cmd) {
+	if !f.active {
+		return f, nil
+	}
+	if msg, ok := msg.(tea.KeyMsg); ok {
+		switch msg.String() {
+		case "up", "ctrl+k", "ctrl+p":
+			f.moveCursor(-1)
+			return f, nil
+		case "down", "ctrl+j", "ctrl+n":
+			f.moveCursor(1)
+			return f, nil
+		}
+	}
+	oldValue := f.input.Value()
+	var cmd tea.Cmd
+	f.input, cmd = f.input.Update(msg)
+	if f.input.Value() != oldValue {
+		f.rank()
+	}
+	return f, cmd
+}
+
+func (f finder) View() string {
+	if !f.active {
+		return ""
+	}
+	lines := []string{
+		f.input.View(),
+		finderCountStyle.Render(fmt.Sprintf("%d/%d", len(f.ranked), len(f.candidates))),
+	}
+
+	rows := f.visibleRows()
+	first := 0
+	if f.cursor >= rows {
+		first = f.cursor - rows + 1
+	}
+	for i := first; i < len(f.ranked) && i < first+rows; i++ {
+		line := highlight(f.ranked[i], f.innerWidth()-2)
+		if i == f.cursor {
+			line = "> " + line
+		} else {
+			line = "  " + line
+		}
+		lines = append(lines, line)
+	}
+	for len(lines) < rows+2 {
+		lines = append(lines, "")
+	}
+	// Width includes the padding, but not the border
+	return finderBoxStyle.
+		Width(f.width - finderBoxStyle.GetHorizontalBorderSize()).
+		Render(strings.Join(lines, "\n"))
+}
+
+// highlight renders the text of a ranked candidate with its matched runes highlighted,
+// truncated to width runes
+func highlight(r search.Ranked, width int) string {
+	text := []rune(truncate(r.Text, width))
+	var b strings.Builder
+	p := 0
+	for i, char := range text {
+		for p < len(r.Positions) && r.Positions[p] < i {
+			p++
+		}
+		if p < len(r.Positions) && r.Positions[p] == i {
+			b.WriteString(finderHighlightStyle.Render(string(char)))
+		} else {
+			b.WriteRune(char)
+		}
+	}
+	return b.String()
+}
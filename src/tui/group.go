@@ -22,6 +22,13 @@ var numberStyle lipgloss.Style = lipgloss.NewStyle().
 	Width(NUM_COL_WIDTH).
 	AlignHorizontal(lipgloss.Right)
 
+// Marker for entries whose password was found in a breach
+const BREACHED_MARKER = "!"
+
+var breachedStyle lipgloss.Style = numberStyle.Copy().
+	Bold(true).
+	Foreground(lipgloss.Color("#f47c7c"))
+
 type entryField struct {
 	key          string
 	displayName  string
@@ -38,6 +45,8 @@ type groupTable struct {
 	// items is a list of copies of the database items currently being displayed;
 	// only metadata is copied, not sub-items
 	items []parser.Item
+	// Maps the UUIDs of entries whose password was found in a breach to the breach count
+	breached map[string]int
 }
 
 func newGroupTable(styles table.Styles, sorted bool, notifyCursorChange bool, options ...table.Option) groupTable {
@@ -67,6 +76,11 @@ func (t *groupTable) Resize(width, height int) {
 	})
 }
 
+// SetBreached sets the entries which are marked as breached, mapping their UUIDs to breach counts
+func (t *groupTable) SetBreached(breached map[string]int) {
+	t.breached = breached
+}
+
 func (t *groupTable) SetSorted(v bool) {
 	t.sorted = v
 }
@@ -203,7 +217,11 @@ func (t *groupTable) LoadItems(groups []parser.Group, entries []parser.Entry) {
 		if len(title) == 0 {
 			title = NO_TITLE_PLACEHOLDER
 		}
-		rows = append(rows, table.Row{title, ""})
+		marker := ""
+		if t.breached[entry.UUID] > 0 {
+			marker = breachedStyle.Render(BREACHED_MARKER)
+		}
+		rows = append(rows, table.Row{title, marker})
 		t.items = append(t.items, entry.CopyMeta())
 	}
 	t.model.SetRows(rows)
@@ -3,11 +3,17 @@ package tui
 import (
 	"fmt"
 	"log"
+	"os"
 	"strings"
+	"time"
 
 	"github.com/Zaphoood/tresor/src/keepass/database"
+	"github.com/Zaphoood/tresor/src/keepass/hibp"
 	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/search"
 	"github.com/Zaphoood/tresor/src/keepass/undo"
+	"github.com/Zaphoood/tresor/src/keepass/views"
+	"github.com/Zaphoood/tresor/src/util"
 
 	"github.com/charmbracelet/bubbles/table"
 	tea "github.com/charmbracelet/bubbletea"
@@ -19,6 +25,7 @@ import (
 const TABLE_SPACING = 1
 const ERR_TOO_FEW_ARGS = "Error: Too few arguments"
 const ERR_TOO_MANY_ARGS = "Error: Too many arguments"
+const ERR_READ_ONLY = "Smart views are read-only"
 
 var tablePadding lipgloss.Style = lipgloss.NewStyle().PaddingRight(TABLE_SPACING)
 
@@ -38,6 +45,26 @@ type Navigate struct {
 	search        []string
 	searchIndex   int
 	searchForward bool
+	// Results of a search over the whole database. Unlike the results above, these are kept when
+	// navigating to another group.
+	results resultsPane
+	// Overlay for fuzzy-finding entries
+	finder finder
+	// Overlay listing all tags
+	tagList tagList
+	// Overlay showing the findings of a security audit
+	auditView auditView
+	// Overlay showing the problems found by an integrity check
+	checkView checkView
+	// Maps the UUIDs of entries whose password was found in a breach to the breach count
+	breached map[string]int
+
+	// If true, field values are shown and copied without resolving references and placeholders
+	showRaw bool
+
+	// The database's document along with smart views as additional top-level groups. This is what
+	// is displayed, while changes are always made to the actual document.
+	document *parser.Document
 
 	path []string
 	err  error
@@ -79,8 +106,13 @@ func NewNavigate(database *database.Database, windowWidth, windowHeight int) Nav
 		tableStyles,
 		tableStylesBlurred,
 	)
+	n.finder = newFinder()
+	n.tagList = newTagList(tableStyles)
+	n.auditView = newAuditView(tableStyles)
+	n.checkView = newCheckView(tableStyles)
 
 	n.resizeAll()
+	n.refreshViews()
 	n.reopenLastGroup()
 	n.loadAllTables()
 
@@ -91,7 +123,7 @@ func NewNavigate(database *database.Database, windowWidth, windowHeight int) Nav
 
 func (n *Navigate) resizeAll() {
 	totalWidth := n.windowWidth - 2*TABLE_SPACING
-	totalHeight := n.windowHeight - n.cmdLine.GetHeight()
+	totalHeight := n.windowHeight - n.cmdLine.GetHeight() - n.results.GetHeight()
 	centerTableWidth := int(float64(totalWidth) * 0.3)
 	rightTableWidth := int(float64(totalWidth) * 0.5)
 	leftTableWidth := totalWidth - centerTableWidth - rightTableWidth
@@ -101,20 +133,38 @@ func (n *Navigate) resizeAll() {
 	n.centerTable.Resize(centerTableWidth, height)
 	n.rightGroupTable.Resize(rightTableWidth, height)
 	n.rightEntryTable.Resize(rightTableWidth, height)
+	n.results.Resize(n.windowWidth)
+	n.finder.Resize(n.windowWidth, n.windowHeight-n.cmdLine.GetHeight())
+	// One line is taken up by the overlay's title
+	n.tagList.Resize(centerTableWidth, totalHeight-1)
+	n.auditView.Resize(n.windowWidth, n.windowHeight-n.cmdLine.GetHeight())
+	n.checkView.Resize(n.windowWidth, n.windowHeight-n.cmdLine.GetHeight())
+}
+
+// refreshViews recomputes the smart views. Must be called whenever the document was changed.
+func (n *Navigate) refreshViews() {
+	n.document = views.Document(n.database.Parsed(), time.Now())
+}
+
+// inView checks wether a smart view is currently being browsed
+func (n *Navigate) inView() bool {
+	return len(n.path) > 0 && views.IsView(n.path[0])
 }
 
 func (n *Navigate) loadAllTables() {
 	if len(n.path) == 0 {
 		n.leftTable.Clear()
 	} else {
-		n.leftTable.Load(n.database.Parsed(), n.path[:len(n.path)-1])
+		n.leftTable.Load(n.document, n.path[:len(n.path)-1])
 		err := n.leftTable.LoadLastCursor(&n.lastCursors)
 		if err != nil {
 			log.Println(err)
 		}
 	}
 
-	n.centerTable.Load(n.database.Parsed(), n.path)
+	// Views are already sorted in a meaningful order
+	n.centerTable.SetSorted(!n.inView())
+	n.centerTable.Load(n.document, n.path)
 	err := n.centerTable.LoadLastCursor(&n.lastCursors)
 	if err != nil {
 		log.Println(err)
@@ -133,12 +183,14 @@ func (n *Navigate) loadPreviewTable() {
 	}
 	switch focusedItem := (*focusedItem).(type) {
 	case parser.Group:
+		n.rightGroupTable.SetSorted(!views.IsView(focusedItem.UUID))
 		n.rightGroupTable.LoadGroup(focusedItem)
 		err := n.rightGroupTable.LoadLastCursor(&n.lastCursors)
 		if err != nil {
 			log.Println(err)
 		}
 	case parser.Entry:
+		n.rightEntryTable.SetReadOnly(n.inView())
 		n.rightEntryTable.LoadEntry(focusedItem, n.database)
 	default:
 		log.Printf("ERROR in updatePreview: Expected Group or Entry as focused item")
@@ -167,6 +219,9 @@ func (n *Navigate) saveLastSelected() {
 	if len(currentGroupUUID) == 0 {
 		currentGroupUUID = n.centerTable.FocusedUUID()
 	}
+	if views.IsView(currentGroupUUID) {
+		return
+	}
 	n.database.Parsed().Meta.LastSelectedGroup = currentGroupUUID
 }
 
@@ -176,7 +231,7 @@ func (n *Navigate) getFocusedItem() *parser.Item {
 	if len(focused) == 0 {
 		return nil
 	}
-	item, err := n.database.Parsed().GetItem(append(n.path, focused))
+	item, err := n.document.GetItem(append(n.path, focused))
 	if err != nil {
 		log.Printf("ERROR: Failed to get focused item: %s\n", err)
 		return nil
@@ -185,7 +240,7 @@ func (n *Navigate) getFocusedItem() *parser.Item {
 }
 
 func (n *Navigate) focusItem(uuid string) {
-	path, found := n.database.Parsed().FindPath(uuid)
+	path, found := n.document.FindPath(uuid)
 	if !found {
 		log.Printf("ERROR: Cannot focus on item '%s' (not found)", uuid)
 	}
@@ -210,7 +265,7 @@ func (n *Navigate) moveLeft() {
 
 func (n *Navigate) moveRight() {
 	newPath := append(n.path, n.centerTable.FocusedUUID())
-	focusedItem, err := n.database.Parsed().GetItem(newPath)
+	focusedItem, err := n.document.GetItem(newPath)
 	if err != nil {
 		return
 	}
@@ -244,7 +299,7 @@ func (n *Navigate) copyToClipboard() tea.Cmd {
 		return nil
 	}
 
-	cmd, err := copyEntryFieldToClipboard(focusedEntry, "Password", CLEAR_CLIPBOARD_DELAY)
+	cmd, err := copyEntryFieldToClipboard(n.database.Parsed(), focusedEntry, "Password", n.showRaw, util.CLEAR_CLIPBOARD_DELAY)
 	if err != nil {
 		log.Println(err)
 		return nil
@@ -252,6 +307,29 @@ func (n *Navigate) copyToClipboard() tea.Cmd {
 	return cmd
 }
 
+func (n *Navigate) toggleRaw() {
+	n.showRaw = !n.showRaw
+	n.rightEntryTable.SetRaw(n.showRaw)
+	n.loadPreviewTable()
+	if n.showRaw {
+		n.cmdLine.SetMessage("Showing raw values")
+	} else {
+		n.cmdLine.SetMessage("Showing resolved values")
+	}
+}
+
+func (n *Navigate) copyOTPToClipboard() tea.Cmd {
+	focusedItem := n.getFocusedItem()
+	if focusedItem == nil {
+		return nil
+	}
+	focusedEntry, ok := (*focusedItem).(parser.Entry)
+	if !ok {
+		return nil
+	}
+	return copyOTPToClipboard(focusedEntry)
+}
+
 func (n *Navigate) handleCommand(cmd []string) tea.Cmd {
 	if len(cmd) == 0 {
 		return nil
@@ -267,6 +345,20 @@ func (n *Navigate) handleCommand(cmd []string) tea.Cmd {
 		return n.handleEditCmd(cmd)
 	case "change":
 		return n.handleChangeCmd(cmd)
+	case "find":
+		return n.openFinder(strings.Join(cmd[1:], " "))
+	case "tag":
+		return n.handleTagCmd(cmd)
+	case "tags":
+		return n.handleTagsCmd(cmd)
+	case "audit":
+		n.auditView.Open(n.database)
+		return nil
+	case "check":
+		n.checkView.Open(n.database)
+		return nil
+	case "breachcheck":
+		return n.handleBreachCheckCmd(cmd)
 	default:
 		n.cmdLine.SetMessage(fmt.Sprintf("Not a command: %s", cmd[0]))
 		return nil
@@ -324,6 +416,10 @@ func (n *Navigate) handleChangeCmd(cmd []string) tea.Cmd {
 	// instead of in command line), or alternatively allow wrapping command
 	// arguments in parantheses
 	newValue := strings.Join(cmd[1:], " ")
+	if n.inView() {
+		n.cmdLine.SetMessage(ERR_READ_ONLY)
+		return nil
+	}
 
 	if n.rightEntryTable.Focused() {
 		return n.rightEntryTable.changeFocused(newValue)
@@ -342,15 +438,210 @@ func (n *Navigate) handleChangeCmd(cmd []string) tea.Cmd {
 	return makeChangeFieldAction(focusedEntry, "Title", newValue, focusChangedItemCmd(focusedEntry.UUID))
 }
 
+func (n *Navigate) openFinder(query string) tea.Cmd {
+	return n.finder.Open(n.database.Parsed(), query)
+}
+
+// handleKeyFinder handles all key events while the fuzzy finder is open
+func (n *Navigate) handleKeyFinder(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "ctrl+c":
+		n.finder.Close()
+		return nil
+	case "enter":
+		entry, ok := n.finder.Selected()
+		n.finder.Close()
+		if !ok {
+			return nil
+		}
+		n.centerTable.Focus()
+		n.rightEntryTable.Blur()
+		n.focusItem(entry.UUID)
+		return nil
+	case "ctrl+y":
+		entry, ok := n.finder.Selected()
+		if !ok {
+			return nil
+		}
+		n.finder.Close()
+		cmd, err := copyEntryFieldToClipboard(n.database.Parsed(), entry, "Password", n.showRaw, util.CLEAR_CLIPBOARD_DELAY)
+		if err != nil {
+			log.Println(err)
+			return nil
+		}
+		return cmd
+	}
+	var cmd tea.Cmd
+	n.finder, cmd = n.finder.Update(msg)
+	return cmd
+}
+
+// focusedEntry returns the entry which is either focused in the center table or shown in the preview
+func (n *Navigate) focusedEntry() (parser.Entry, bool) {
+	focusedItem := n.getFocusedItem()
+	if focusedItem == nil {
+		return parser.Entry{}, false
+	}
+	entry, ok := (*focusedItem).(parser.Entry)
+	return entry, ok
+}
+
+func (n *Navigate) handleTagCmd(cmd []string) tea.Cmd {
+	if len(cmd) < 3 {
+		n.cmdLine.SetMessage(ERR_TOO_FEW_ARGS)
+		return nil
+	}
+	var remove bool
+	switch cmd[1] {
+	case "add":
+		remove = false
+	case "rm":
+		remove = true
+	default:
+		n.cmdLine.SetMessage("Usage: :tag add|rm <tag>")
+		return nil
+	}
+	if n.inView() {
+		n.cmdLine.SetMessage(ERR_READ_ONLY)
+		return nil
+	}
+	entry, ok := n.focusedEntry()
+	if !ok {
+		n.cmdLine.SetMessage("Sorry, only entries can be tagged")
+		return nil
+	}
+	return makeTagAction(entry, strings.Join(cmd[2:], " "), remove)
+}
+
+// handleTagsCmd opens the list of all tags, or shows the entries carrying a tag if one is given
+func (n *Navigate) handleTagsCmd(cmd []string) tea.Cmd {
+	if len(cmd) > 1 {
+		n.handleGlobalSearch(tagQuery(strings.Join(cmd[1:], " ")))
+		return nil
+	}
+	if !n.tagList.Open(n.database.Parsed()) {
+		n.cmdLine.SetMessage("No tags")
+	}
+	return nil
+}
+
+// handleKeyTagList handles all key events while the list of tags is open
+func (n *Navigate) handleKeyTagList(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "ctrl+c", "q":
+		n.tagList.Close()
+		return nil
+	case "enter", "l":
+		tag, ok := n.tagList.Selected()
+		n.tagList.Close()
+		if ok {
+			n.handleGlobalSearch(tagQuery(tag))
+		}
+		return nil
+	}
+	var cmd tea.Cmd
+	n.tagList, cmd = n.tagList.Update(msg)
+	return cmd
+}
+
+// handleBreachCheckCmd starts looking up all passwords in a local copy of the Have I Been Pwned range files
+func (n *Navigate) handleBreachCheckCmd(cmd []string) tea.Cmd {
+	if len(cmd) > 2 {
+		n.cmdLine.SetMessage(ERR_TOO_MANY_ARGS)
+		return nil
+	}
+	dir := os.Getenv(hibp.ENV_DIR)
+	if len(cmd) == 2 {
+		dir = cmd[1]
+	}
+	if len(dir) == 0 {
+		n.cmdLine.SetMessage(fmt.Sprintf("Usage: :breachcheck <dir> (or set $%s)", hibp.ENV_DIR))
+		return nil
+	}
+	dir, err := expand(dir)
+	if err != nil {
+		n.cmdLine.SetMessage(err.Error())
+		return nil
+	}
+	store, err := hibp.Open(dir)
+	if err != nil {
+		n.cmdLine.SetMessage(fmt.Sprintf("Error: %s", err))
+		return nil
+	}
+	n.cmdLine.SetMessage("Checking passwords...")
+	// Passwords are collected here, so that the document isn't accessed concurrently
+	return breachCheckCmd(hibp.Collect(n.database.Parsed()), store)
+}
+
+func (n *Navigate) setBreached(breaches []hibp.Breach) {
+	n.breached = make(map[string]int, len(breaches))
+	for _, breach := range breaches {
+		n.breached[breach.UUID] = breach.Count
+	}
+	n.leftTable.SetBreached(n.breached)
+	n.centerTable.SetBreached(n.breached)
+	n.rightGroupTable.SetBreached(n.breached)
+	n.loadAllTables()
+}
+
+// handleKeyAudit handles all key events while the audit overlay is open
+func (n *Navigate) handleKeyAudit(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "ctrl+c", "q":
+		n.auditView.Close()
+		return nil
+	case "enter", "l":
+		uuid, ok := n.auditView.Selected()
+		if !ok {
+			return nil
+		}
+		n.auditView.Close()
+		n.centerTable.Focus()
+		n.rightEntryTable.Blur()
+		n.focusItem(uuid)
+		return nil
+	}
+	var cmd tea.Cmd
+	n.auditView, cmd = n.auditView.Update(msg)
+	return cmd
+}
+
+// handleKeyCheck handles all key events while the integrity check overlay is open
+func (n *Navigate) handleKeyCheck(msg tea.KeyMsg) tea.Cmd {
+	switch msg.String() {
+	case "esc", "ctrl+c", "q":
+		n.checkView.Close()
+		return nil
+	case "enter", "l":
+		uuid, ok := n.checkView.Selected()
+		if !ok {
+			return nil
+		}
+		n.checkView.Close()
+		n.centerTable.Focus()
+		n.rightEntryTable.Blur()
+		n.focusItem(uuid)
+		return nil
+	}
+	var cmd tea.Cmd
+	n.checkView, cmd = n.checkView.Update(msg)
+	return cmd
+}
+
 func (n *Navigate) handleSearch(query string, reverse bool) tea.Cmd {
+	q, err := search.ParseQuery(query)
+	if err != nil {
+		n.cmdLine.SetMessage(fmt.Sprintf("Invalid query: %s", err))
+		return nil
+	}
 	n.search = n.centerTable.FindAll(func(item parser.Item) bool {
-		switch item := item.(type) {
-		case parser.Group:
-			return strings.Contains(strings.ToLower(item.Name), strings.ToLower(query))
-		case parser.Entry:
-			return strings.Contains(strings.ToLower(item.TryGet("Title", "")), strings.ToLower(query))
+		if q.History {
+			// The table's items don't include the history of entries
+			if full, err := n.document.GetItem(append(n.path, item.GetUUID())); err == nil {
+				item = full
+			}
 		}
-		return false
+		return q.Match(item)
 	})
 	if len(n.search) == 0 {
 		n.cmdLine.SetMessage(fmt.Sprintf("Not found: %s", query))
@@ -372,6 +663,42 @@ func (n *Navigate) handleSearch(query string, reverse bool) tea.Cmd {
 	return cmd
 }
 
+func (n *Navigate) handleGlobalSearch(query string) {
+	err := n.results.Search(n.database.Parsed(), query)
+	if err != nil {
+		n.cmdLine.SetMessage(fmt.Sprintf("Invalid query: %s", err))
+		return
+	}
+	n.resizeAll()
+	if n.results.Empty() {
+		n.cmdLine.SetMessage(fmt.Sprintf("Not found: %s", query))
+		return
+	}
+	n.centerTable.Focus()
+	n.rightEntryTable.Blur()
+	n.focusItem(n.results.Current().Item.GetUUID())
+}
+
+// moveGlobalResult focuses the global search result offset positions away from the current one
+func (n *Navigate) moveGlobalResult(offset int) {
+	result := n.results.Move(offset)
+	if result == nil {
+		return
+	}
+	n.focusItem(result.Item.GetUUID())
+}
+
+func (n *Navigate) closeGlobalResults() {
+	n.results.Close()
+	n.resizeAll()
+}
+
+// refreshGlobalResults re-runs the global search after the document was changed
+func (n *Navigate) refreshGlobalResults() {
+	n.results.Refresh(n.database.Parsed())
+	n.resizeAll()
+}
+
 func (n *Navigate) nextSearchResult() {
 	if n.searchForward {
 		n.incSearchIndex()
@@ -430,7 +757,9 @@ func (n *Navigate) handleUndo() tea.Cmd {
 	}
 
 	n.cmdLine.SetMessage(fmt.Sprintf("Undo: %s", description))
+	n.refreshViews()
 	n.loadAllTables()
+	n.refreshGlobalResults()
 
 	// An undoable action may ask for a tea.Cmd to be executed after it is undone, such as focusing a changed item
 	if cmd, ok := result.(tea.Cmd); ok {
@@ -452,7 +781,9 @@ func (n *Navigate) handleRedo() tea.Cmd {
 	}
 
 	n.cmdLine.SetMessage(fmt.Sprintf("Redo: %s", description))
+	n.refreshViews()
 	n.loadAllTables()
+	n.refreshGlobalResults()
 
 	if cmd, ok := result.(tea.Cmd); ok {
 		return cmd
@@ -462,7 +793,7 @@ func (n *Navigate) handleRedo() tea.Cmd {
 }
 
 func (n Navigate) Init() tea.Cmd {
-	return nil
+	return otpTickCmd()
 }
 
 func (n Navigate) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
@@ -475,6 +806,9 @@ func (n Navigate) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return n, tea.Quit
 	case groupTableCursorChanged:
 		n.loadPreviewTable()
+	case otpTickMsg:
+		n.rightEntryTable.refreshOTP(msg.time)
+		return n, otpTickCmd()
 	case focusItemMsg:
 		n.focusItem(msg.uuid)
 		return n, nil
@@ -484,6 +818,21 @@ func (n Navigate) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case searchInputMsg:
 		cmd = n.handleSearch(msg.query, msg.reverse)
 		return n, cmd
+	case globalSearchInputMsg:
+		n.handleGlobalSearch(msg.query)
+		return n, nil
+	case breachCheckDoneMsg:
+		if msg.err != nil {
+			n.cmdLine.SetMessage(fmt.Sprintf("Error while checking passwords: %s", msg.err))
+			return n, nil
+		}
+		n.setBreached(msg.breaches)
+		if len(msg.breaches) == 0 {
+			n.cmdLine.SetMessage("No compromised passwords found")
+		} else {
+			n.cmdLine.SetMessage(fmt.Sprintf("%d compromised passwords found, marked with '%s'", len(msg.breaches), BREACHED_MARKER))
+		}
+		return n, nil
 	case saveDoneMsg:
 		n.cmdLine.SetMessage(fmt.Sprintf("Saved to %s", msg.path))
 		return n, msg.andThen
@@ -492,8 +841,14 @@ func (n Navigate) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case loadFailedMsg:
 		n.cmdLine.SetMessage(fmt.Sprintf("Error while loading: %s", msg.err))
 	case undoableActionMsg:
-		result, _ := n.undoman.Do(n.database.Parsed(), msg.action)
+		result, _, err := n.undoman.Do(n.database.Parsed(), msg.action)
+		if err != nil {
+			n.cmdLine.SetMessage(fmt.Sprintf("Error: %s", err))
+			return n, nil
+		}
+		n.refreshViews()
 		n.loadAllTables()
+		n.refreshGlobalResults()
 		if cmd, ok := result.(tea.Cmd); ok {
 			return n, cmd
 		}
@@ -510,6 +865,18 @@ func (n Navigate) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		n.resizeAll()
 		return n, globalResizeCmd(msg.Width, msg.Height)
 	case tea.KeyMsg:
+		if n.finder.Active() {
+			return n, n.handleKeyFinder(msg)
+		}
+		if n.tagList.Active() {
+			return n, n.handleKeyTagList(msg)
+		}
+		if n.auditView.Active() {
+			return n, n.handleKeyAudit(msg)
+		}
+		if n.checkView.Active() {
+			return n, n.handleKeyCheck(msg)
+		}
 		if n.cmdLine.Focused() {
 			// Key events should not be handled by Navigate in case the command line is active
 			break
@@ -529,7 +896,10 @@ func (n Navigate) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
-	if n.cmdLine.Focused() {
+	if n.finder.Active() {
+		n.finder, cmd = n.finder.Update(msg)
+		return n, cmd
+	} else if n.cmdLine.Focused() {
 		n.cmdLine, cmd = n.cmdLine.Update(msg)
 		return n, cmd
 	} else if n.rightEntryTable.Focused() {
@@ -554,6 +924,11 @@ func (n *Navigate) handleKeyAnyFocus(msg tea.KeyMsg) (bool, tea.Cmd) {
 		return true, n.handleUndo()
 	case "ctrl+r":
 		return true, n.handleRedo()
+	case "r":
+		n.toggleRaw()
+		return true, nil
+	case "ctrl+p":
+		return true, n.openFinder("")
 	}
 	return false, nil
 }
@@ -563,6 +938,8 @@ func (n *Navigate) handleKeyDefault(msg tea.KeyMsg) (bool, tea.Cmd) {
 	switch msg.String() {
 	case "y":
 		return true, n.copyToClipboard()
+	case "t":
+		return true, n.copyOTPToClipboard()
 	case "l":
 		n.moveRight()
 		return true, nil
@@ -570,11 +947,24 @@ func (n *Navigate) handleKeyDefault(msg tea.KeyMsg) (bool, tea.Cmd) {
 		n.moveLeft()
 		return true, nil
 	case "n":
-		n.nextSearchResult()
+		if n.results.Active() {
+			n.moveGlobalResult(1)
+		} else {
+			n.nextSearchResult()
+		}
 		return true, nil
 	case "N":
-		n.previousSearchResult()
+		if n.results.Active() {
+			n.moveGlobalResult(-1)
+		} else {
+			n.previousSearchResult()
+		}
 		return true, nil
+	case "esc":
+		if n.results.Active() {
+			n.closeGlobalResults()
+			return true, nil
+		}
 	}
 	return false, nil
 }
@@ -587,6 +977,9 @@ func (n *Navigate) handleKeyCmdLineTrigger(msg tea.KeyMsg) (bool, tea.Cmd) {
 		return true, n.cmdLine.StartInput(PROMPT_SEARCH, SearchCallback(false))
 	case PROMPT_REV_SEARCH:
 		return true, n.cmdLine.StartInput(PROMPT_REV_SEARCH, SearchCallback(true))
+	case "ctrl+f":
+		// Not "g", which the tables use to jump to the top
+		return true, n.cmdLine.StartInput(PROMPT_GLOBAL_SEARCH, GlobalSearchCallback)
 	case "c":
 		// Note that we handle this keypress here even though it might seem
 		// like the right entry/group table should handle it. However, the
@@ -599,6 +992,15 @@ func (n *Navigate) handleKeyCmdLineTrigger(msg tea.KeyMsg) (bool, tea.Cmd) {
 }
 
 func (n Navigate) View() string {
+	if n.finder.Active() {
+		return lipgloss.JoinVertical(lipgloss.Left, n.finder.View(), n.cmdLine.View())
+	}
+	if n.auditView.Active() {
+		return lipgloss.JoinVertical(lipgloss.Left, n.auditView.View(), n.cmdLine.View())
+	}
+	if n.checkView.Active() {
+		return lipgloss.JoinVertical(lipgloss.Left, n.checkView.View(), n.cmdLine.View())
+	}
 	var preview string
 	focusedItem := n.getFocusedItem()
 	if focusedItem == nil {
@@ -611,11 +1013,18 @@ func (n Navigate) View() string {
 			preview = n.rightEntryTable.View()
 		}
 	}
+	center := n.centerTable.View()
+	if n.tagList.Active() {
+		center = n.tagList.View()
+	}
 	tables := lipgloss.JoinHorizontal(
 		lipgloss.Top,
 		tablePadding.Render(n.leftTable.View()),
-		tablePadding.Render(n.centerTable.View()),
+		tablePadding.Render(center),
 		preview,
 	)
+	if n.results.Active() {
+		return lipgloss.JoinVertical(lipgloss.Left, tables, n.results.View(), n.cmdLine.View())
+	}
 	return lipgloss.JoinVertical(lipgloss.Left, tables, n.cmdLine.View())
 }
@@ -0,0 +1,151 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/search"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Maximum number of results shown at once, not including the header
+const RESULTS_PANE_MAX_ROWS = 8
+
+var resultsHeaderStyle = lipgloss.NewStyle().Bold(true)
+var resultsSelectedStyle = lipgloss.NewStyle().
+	Reverse(true).
+	Bold(true).
+	Foreground(lipgloss.Color("#9dcbf4"))
+
+// resultsPane shows the results of a search over the whole database
+type resultsPane struct {
+	query   string
+	parsed  search.Query
+	results []search.Result
+	index   int
+	active  bool
+	width   int
+}
+
+// Search runs a search for query over the whole document and activates the pane
+func (p *resultsPane) Search(d *parser.Document, query string) error {
+	parsed, err := search.ParseQuery(query)
+	if err != nil {
+		return err
+	}
+	p.query = query
+	p.parsed = parsed
+	p.results = search.Search(d, parsed.Match)
+	p.index = 0
+	p.active = true
+	return nil
+}
+
+// Refresh re-runs the last search, e. g. after the document was changed. The current result stays
+// selected if it is still found.
+func (p *resultsPane) Refresh(d *parser.Document) {
+	if !p.active {
+		return
+	}
+	current := p.Current()
+	p.results = search.Search(d, p.parsed.Match)
+	p.index = 0
+	if current == nil {
+		return
+	}
+	for i, result := range p.results {
+		if result.Item.GetUUID() == current.Item.GetUUID() {
+			p.index = i
+			return
+		}
+	}
+}
+
+func (p *resultsPane) Close() {
+	p.active = false
+	p.results = nil
+	p.query = ""
+}
+
+func (p *resultsPane) Active() bool {
+	return p.active
+}
+
+func (p *resultsPane) Empty() bool {
+	return len(p.results) == 0
+}
+
+// Current returns the selected result, or nil if there are no results
+func (p *resultsPane) Current() *search.Result {
+	if len(p.results) == 0 {
+		return nil
+	}
+	return &p.results[p.index]
+}
+
+// Move selects the result offset positions away from the current one, wrapping around at either end
+func (p *resultsPane) Move(offset int) *search.Result {
+	if len(p.results) == 0 {
+		return nil
+	}
+	p.index = mod(p.index+offset, len(p.results))
+	return p.Current()
+}
+
+func (p *resultsPane) Resize(width int) {
+	p.width = width
+}
+
+func (p *resultsPane) GetHeight() int {
+	if !p.active {
+		return 0
+	}
+	rows := len(p.results)
+	if rows > RESULTS_PANE_MAX_ROWS {
+		rows = RESULTS_PANE_MAX_ROWS
+	}
+	return rows + 1
+}
+
+func (p resultsPane) View() string {
+	if !p.active {
+		return ""
+	}
+	if len(p.results) == 0 {
+		return resultsHeaderStyle.Render(fmt.Sprintf("No results for '%s'", p.query))
+	}
+	lines := []string{resultsHeaderStyle.Render(
+		fmt.Sprintf("Results for '%s' (%d/%d)", p.query, p.index+1, len(p.results)),
+	)}
+
+	// Scroll such that the selected result is visible
+	first := 0
+	if p.index >= RESULTS_PANE_MAX_ROWS {
+		first = p.index - RESULTS_PANE_MAX_ROWS + 1
+	}
+	for i := first; i < len(p.results) && i < first+RESULTS_PANE_MAX_ROWS; i++ {
+		line := p.results[i].FullName()
+		if _, ok := p.results[i].Item.(parser.Group); ok {
+			line += search.PATH_SEPARATOR
+		}
+		line = truncate(line, p.width)
+		if i == p.index {
+			line = resultsSelectedStyle.Render(line)
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// truncate shortens s to at most width runes, indicating the omission with an ellipsis
+func truncate(s string, width int) string {
+	runes := []rune(s)
+	if width <= 0 || len(runes) <= width {
+		return s
+	}
+	if width == 1 {
+		return "…"
+	}
+	return string(runes[:width-1]) + "…"
+}
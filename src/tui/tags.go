@@ -0,0 +1,140 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Zaphoood/tresor/src/keepass/parser"
+	"github.com/Zaphoood/tresor/src/keepass/search"
+	"github.com/Zaphoood/tresor/src/keepass/undo"
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// tagList is an overlay listing all tags of the database along with the number of entries carrying them
+type tagList struct {
+	model  table.Model
+	styles table.Styles
+	tags   []search.TagCount
+	active bool
+}
+
+func newTagList(styles table.Styles) tagList {
+	return tagList{
+		model:  table.New(table.WithStyles(styles), table.WithFocused(true)),
+		styles: styles,
+	}
+}
+
+// Open activates the overlay with the tags of the given document. Returns false if there are no tags.
+func (l *tagList) Open(d *parser.Document) bool {
+	l.tags = search.Tags(d)
+	if len(l.tags) == 0 {
+		return false
+	}
+	rows := make([]table.Row, 0, len(l.tags))
+	for _, tag := range l.tags {
+		rows = append(rows, table.Row{tag.Tag, numberStyle.Render(fmt.Sprint(tag.Count))})
+	}
+	l.model.SetRows(rows)
+	l.model.SetCursor(0)
+	l.active = true
+	return true
+}
+
+func (l *tagList) Close() {
+	l.active = false
+}
+
+func (l *tagList) Active() bool {
+	return l.active
+}
+
+// Selected returns the tag under the cursor
+func (l *tagList) Selected() (string, bool) {
+	if len(l.tags) == 0 {
+		return "", false
+	}
+	return l.tags[l.model.Cursor()].Tag, true
+}
+
+func (l *tagList) Resize(width, height int) {
+	l.model.SetWidth(width)
+	l.model.SetHeight(height)
+	frameWidth, _ := l.styles.Header.GetFrameSize()
+	l.model.SetColumns([]table.Column{
+		{Title: "Tag", Width: width - 2*frameWidth - NUM_COL_WIDTH},
+		{Title: "Val", Width: NUM_COL_WIDTH},
+	})
+}
+
+func (l tagList) Update(msg tea.Msg) (tagList, tea.Cmd) {
+	if !l.active {
+		return l, nil
+	}
+	var cmd tea.Cmd
+	l.model, cmd = l.model.Update(msg)
+	return l, cmd
+}
+
+func (l tagList) View() string {
+	if !l.active {
+		return ""
+	}
+	return resultsHeaderStyle.Render("Tags") + "\n" + truncateHeader(l.model.View())
+}
+
+// tagQuery returns a search query matching all entries carrying the given tag
+func tagQuery(tag string) string {
+	if !strings.Contains(tag, `"`) {
+		return fmt.Sprintf(`tag:"%s"`, tag)
+	}
+	return fmt.Sprintf("tag:/^%s$/", strings.ReplaceAll(regexp.QuoteMeta(tag), "/", `\/`))
+}
+
+// makeTagAction returns a command for adding a tag to or removing it from an entry as an undoable action
+func makeTagAction(entry parser.Entry, tag string, remove bool) tea.Cmd {
+	newEntry := entry
+	var description string
+	if remove {
+		if !newEntry.RemoveTag(tag) {
+			return func() tea.Msg { return setCommandLineMessageMsg{fmt.Sprintf("Entry has no tag '%s'", tag)} }
+		}
+		description = fmt.Sprintf("Remove tag '%s'", tag)
+	} else {
+		changed, err := newEntry.AddTag(tag)
+		if err != nil {
+			return func() tea.Msg { return setCommandLineMessageMsg{err.Error()} }
+		}
+		if !changed {
+			return func() tea.Msg { return setCommandLineMessageMsg{fmt.Sprintf("Entry already has tag '%s'", tag)} }
+		}
+		description = fmt.Sprintf("Add tag '%s'", tag)
+	}
+	return func() tea.Msg {
+		return undoableActionMsg{undo.NewUpdateEntryAction(
+			newEntry,
+			entry,
+			focusChangedItemCmd(newEntry.UUID),
+			description,
+		)}
+	}
+}
+
+// makeSetTagsAction returns a command for replacing all tags of an entry as an undoable action
+func makeSetTagsAction(entry parser.Entry, tags string, description string) tea.Cmd {
+	newEntry := entry
+	newEntry.SetTags(parser.SplitTags(tags))
+	if newEntry.Tags == entry.Tags {
+		return nil
+	}
+	return func() tea.Msg {
+		return undoableActionMsg{undo.NewUpdateEntryAction(
+			newEntry,
+			entry,
+			focusChangedItemCmd(newEntry.UUID),
+			description,
+		)}
+	}
+}
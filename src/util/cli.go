@@ -5,7 +5,7 @@ import (
 	"fmt"
 )
 
-const USAGE = "Usage: %s [FILE]"
+const USAGE = "Usage: %s [FILE | COMMAND [ARGS...]]"
 
 // If there is exactly one command line argument, use it as the file path
 func ParseCommandLineArgs(args []string) (string, error) {
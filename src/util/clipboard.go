@@ -0,0 +1,4 @@
+package util
+
+// Seconds after which protected values are cleared from the clipboard by default
+const CLEAR_CLIPBOARD_DELAY = 10